@@ -0,0 +1,134 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Barramento de eventos em memória. Publicar nunca bloqueia: cada
+// inscrição tem um buffer próprio, e uma inscrição cujo buffer enche
+// (um cliente lento) é encerrada em vez de atrasar as demais ou perder
+// eventos em silêncio. Os eventos mais recentes ficam guardados para que
+// o cliente possa retomar a inscrição de onde parou.
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// `bufferSize` é quantos eventos cada inscrição acumula antes de ser
+// considerada lenta, e `historySize` quantos eventos ficam disponíveis
+// para quem retoma uma inscrição.
+func NewBus(bufferSize, historySize int) *Bus {
+	return &Bus{
+		history:     make([]Event, 0, historySize),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Numera o evento e o entrega às inscrições interessadas.
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	if b.historySize > 0 {
+		if len(b.history) == b.historySize {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, event)
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			sub.lagged = true
+			b.remove(sub)
+		}
+	}
+}
+
+// Cria uma inscrição nos eventos aceitos por `filter`. Com `lastEventID`
+// diferente de zero, a inscrição retoma uma anterior: os eventos guardados
+// publicados depois dele são retornados em `missed`, e `complete` indica
+// se nenhum deles se perdeu (o histórico pode já ter descartado parte
+// deles, ou o id pode ser de antes de o processo reiniciar).
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan Event, b.bufferSize),
+	}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, true
+	}
+
+	oldest := b.lastID - uint64(len(b.history)) + 1
+	complete = lastEventID <= b.lastID && lastEventID+1 >= oldest
+
+	for _, event := range b.history {
+		if event.ID > lastEventID && filter(event) {
+			missed = append(missed, event)
+		}
+	}
+
+	return sub, missed, complete
+}
+
+// Deve ser chamado com `mu` travado.
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	events chan Event
+	// Protegido pelo `mu` do barramento
+	lagged bool
+}
+
+// Canal dos eventos, fechado quando a inscrição é encerrada.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Indica se a inscrição foi encerrada por não acompanhar os eventos.
+func (s *Subscription) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.lagged
+}
+
+// Encerra a inscrição. Pode ser chamado mais de uma vez.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Lê os eventos já entregues à inscrição, sem esperar por novos.
+func drain(sub *Subscription) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	bus := NewBus(10, 10)
+	streamID, publisherID, followerID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	byStream, _, _ := bus.Subscribe(ForLiveStream(streamID), 0)
+	byPublisher, _, _ := bus.Subscribe(ForUsers(publisherID), 0)
+	byOther, _, _ := bus.Subscribe(ForUsers(primitive.NewObjectID()), 0)
+
+	bus.Publish(StreamLive(streamID, publisherID))
+	bus.Publish(ViewerCountChanged(primitive.NewObjectID(), publisherID, 3))
+	bus.Publish(UserFollowed(followerID, publisherID))
+
+	received := drain(byStream)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, TypeStreamLive, received[0].Type)
+		assert.Equal(t, uint64(1), received[0].ID)
+		assert.False(t, received[0].At.IsZero())
+	}
+
+	received = drain(byPublisher)
+	if assert.Len(t, received, 3) {
+		assert.Equal(t, TypeUserFollowed, received[2].Type)
+		assert.Equal(t, FollowData{FollowerID: followerID, FolloweeID: publisherID}, received[2].Data)
+	}
+
+	assert.Empty(t, drain(byOther))
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	bus := NewBus(2, 10)
+	userID := primitive.NewObjectID()
+
+	slow, _, _ := bus.Subscribe(ForUsers(userID), 0)
+	fast, _, _ := bus.Subscribe(ForUsers(userID), 0)
+
+	bus.Publish(StreamLive(primitive.NewObjectID(), userID))
+	bus.Publish(StreamLive(primitive.NewObjectID(), userID))
+	assert.Len(t, drain(fast), 2)
+
+	// O terceiro evento não cabe no buffer de quem não leu nada
+	bus.Publish(StreamLive(primitive.NewObjectID(), userID))
+
+	assert.Len(t, drain(slow), 2)
+	_, open := <-slow.Events()
+	assert.False(t, open)
+	assert.True(t, slow.Lagged())
+
+	// Os demais continuam recebendo
+	received := drain(fast)
+	if assert.Len(t, received, 1) {
+		assert.Equal(t, uint64(3), received[0].ID)
+	}
+	assert.False(t, fast.Lagged())
+
+	fast.Close()
+	fast.Close()
+	_, open = <-fast.Events()
+	assert.False(t, open)
+}
+
+func TestResume(t *testing.T) {
+	bus := NewBus(10, 3)
+	userID := primitive.NewObjectID()
+
+	for range 5 {
+		bus.Publish(StreamLive(primitive.NewObjectID(), userID))
+	}
+
+	// Os eventos 3 a 5 ainda estão no histórico
+	sub, missed, complete := bus.Subscribe(ForUsers(userID), 2)
+	assert.True(t, complete)
+	require.Len(t, missed, 3)
+	assert.Equal(t, uint64(3), missed[0].ID)
+	sub.Close()
+
+	// O evento 2 já foi descartado
+	_, missed, complete = bus.Subscribe(ForUsers(userID), 1)
+	assert.False(t, complete)
+	assert.Len(t, missed, 3)
+
+	// Nada foi perdido por quem já viu o último evento
+	_, missed, complete = bus.Subscribe(ForUsers(userID), 5)
+	assert.True(t, complete)
+	assert.Empty(t, missed)
+
+	// Um id do futuro vem de antes de o processo reiniciar
+	_, _, complete = bus.Subscribe(ForUsers(userID), 10)
+	assert.False(t, complete)
+}
@@ -0,0 +1,105 @@
+// Eventos publicados pela API conforme as lives e os usuários mudam,
+// entregues em tempo real aos clientes inscritos.
+package events
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Type string
+
+const (
+	// Uma live foi aberta pelo publisher
+	TypeStreamLive Type = "stream.live"
+	// Uma live foi encerrada, pelo publisher ou pelo nginx-rtmp
+	TypeStreamOffline Type = "stream.offline"
+	// O contador de espectadores de uma live ativa mudou
+	TypeViewerCountChanged Type = "viewer_count.changed"
+	// Um usuário passou a seguir outro
+	TypeUserFollowed Type = "user.followed"
+)
+
+type Event struct {
+	// Sequencial e crescente enquanto o processo estiver de pé, usado
+	// para retomar a inscrição de onde ela parou
+	ID   uint64    `json:"id"`
+	Type Type      `json:"type"`
+	At   time.Time `json:"at"`
+	Data any       `json:"data"`
+
+	// Usados apenas para decidir quais inscrições recebem o evento: a live
+	// envolvida, se houver, e os usuários envolvidos
+	liveStreamID primitive.ObjectID
+	userIDs      []primitive.ObjectID
+}
+
+type StreamData struct {
+	LiveStreamID primitive.ObjectID `json:"live_stream_id"`
+	PublisherID  primitive.ObjectID `json:"publisher_id"`
+}
+
+type ViewerCountData struct {
+	LiveStreamID primitive.ObjectID `json:"live_stream_id"`
+	PublisherID  primitive.ObjectID `json:"publisher_id"`
+	ViewerCount  int                `json:"viewer_count"`
+}
+
+type FollowData struct {
+	FollowerID primitive.ObjectID `json:"follower_id"`
+	FolloweeID primitive.ObjectID `json:"followee_id"`
+}
+
+func StreamLive(liveStreamID, publisherID primitive.ObjectID) Event {
+	return streamEvent(TypeStreamLive, liveStreamID, publisherID, StreamData{LiveStreamID: liveStreamID, PublisherID: publisherID})
+}
+
+func StreamOffline(liveStreamID, publisherID primitive.ObjectID) Event {
+	return streamEvent(TypeStreamOffline, liveStreamID, publisherID, StreamData{LiveStreamID: liveStreamID, PublisherID: publisherID})
+}
+
+func ViewerCountChanged(liveStreamID, publisherID primitive.ObjectID, viewers int) Event {
+	return streamEvent(TypeViewerCountChanged, liveStreamID, publisherID, ViewerCountData{LiveStreamID: liveStreamID, PublisherID: publisherID, ViewerCount: viewers})
+}
+
+func UserFollowed(followerID, followeeID primitive.ObjectID) Event {
+	return Event{
+		Type:    TypeUserFollowed,
+		Data:    FollowData{FollowerID: followerID, FolloweeID: followeeID},
+		userIDs: []primitive.ObjectID{followerID, followeeID},
+	}
+}
+
+func streamEvent(eventType Type, liveStreamID, publisherID primitive.ObjectID, data any) Event {
+	return Event{
+		Type:         eventType,
+		Data:         data,
+		liveStreamID: liveStreamID,
+		userIDs:      []primitive.ObjectID{publisherID},
+	}
+}
+
+// Decide se uma inscrição recebe o evento.
+type Filter func(event Event) bool
+
+// Eventos da live `liveStreamID`.
+func ForLiveStream(liveStreamID primitive.ObjectID) Filter {
+	return func(event Event) bool {
+		return event.liveStreamID == liveStreamID
+	}
+}
+
+// Eventos que envolvem algum dos usuários `userIDs`: as lives de que são
+// publishers e os follows feitos ou recebidos por eles.
+func ForUsers(userIDs ...primitive.ObjectID) Filter {
+	set := make(map[primitive.ObjectID]bool, len(userIDs))
+	for _, id := range userIDs {
+		set[id] = true
+	}
+
+	return func(event Event) bool {
+		return slices.ContainsFunc(event.userIDs, func(id primitive.ObjectID) bool { return set[id] })
+	}
+}
@@ -2,49 +2,134 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/application/ranking"
+	"github.com/gtvb/livestream/infra/auth"
 	"github.com/gtvb/livestream/infra/repository"
-	"github.com/gtvb/livestream/utils"
+	"github.com/gtvb/livestream/infra/repository/memory"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func setupDatabase() *utils.TestContainer {
-	container, err := utils.NewTestContainer("ls-db-test")
-	if err != nil {
-		log.Panicf("Error: could not start container, reason -> %s\n", err)
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	// Status não padronizado (usado pelo nginx) para requisições em que o
+	// cliente desconectou antes da resposta
+	statusClientClosedRequest = 499
+)
+
+// Traduz um erro vindo dos repositórios na resposta HTTP correspondente.
+// `message` descreve a operação que falhou e é usada nas respostas de
+// 404, 409 e 500. Requisições canceladas pelo cliente são abortadas sem corpo.
+func respondWithError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		ctx.AbortWithStatus(statusClientClosedRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		ctx.JSON(http.StatusGatewayTimeout, gin.H{"message": "database operation timed out"})
+	case errors.Is(err, repository.ErrUnavailable):
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"message": "database unavailable"})
+	case errors.Is(err, repository.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{"message": message})
+	case errors.Is(err, repository.ErrConflict):
+		ctx.JSON(http.StatusConflict, gin.H{"message": message})
+	case errors.Is(err, repository.ErrNoChange):
+		ctx.JSON(http.StatusOK, gin.H{"message": "nothing to update"})
+	case errors.Is(err, models.ErrSelfFollow):
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "users cannot follow themselves"})
+	case errors.Is(err, models.ErrSelfRelation):
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "users cannot block or mute themselves"})
+	case errors.Is(err, repository.ErrInvalidCursor):
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid cursor"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": message})
+	}
+}
+
+// Lê os parâmetros de paginação por cursor `limit` e `cursor` (o token
+// opaco de uma página anterior) da query, respondendo com 400 caso sejam
+// inválidos.
+func parseCursorPagination(ctx *gin.Context) (models.PageRequest, bool) {
+	req := models.PageRequest{Limit: defaultPageSize}
+
+	if q := ctx.Query("limit"); q != "" {
+		parsed, err := strconv.Atoi(q)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "limit needs to be an integer between 1 and 100"})
+			return req, false
+		}
+		req.Limit = parsed
 	}
 
-	err = container.SetupDatabaseWrapper()
-	if err != nil {
-		log.Panicf("Error: could not start database wrapper, reason -> %s\n", err)
+	if q := ctx.Query("cursor"); q != "" {
+		cursor, err := models.DecodeCursor(q)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid cursor"})
+			return req, false
+		}
+		req.Cursor = cursor
 	}
 
-	return container
+	return req, true
 }
 
-func setupEnv(database *db.Database) ServerEnv {
-	env := ServerEnv{}
+// Ambiente dos testes dos handlers, com os repositórios em memória. Os
+// comportamentos próprios do Mongo são testados no pacote dos repositórios.
+func setupEnv() ServerEnv {
+	liveStreamRepo := memory.NewLiveStreamRepository()
+	streamSessionRepo := memory.NewStreamSessionRepository()
 
-	userRepo := repository.NewUserRepository(database, "users_test")
-	liveStreamRepo := repository.NewLiveStreamRepository(database, "livestreams_test")
+	return ServerEnv{
+		userRepository:           memory.NewUserRepository(),
+		liveStreamsRepository:    liveStreamRepo,
+		refreshTokenRepository:   memory.NewRefreshTokenRepository(),
+		streamSessionRepository:  streamSessionRepo,
+		viewerPresenceRepository: memory.NewViewerPresenceRepository(),
+		followRepository:         memory.NewFollowRepository(),
+		relationRepository:       memory.NewRelationRepository(),
+		unitOfWork:               memory.NewUnitOfWork(),
+		viewerHeartbeatTTL:       defaultViewerHeartbeatTTL,
+		viewerTokenTTL:           defaultViewerTokenTTL,
+		restoreWindow:            defaultRestoreWindow,
+		tokenManager:             auth.NewTokenManager("test-secret", time.Hour, time.Hour),
+		feed:                     ranking.NewFeed(liveStreamRepo, streamSessionRepo, ranking.NewWeightedRanker(ranking.DefaultWeights()), ranking.DefaultMaxCandidates),
+		events:                   events.NewBus(defaultEventBufferSize, defaultEventHistorySize),
+	}
+}
 
-	env.userRepository = userRepo
-	env.liveStreamsRepository = liveStreamRepo
+func makeRequest(router *gin.Engine, method, url string, body interface{}) *httptest.ResponseRecorder {
+	requestBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, req)
 
-	return env
+	return writer
 }
 
-func makeRequest(router *gin.Engine, method, url string, body interface{}) *httptest.ResponseRecorder {
+func makeAuthenticatedRequest(router *gin.Engine, method, url string, body interface{}, token string) *httptest.ResponseRecorder {
 	requestBody, _ := json.Marshal(body)
 	req, _ := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
+	req.Header.Set("Authorization", "Bearer "+token)
 
 	writer := httptest.NewRecorder()
 	router.ServeHTTP(writer, req)
 
 	return writer
 }
+
+func generateTestToken(env ServerEnv, userID primitive.ObjectID) string {
+	token, _, _ := env.tokenManager.GenerateAccessToken(userID)
+	return token
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondWithError(t *testing.T) {
+	respond := func(err error) (int, string) {
+		router := gin.New()
+		router.GET("/", func(ctx *gin.Context) {
+			respondWithError(ctx, err, "failed to find stream")
+		})
+
+		writer := makeRequest(router, "GET", "/", nil)
+		return writer.Code, writer.Body.String()
+	}
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: %w", repository.ErrUnavailable, context.DeadlineExceeded))
+		assert.Equal(t, http.StatusGatewayTimeout, code)
+		assert.Contains(t, body, "database operation timed out")
+	})
+
+	t.Run("Canceled by the client", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: %w", repository.ErrUnavailable, context.Canceled))
+		assert.Equal(t, statusClientClosedRequest, code)
+		assert.Empty(t, body)
+	})
+
+	t.Run("Unavailable", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: no reachable servers", repository.ErrUnavailable))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, body, "database unavailable")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: no match", repository.ErrNotFound))
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Contains(t, body, "failed to find stream")
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		code, _ := respond(repository.ErrConflict)
+		assert.Equal(t, http.StatusConflict, code)
+	})
+
+	t.Run("No change", func(t *testing.T) {
+		code, body := respond(repository.ErrNoChange)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Contains(t, body, "nothing to update")
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		code, body := respond(repository.ErrInvalidCursor)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, body, "invalid cursor")
+	})
+
+	t.Run("Self follow", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: _id 1", models.ErrSelfFollow))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, body, "users cannot follow themselves")
+	})
+
+	t.Run("Self relation", func(t *testing.T) {
+		code, body := respond(fmt.Errorf("%w: _id 1", models.ErrSelfRelation))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Contains(t, body, "users cannot block or mute themselves")
+	})
+
+	t.Run("Unknown error", func(t *testing.T) {
+		code, body := respond(errors.New("boom"))
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.Contains(t, body, "failed to find stream")
+		assert.NotContains(t, body, "boom")
+	})
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Eventos acumulados por cliente antes de ele ser desconectado por
+	// não acompanhar o ritmo
+	defaultEventBufferSize = 64
+	// Eventos recentes guardados para clientes que reconectam
+	defaultEventHistorySize = 1024
+
+	// Intervalo dos comentários enviados para que proxies não encerrem
+	// conexões sem eventos
+	eventsKeepAlivePeriod = 15 * time.Second
+)
+
+// swagger:route GET /events events streamEvents
+//
+// Stream real-time events as Server-Sent Events. Exactly one of the query parameters
+// selects the subscription: `stream` receives the events of a live stream, `user` the
+// events involving a user (their live streams and follows) and `following=true` the
+// events involving the users the authenticated user follows, except blocked or muted ones.
+//
+// Each event carries its id, and reconnecting with the `Last-Event-ID` header replays
+// the recent events that were missed. When some of them are no longer available, a
+// `reset` event is sent first and the client should reload its state. Clients that
+// fall too far behind receive a `lagged` event and are disconnected, and should
+// reconnect the same way.
+//
+// Responses:
+//
+//	200: eventStreamResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) streamEvents(ctx *gin.Context) {
+	filter, ok := env.parseEventFilter(ctx)
+	if !ok {
+		return
+	}
+
+	var lastEventID uint64
+	if header := ctx.GetHeader("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid Last-Event-ID"})
+			return
+		}
+		lastEventID = id
+	}
+
+	sub, missed, complete := env.events.Subscribe(filter, lastEventID)
+	defer sub.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	// Sem isso o nginx acumula os eventos antes de repassá-los
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	if !complete {
+		if err := writeSSE(ctx, "", "reset", gin.H{"message": "some events were missed, reload the current state"}); err != nil {
+			return
+		}
+	}
+
+	for _, event := range missed {
+		if err := writeEvent(ctx, event); err != nil {
+			return
+		}
+	}
+	// Envia os cabeçalhos mesmo sem eventos pendentes
+	ctx.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlivePeriod)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, open := <-sub.Events():
+			// O canal só é fechado por aqui ou quando o cliente fica para trás
+			if !open {
+				if sub.Lagged() {
+					writeSSE(ctx, "", "lagged", gin.H{"message": "too many pending events, reconnect to resume"})
+				}
+				return
+			}
+
+			if err := writeEvent(ctx, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		}
+	}
+}
+
+// Monta o filtro da inscrição a partir da query, respondendo com o erro
+// correspondente caso ela seja inválida. Quem foi bloqueado por um
+// publisher não acompanha os eventos dele.
+func (env *ServerEnv) parseEventFilter(ctx *gin.Context) (events.Filter, bool) {
+	stream, user, following := ctx.Query("stream"), ctx.Query("user"), ctx.Query("following") == "true"
+
+	selected := 0
+	for _, set := range []bool{stream != "", user != "", following} {
+		if set {
+			selected++
+		}
+	}
+	if selected != 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "exactly one of stream, user or following is required"})
+		return nil, false
+	}
+
+	switch {
+	case stream != "":
+		streamID, err := primitive.ObjectIDFromHex(stream)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+			return nil, false
+		}
+
+		livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+		if err != nil {
+			respondWithError(ctx, err, "failed to find stream")
+			return nil, false
+		}
+
+		if !env.requireNotBlockedBy(ctx, livestream.PublisherId) {
+			return nil, false
+		}
+
+		return events.ForLiveStream(streamID), true
+
+	case user != "":
+		userID, err := primitive.ObjectIDFromHex(user)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+			return nil, false
+		}
+
+		if _, err := env.userRepository.GetUserById(ctx.Request.Context(), userID); err != nil {
+			respondWithError(ctx, err, "could not find a user with this id")
+			return nil, false
+		}
+
+		if !env.requireNotBlockedBy(ctx, userID) {
+			return nil, false
+		}
+
+		return events.ForUsers(userID), true
+
+	default:
+		userID := authenticatedUserID(ctx)
+		if userID.IsZero() {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"message": "missing bearer token"})
+			return nil, false
+		}
+
+		// Os seguidos são lidos uma vez, na inscrição
+		followingIDs, err := env.followRepository.GetFollowingIDs(ctx.Request.Context(), userID)
+		if err != nil {
+			respondWithError(ctx, err, "could not fetch followed users from db")
+			return nil, false
+		}
+
+		hidden, err := env.relationRepository.GetRelatedIDs(ctx.Request.Context(), userID, models.RelationBlock, models.RelationMute)
+		if err != nil {
+			respondWithError(ctx, err, "could not fetch blocked and muted users from db")
+			return nil, false
+		}
+		followingIDs = slices.DeleteFunc(followingIDs, func(id primitive.ObjectID) bool { return slices.Contains(hidden, id) })
+
+		return events.ForUsers(followingIDs...), true
+	}
+}
+
+// Escreve um evento do barramento, com o seu id, e o envia ao cliente.
+func writeEvent(ctx *gin.Context, event events.Event) error {
+	return writeSSE(ctx, strconv.FormatUint(event.ID, 10), string(event.Type), event)
+}
+
+// Escreve uma mensagem no formato do Server-Sent Events, com `data`
+// serializado em JSON, e a envia ao cliente. Um `id` vazio é omitido.
+func writeSSE(ctx *gin.Context, id, name string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("event: %s\ndata: %s\n\n", name, payload)
+	if id != "" {
+		message = "id: " + id + "\n" + message
+	}
+
+	if _, err := fmt.Fprint(ctx.Writer, message); err != nil {
+		return err
+	}
+	ctx.Writer.Flush()
+
+	return nil
+}
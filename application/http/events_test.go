@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Abre o stream de eventos `url` retomando a partir de `lastEventID`, e
+// o encerra depois de `timeout`.
+func makeEventsRequest(router *gin.Engine, url, lastEventID string, timeout time.Duration) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	writer := httptest.NewRecorder()
+	router.ServeHTTP(writer, req)
+
+	return writer
+}
+
+func TestStreamEventsFilter(t *testing.T) {
+	env := ServerEnv{
+		tokenManager: auth.NewTokenManager("test-secret", time.Hour, time.Hour),
+		events:       events.NewBus(defaultEventBufferSize, defaultEventHistorySize),
+	}
+
+	router := gin.New()
+	router.GET("/events", env.optionalAuthMiddleware(), env.streamEvents)
+
+	t.Run("Missing subscription", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/events", nil)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+		assert.Contains(t, writer.Body.String(), "exactly one of stream, user or following is required")
+	})
+
+	t.Run("More than one subscription", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/events?stream="+primitive.NewObjectID().Hex()+"&following=true", nil)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+
+	t.Run("Invalid id", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/events?user=not-an-id", nil)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+		assert.Contains(t, writer.Body.String(), "invalid id")
+	})
+
+	t.Run("Anonymous following", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/events?following=true", nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/events?following=true", nil, "not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		assert.Contains(t, writer.Body.String(), "invalid or expired token")
+	})
+}
+
+func TestStreamEvents(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	// Abrir a live e receber um espectador publicam os eventos 1 e 2
+	liveStatus := true
+	writer := makeAuthenticatedRequest(router, "PATCH", "/livestreams/update/"+id.Hex(), UpdateLiveStreamBody{LiveStatus: &liveStatus}, generateTestToken(env, user.ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: watchTestStream(t, router, id)})
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	t.Run("Resume", func(t *testing.T) {
+		writer := makeEventsRequest(router, "/events?stream="+id.Hex(), "1", 100*time.Millisecond)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Equal(t, "text/event-stream", writer.Header().Get("Content-Type"))
+
+		body := writer.Body.String()
+		assert.Contains(t, body, "id: 2\nevent: viewer_count.changed\n")
+		assert.Contains(t, body, `"viewer_count":1`)
+		assert.NotContains(t, body, "stream.live")
+	})
+
+	t.Run("Missed events", func(t *testing.T) {
+		writer := makeEventsRequest(router, "/events?user="+user.ID.Hex(), "50", 100*time.Millisecond)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "event: reset\n")
+	})
+
+	t.Run("Unknown stream", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/events?stream="+primitive.NewObjectID().Hex(), nil)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+	})
+
+	t.Run("Blocked viewer", func(t *testing.T) {
+		viewerID, _ := env.userRepository.CreateUser(context.Background(), "viewer", "viewer@email.com", hashPassword("test_pass"))
+		viewer := viewerID.(primitive.ObjectID)
+
+		writer := makeAuthenticatedRequest(router, "PATCH", "/user/block/"+viewer.Hex(), nil, generateTestToken(env, user.ID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		writer = makeAuthenticatedRequest(router, "GET", "/events?stream="+id.Hex(), nil, generateTestToken(env, viewer))
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+	})
+}
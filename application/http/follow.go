@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// swagger:route GET /users/{id}/followers users getFollowers
+//
+// Get a page of the users that follow `id`.
+//
+// Responses:
+//
+//	200: followListResponse
+//	400: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getFollowers(ctx *gin.Context) {
+	env.listFollows(ctx, env.followRepository.GetFollowers, func(follow *models.Follow) primitive.ObjectID { return follow.FollowerID })
+}
+
+// swagger:route GET /users/{id}/following users getFollowing
+//
+// Get a page of the users that `id` follows.
+//
+// Responses:
+//
+//	200: followListResponse
+//	400: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getFollowing(ctx *gin.Context) {
+	env.listFollows(ctx, env.followRepository.GetFollowing, func(follow *models.Follow) primitive.ObjectID { return follow.FolloweeID })
+}
+
+// Responde com os perfis públicos dos usuários do outro lado (`other`)
+// da página de follows retornada por `list` para o usuário `id` dos
+// parâmetros. Usuários removidos são omitidos, então a página pode vir
+// com menos itens que o limite.
+func (env *ServerEnv) listFollows(ctx *gin.Context, list func(ctx context.Context, id primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error), other func(follow *models.Follow) primitive.ObjectID) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
+	}
+
+	// As listas de um usuário removido não são expostas
+	if _, err := env.userRepository.GetUserById(ctx.Request.Context(), id); err != nil {
+		respondWithError(ctx, err, "could not find a user with this id")
+		return
+	}
+
+	page, err := list(ctx.Request.Context(), id, req)
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch follows from db")
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(page.Items))
+	for _, follow := range page.Items {
+		ids = append(ids, other(follow))
+	}
+
+	env.respondWithProfiles(ctx, ids, page.NextCursor, page.PrevCursor)
+}
+
+// Responde com os perfis públicos dos usuários `ids`, na mesma ordem,
+// junto dos cursores da página de onde eles vieram. Usuários removidos
+// são omitidos.
+func (env *ServerEnv) respondWithProfiles(ctx *gin.Context, ids []primitive.ObjectID, nextCursor, prevCursor string) {
+	users, err := env.userRepository.GetUsersByIds(ctx.Request.Context(), ids)
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch users from db")
+		return
+	}
+
+	byID := make(map[primitive.ObjectID]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	profiles := make([]*models.PublicProfile, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			profiles = append(profiles, user.PublicProfile())
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"users": profiles, "next_cursor": nextCursor, "prev_cursor": prevCursor})
+}
+
+// Cria o follow e ajusta os contadores dos dois usuários, que precisam
+// mudar juntos. Deve ser chamado dentro de uma unidade de trabalho.
+func (env *ServerEnv) follow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	if err := env.followRepository.Follow(ctx, followerID, followeeID); err != nil {
+		return err
+	}
+	if err := env.userRepository.AddFollowCounts(ctx, followerID, 0, 1); err != nil {
+		return err
+	}
+	return env.userRepository.AddFollowCounts(ctx, followeeID, 1, 0)
+}
+
+// Desfaz o follow e ajusta os contadores dos dois usuários. Deve ser
+// chamado dentro de uma unidade de trabalho.
+func (env *ServerEnv) unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	if err := env.followRepository.Unfollow(ctx, followerID, followeeID); err != nil {
+		return err
+	}
+	if err := env.userRepository.AddFollowCounts(ctx, followerID, 0, -1); err != nil {
+		return err
+	}
+	return env.userRepository.AddFollowCounts(ctx, followeeID, -1, 0)
+}
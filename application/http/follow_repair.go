@@ -0,0 +1,38 @@
+package http
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const defaultFollowRepairPeriod = 24 * time.Hour
+
+// Recalcula os contadores de seguidores na inicialização e depois
+// periodicamente, corrigindo divergências deixadas por falhas parciais.
+func (env *ServerEnv) runFollowCountRepair(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		repaired, err := env.repairFollowCounts(context.Background())
+		if err != nil {
+			log.Printf("follow repair: failed to repair follow counts: %s\n", err)
+		} else if repaired > 0 {
+			log.Printf("follow repair: repaired the follow counts of %d users\n", repaired)
+		}
+
+		<-ticker.C
+	}
+}
+
+// Grava nos usuários os contadores calculados a partir dos follows,
+// retornando quantos usuários foram corrigidos.
+func (env *ServerEnv) repairFollowCounts(ctx context.Context) (int64, error) {
+	counts, err := env.followRepository.CountFollows(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return env.userRepository.SetFollowCounts(ctx, counts)
+}
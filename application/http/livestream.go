@@ -1,29 +1,36 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/application/ranking"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/gtvb/livestream/infra/repository"
 	"github.com/gtvb/livestream/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // swagger:route POST /livestreams/create livestreams createLiveStream
 //
 // Create a new live stream and assign it to the user specified in the request body.
+// The publisher must be the authenticated user.
 //
 // Responses:
 //
 //	201: liveStreamResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 //	404: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) createLiveStream(ctx *gin.Context) {
@@ -38,25 +45,34 @@ func (env *ServerEnv) createLiveStream(ctx *gin.Context) {
 		return
 	}
 
-	userId, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid ID"})
+	// Caso o publisher não seja informado, a live é criada
+	// para o usuário autenticado
+	userId := authenticatedUserID(ctx)
+	if id != "" {
+		userId, err = primitive.ObjectIDFromHex(id)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid ID"})
+			return
+		}
+	}
+
+	if !requireOwner(ctx, userId) {
 		return
 	}
 
-	_, err = env.userRepository.GetUserById(userId)
+	_, err = env.userRepository.GetUserById(ctx.Request.Context(), userId)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+		respondWithError(ctx, err, "user not found")
 		return
 	}
 
-	streamKey, err := uuid.NewV7()
+	streamKey, err := auth.GenerateStreamKey()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate stream key"})
 		return
 	}
 
-	filePath := filepath.Join("uploads", file.Filename)
+	filePath := filepath.Join(uploadsDir, file.Filename)
 	if err := ctx.SaveUploadedFile(file, filePath); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to save the image"})
 		return
@@ -68,23 +84,28 @@ func (env *ServerEnv) createLiveStream(ctx *gin.Context) {
 	}
 
 	fileUrl := fmt.Sprintf("%s/thumbs/%s", baseURL, file.Filename)
-	streamId, err := env.liveStreamsRepository.CreateLiveStream(name, fileUrl, streamKey.String(), userId)
+	streamId, err := env.liveStreamsRepository.CreateLiveStream(ctx.Request.Context(), name, fileUrl, streamKey, userId)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to create the live stream"})
+		respondWithError(ctx, err, "failed to create the live stream")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"stream_id": streamId})
+	// A chave só é exibida agora, já que apenas seu hash é guardado
+	ctx.JSON(http.StatusCreated, gin.H{"stream_id": streamId, "stream_key": streamKey})
 }
 
 // swagger:route DELETE /livestreams/delete/{id} livestreams deleteLiveStream
 //
-// Delete a live stream given a valid `id`.
+// Delete a live stream given a valid `id`. The live stream is permanently
+// removed, along with its thumbnail, once the restore window expires.
+// Only the publisher of the live stream can perform this operation.
 //
 // Responses:
 //
 //	200: messageResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 //	404: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) deleteLiveStream(ctx *gin.Context) {
@@ -96,23 +117,35 @@ func (env *ServerEnv) deleteLiveStream(ctx *gin.Context) {
 		return
 	}
 
-	err = env.liveStreamsRepository.DeleteLiveStream(id)
+	ls, ok := env.requireStreamOwner(ctx, id)
+	if !ok {
+		return
+	}
+
+	err = env.liveStreamsRepository.DeleteLiveStream(ctx.Request.Context(), id, time.Now())
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "failed to delete stream"})
+		respondWithError(ctx, err, "failed to delete stream")
 		return
 	}
 
+	if ls.LiveStatus {
+		env.events.Publish(events.StreamOffline(ls.ID, ls.PublisherId))
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
 }
 
 // swagger:route PATCH /livestreams/update/{id} livestreams updateLiveStream
 //
 // Update the data of a live stream identified by the specified `id`.
+// Only the publisher of the live stream can perform this operation.
 //
 // Responses:
 //
 //	200: messageResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 //	404: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) updateLiveStream(ctx *gin.Context) {
@@ -123,6 +156,11 @@ func (env *ServerEnv) updateLiveStream(ctx *gin.Context) {
 		return
 	}
 
+	ls, ok := env.requireStreamOwner(ctx, streamID)
+	if !ok {
+		return
+	}
+
 	var updateLiveStreamBody UpdateLiveStreamBody
 	if err := ctx.ShouldBindBodyWithJSON(&updateLiveStreamBody); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"message": "malformed request"})
@@ -138,12 +176,32 @@ func (env *ServerEnv) updateLiveStream(ctx *gin.Context) {
 		newData["live_stream_status"] = *updateLiveStreamBody.LiveStatus
 	}
 
-	err = env.liveStreamsRepository.UpdateLiveStream(streamID, newData)
+	if updateLiveStreamBody.AllowedIPs != nil {
+		for _, allowed := range *updateLiveStreamBody.AllowedIPs {
+			if _, err := models.ParseAllowedIP(allowed); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid allowed ip: " + allowed})
+				return
+			}
+		}
+		newData["allowed_ips"] = *updateLiveStreamBody.AllowedIPs
+	}
+
+	err = env.liveStreamsRepository.UpdateLiveStream(ctx.Request.Context(), streamID, newData)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "failed to update stream"})
+		respondWithError(ctx, err, "failed to update stream")
 		return
 	}
 
+	// Abrir e encerrar a live pela interface são as mudanças de estado
+	// que os clientes acompanham
+	if status := updateLiveStreamBody.LiveStatus; status != nil && *status != ls.LiveStatus {
+		if *status {
+			env.events.Publish(events.StreamLive(ls.ID, ls.PublisherId))
+		} else {
+			env.events.Publish(events.StreamOffline(ls.ID, ls.PublisherId))
+		}
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
 }
 
@@ -164,19 +222,54 @@ func (env *ServerEnv) getLiveStreamData(ctx *gin.Context) {
 		return
 	}
 
-	livestream, err := env.liveStreamsRepository.GetLiveStreamById(id)
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "failed to find stream"})
+		respondWithError(ctx, err, "failed to find stream")
 		return
 	}
 
 	ctx.JSON(http.StatusOK, gin.H{"livestream": livestream})
 }
 
+// swagger:route GET /livestreams/info/{id}/settings livestreams getLiveStreamSettings
+//
+// Get the publishing settings of the live stream identified by `id`, such as the
+// addresses allowed to publish. Only the publisher of the live stream can perform
+// this operation.
+//
+// Responses:
+//
+//	200: liveStreamSettingsResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getLiveStreamSettings(ctx *gin.Context) {
+	streamID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
+		return
+	}
+
+	livestream, ok := env.requireStreamOwner(ctx, streamID)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"settings": livestream.Settings()})
+}
+
 // generate swagger documentation for this function
 // swagger:route GET /livestreams/feed livestreams getLiveStreamFeed
 //
-// Get a feed of live streams.
+// Get a page of the live stream feed, ranked by current viewers, viewer growth,
+// session age and recency. Each entry embeds the public profile of the publisher,
+// which is null if they were deleted.
+//
+// Scores are computed again for every page, so the order across pages is best-effort:
+// a live stream whose audience changed between requests may repeat or be skipped.
+// Only the most watched live streams are ranked.
 //
 // Responses:
 //
@@ -184,88 +277,306 @@ func (env *ServerEnv) getLiveStreamData(ctx *gin.Context) {
 //	400: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) getFeed(ctx *gin.Context) {
-	numStreams := 20
-	q := ctx.Query("q")
-	if q != "" {
-		qInt, err := strconv.Atoi(q)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, gin.H{"message": "q needs to be an integer"})
-			return
-		}
-		numStreams = qInt
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
 	}
 
-	livestreams, err := env.liveStreamsRepository.GetLiveStreamFeed(numStreams)
+	page, err := env.feed.Page(ctx.Request.Context(), req, time.Now())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get livestream feed"})
+		respondWithError(ctx, err, "failed to get livestream feed")
 		return
 	}
 
-	var users []*models.User
-	for _, stream := range livestreams {
-		user, err := env.userRepository.GetUserById(stream.PublisherId)
-		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"message": "could not get user for this stream"})
-			return
-		}
+	env.respondWithFeed(ctx, page)
+}
+
+// swagger:route GET /livestreams/feed/personal livestreams getPersonalFeed
+//
+// Get a page of the personalized feed of the authenticated user: live streams from
+// followed publishers first, then the recommended ones, each section ranked like the
+// discovery feed. Entries from followed publishers have `following` set, and
+// publishers blocked or muted by the user are left out.
+//
+// Responses:
+//
+//	200: liveStreamFeedResponse
+//	400: messageResponse
+//	401: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getPersonalFeed(ctx *gin.Context) {
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
+	}
+
+	following, err := env.followRepository.GetFollowingIDs(ctx.Request.Context(), authenticatedUserID(ctx))
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch followed users from db")
+		return
+	}
+
+	// Quem o usuário bloqueou ou silenciou some do feed
+	hidden, err := env.relationRepository.GetRelatedIDs(ctx.Request.Context(), authenticatedUserID(ctx), models.RelationBlock, models.RelationMute)
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch blocked and muted users from db")
+		return
+	}
 
-		users = append(users, user)
+	page, err := env.feed.PersonalPage(ctx.Request.Context(), req, following, hidden, time.Now())
+	if err != nil {
+		respondWithError(ctx, err, "failed to get livestream feed")
+		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"livestreams": livestreams, "users": users})
+	env.respondWithFeed(ctx, page)
 }
 
-func (env *ServerEnv) getAllStreams(ctx *gin.Context) {
-	livestreams, err := env.liveStreamsRepository.GetAllLiveStreams()
+// Responde com as lives da página, cada uma junto do seu publisher.
+func (env *ServerEnv) respondWithFeed(ctx *gin.Context, page *models.Page[*ranking.Ranked]) {
+	liveStreams := make([]*models.LiveStream, 0, len(page.Items))
+	for _, ranked := range page.Items {
+		liveStreams = append(liveStreams, ranked.LiveStream)
+	}
+
+	// Os publishers da página inteira são buscados de uma só vez
+	publishers, err := env.userRepository.GetUsersByIds(ctx.Request.Context(), models.PublisherIDs(liveStreams))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to get livestreams"})
+		respondWithError(ctx, err, "failed to get livestream feed")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"livestreams": livestreams})
+	entries := models.JoinPublishers(liveStreams, publishers)
+
+	for i, ranked := range page.Items {
+		entries[i].Following = ranked.Section == ranking.SectionFollowing
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"entries": entries, "next_cursor": page.NextCursor, "prev_cursor": page.PrevCursor})
 }
 
+// Chamado pelo nginx-rtmp (`on_publish`) quando alguém começa a transmitir.
+// A chave de stream (`name`) é a única credencial necessária, e o endereço
+// do broadcaster (`addr`) precisa estar na lista de IPs autorizados da live,
+// caso ela exista. Com a transmissão aceita, a live passa a estar ativa.
 func (env *ServerEnv) validateStream(ctx *gin.Context) {
 	streamKey := ctx.Query("name")
+	if streamKey == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "missing stream key"})
+		return
+	}
+
+	ls, err := env.liveStreamsRepository.GetLiveStreamByStreamKey(ctx.Request.Context(), streamKey)
+	if errors.Is(err, repository.ErrNotFound) {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": "invalid stream key"})
+		return
+	}
+	if err != nil {
+		respondWithError(ctx, err, "failed to validate stream key")
+		return
+	}
+
+	if !ls.AllowsAddress(ctx.Query("addr")) {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": "address not allowed to publish to this stream"})
+		return
+	}
+
+	// A live passa a estar ativa, e guardamos o cliente que está
+	// transmitindo para que a transmissão possa ser encerrada caso a
+	// chave seja rotacionada
+	newData := bson.M{
+		"live_stream_status":  true,
+		"publisher_client_id": ctx.Query("clientid"),
+	}
+
+	err = env.liveStreamsRepository.UpdateLiveStream(ctx.Request.Context(), ls.ID, newData)
+	if err != nil && !errors.Is(err, repository.ErrNoChange) {
+		log.Printf("failed to mark stream %s as live: %s\n", ls.ID.Hex(), err)
+	} else if !ls.LiveStatus {
+		env.events.Publish(events.StreamLive(ls.ID, ls.PublisherId))
+	}
+
+	// Sessões que não foram encerradas (ex: o nginx caiu antes de
+	// chamar o on_publish_done) são fechadas antes de abrir a nova
+	if err := env.streamSessionRepository.CloseStreamSessions(ctx.Request.Context(), ls.ID); err != nil {
+		log.Printf("failed to close previous sessions for stream %s: %s\n", ls.ID.Hex(), err)
+	}
+
+	encoder := models.EncoderInfo{
+		FlashVersion: ctx.Query("flashver"),
+		SwfURL:       ctx.Query("swfurl"),
+		TcURL:        ctx.Query("tcurl"),
+		PageURL:      ctx.Query("pageurl"),
+	}
+
+	_, err = env.streamSessionRepository.CreateStreamSession(ctx.Request.Context(), ls.ID, ls.PublisherId, ctx.Query("addr"), ctx.Query("clientid"), encoder)
+	if err != nil {
+		log.Printf("failed to open session for stream %s: %s\n", ls.ID.Hex(), err)
+	}
+
+	location := fmt.Sprintf("rtmp://127.0.0.1/hls-live/%s", ls.ID.Hex())
+
+	ctx.Redirect(http.StatusFound, location)
+}
+
+// Chamado pelo nginx-rtmp (`on_publish_done`) quando o broadcaster se
+// desconecta. Se a chave foi rotacionada durante a transmissão, a live
+// já foi encerrada pela rotação e a chave antiga não a encontra mais.
+func (env *ServerEnv) endStream(ctx *gin.Context) {
+	streamKey := ctx.Query("name")
 
-	// Obter os parâmetros (gambiarra)
-	tcurl := ctx.Query("swfurl")
-	url, err := url.Parse(tcurl)
+	ls, err := env.liveStreamsRepository.GetLiveStreamByStreamKey(ctx.Request.Context(), streamKey)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		respondWithError(ctx, err, "invalid stream key")
 		return
 	}
 
-	username := url.Query().Get("username")
-	password := url.Query().Get("password")
+	if err := env.finishStream(ctx.Request.Context(), ls); err != nil {
+		respondWithError(ctx, err, "failed to end stream")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
 
-	if username == "" || password == "" {
-		fmt.Println("No user and password")
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "missing username/password combination"})
+// Marca a live como offline, zera o contador de espectadores e encerra a
+// sessão de transmissão.
+func (env *ServerEnv) finishStream(ctx context.Context, ls *models.LiveStream) error {
+	newData := bson.M{
+		"live_stream_status":    false,
+		"viewer_count":          0,
+		"last_session_ended_at": time.Now(),
+		"publisher_client_id":   "",
+	}
+
+	err := env.liveStreamsRepository.UpdateLiveStream(ctx, ls.ID, newData)
+	if err != nil {
+		return err
+	}
+
+	if ls.LiveStatus {
+		env.events.Publish(events.StreamOffline(ls.ID, ls.PublisherId))
+	}
+
+	return env.streamSessionRepository.CloseStreamSessions(ctx, ls.ID)
+}
+
+// swagger:route POST /livestreams/rotate_key/{id} livestreams rotateStreamKey
+//
+// Generate a new stream key for the live stream identified by `id`. The previous
+// key stops being accepted immediately and, if requested, the active publish is terminated.
+// A live stream being broadcast goes offline and its session is closed; the broadcaster
+// must reconnect with the new key. Only the publisher of the live stream can perform this
+// operation.
+//
+// Responses:
+//
+//	200: streamKeyResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) rotateStreamKey(ctx *gin.Context) {
+	id := ctx.Param("id")
+	streamID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
 		return
 	}
 
-	user, err := env.userRepository.GetUserByUsername(username)
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
 	if err != nil {
-		fmt.Println("No user")
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid username"})
+		respondWithError(ctx, err, "failed to find stream")
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		fmt.Println("Wrong password")
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "incorrect password"})
+	if !requireOwner(ctx, livestream.PublisherId) {
 		return
 	}
 
-	ls, err := env.liveStreamsRepository.GetLiveStreamByStreamKey(streamKey)
+	var rotateBody RotateStreamKeyBody
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindBodyWithJSON(&rotateBody); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"message": "malformed request"})
+			return
+		}
+	}
+
+	streamKey, err := auth.GenerateStreamKey()
 	if err != nil {
-		fmt.Println("No stream")
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid stream key"})
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate stream key"})
 		return
 	}
 
-	location := fmt.Sprintf("rtmp://127.0.0.1/hls-live/%s", ls.ID.Hex())
+	rotation := models.NewStreamKeyRotation(authenticatedUserID(ctx), livestream.StreamKeyHash, rotateBody.Reason)
+	rotation.TerminatePublish = rotateBody.TerminatePublish
 
-	ctx.Redirect(http.StatusFound, location)
+	// A chave é trocada antes de derrubar a transmissão, para que o
+	// broadcaster não consiga se reconectar com a chave antiga
+	err = env.liveStreamsRepository.RotateLiveStreamKey(ctx.Request.Context(), streamID, streamKey, rotation)
+	if err != nil {
+		respondWithError(ctx, err, "failed to rotate stream key")
+		return
+	}
+
+	terminated := false
+	if rotateBody.TerminatePublish && livestream.PublisherClientID != "" && env.publishController != nil {
+		if err := env.publishController.DropPublisher(ctx.Request.Context(), livestream.PublisherClientID); err != nil {
+			log.Printf("failed to terminate publish for stream %s: %s\n", streamID.Hex(), err)
+		} else {
+			terminated = true
+		}
+	}
+
+	// O `on_publish_done` da transmissão em andamento chega com a chave
+	// antiga, que não encontra mais a live. Ela é encerrada aqui, e o
+	// broadcaster precisa se reconectar com a nova chave
+	if livestream.LiveStatus || livestream.PublisherClientID != "" {
+		if err := env.finishStream(ctx.Request.Context(), livestream); err != nil {
+			log.Printf("failed to end stream %s after key rotation: %s\n", streamID.Hex(), err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"stream_key": streamKey, "terminated_publish": terminated})
+}
+
+// swagger:route GET /livestreams/info/{id}/sessions livestreams getLiveStreamSessions
+//
+// List the broadcast sessions of the live stream identified by `id`, most recent first.
+// Only the publisher of the live stream can perform this operation.
+//
+// Responses:
+//
+//	200: streamSessionsResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getLiveStreamSessions(ctx *gin.Context) {
+	id := ctx.Param("id")
+	streamID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
+		return
+	}
+
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
+	}
+
+	if _, ok := env.requireStreamOwner(ctx, streamID); !ok {
+		return
+	}
+
+	page, err := env.streamSessionRepository.GetStreamSessionsByLiveStream(ctx.Request.Context(), streamID, req)
+	if err != nil {
+		respondWithError(ctx, err, "failed to get stream sessions")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"sessions": page.Items, "next_cursor": page.NextCursor, "prev_cursor": page.PrevCursor})
 }
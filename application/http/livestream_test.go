@@ -1,10 +1,15 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/infra/repository"
 	"github.com/gtvb/livestream/models"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,7 +17,7 @@ import (
 )
 
 func createTestUser(env ServerEnv) *models.User {
-	id, _ := env.userRepository.CreateUser("test_username", "test@email.com", hashPassword("test_pass"))
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
 	userID := id.(primitive.ObjectID)
 
 	return &models.User{
@@ -24,10 +29,7 @@ func createTestUser(env ServerEnv) *models.User {
 }
 
 // func TestCreateLiveStream(t *testing.T) {
-// 	container := setupDatabase()
-// 	defer container.Terminate()
-
-// 	env := setupEnv(container.Database)
+// 	env := setupEnv()
 // 	router := setupRouter(env)
 // 	user := createTestUser(env)
 
@@ -57,48 +59,62 @@ func createTestUser(env ServerEnv) *models.User {
 // }
 
 func TestDeleteLiveStream(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 	router := setupRouter(env)
 
 	user := createTestUser(env)
+	token := generateTestToken(env, user.ID)
 
 	t.Run("Successfully create stream", func(t *testing.T) {
-		streamID, err := env.liveStreamsRepository.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+		streamID, err := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
 		if err != nil {
 			t.Fatalf("Failed to create test live stream: %v", err)
 		}
 		id := streamID.(primitive.ObjectID)
 
-		writer := makeRequest(router, "DELETE", "/livestreams/delete/"+id.Hex(), nil)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/livestreams/delete/"+id.Hex(), nil, token)
 		assert.Equal(t, http.StatusOK, writer.Code)
 		assert.Contains(t, writer.Body.String(), "success")
+
+		writer = makeAuthenticatedRequest(router, "DELETE", "/livestreams/delete/"+id.Hex(), nil, token)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
 	})
 
 	t.Run("Invalid ID", func(t *testing.T) {
-		writer := makeRequest(router, "DELETE", "/livestreams/delete/invalidID", nil)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/livestreams/delete/invalidID", nil, token)
 		assert.Equal(t, http.StatusBadRequest, writer.Code)
 		assert.Contains(t, writer.Body.String(), "invalid ID")
 	})
 
 	t.Run("Stream not found", func(t *testing.T) {
-		writer := makeRequest(router, "DELETE", "/livestreams/delete/"+primitive.NewObjectID().Hex(), nil)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/livestreams/delete/"+primitive.NewObjectID().Hex(), nil, token)
 		assert.Equal(t, http.StatusNotFound, writer.Code)
-		assert.Contains(t, writer.Body.String(), "failed to delete stream")
+		assert.Contains(t, writer.Body.String(), "failed to find stream")
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		writer := makeRequest(router, "DELETE", "/livestreams/delete/"+primitive.NewObjectID().Hex(), nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Not the publisher", func(t *testing.T) {
+		streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-other", user.ID)
+		id := streamID.(primitive.ObjectID)
+
+		otherToken := generateTestToken(env, primitive.NewObjectID())
+		writer := makeAuthenticatedRequest(router, "DELETE", "/livestreams/delete/"+id.Hex(), nil, otherToken)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
 	})
 }
 
 func TestUpdateLiveStream(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 	router := setupRouter(env)
 	user := createTestUser(env)
 
-	streamID, _ := env.liveStreamsRepository.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	token := generateTestToken(env, user.ID)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
 	id := streamID.(primitive.ObjectID)
 
 	t.Run("Correct body", func(t *testing.T) {
@@ -106,33 +122,36 @@ func TestUpdateLiveStream(t *testing.T) {
 			Name: "Updated Stream Name",
 		}
 
-		writer := makeRequest(router, "PATCH", "/livestreams/update/"+id.Hex(), updateLiveStreamBody)
+		writer := makeAuthenticatedRequest(router, "PATCH", "/livestreams/update/"+id.Hex(), updateLiveStreamBody, token)
 		assert.Equal(t, http.StatusOK, writer.Code)
 		assert.Contains(t, writer.Body.String(), "success")
 	})
 
 	t.Run("Invalid ID", func(t *testing.T) {
-		writer := makeRequest(router, "PATCH", "/livestreams/update/invalidID", nil)
+		writer := makeAuthenticatedRequest(router, "PATCH", "/livestreams/update/invalidID", nil, token)
 		assert.Equal(t, http.StatusBadRequest, writer.Code)
 		assert.Contains(t, writer.Body.String(), "unparseable ID")
 	})
 
 	t.Run("Unexistant ID", func(t *testing.T) {
-		writer := makeRequest(router, "PATCH", "/livestreams/update/"+primitive.NewObjectID().Hex(), nil)
-		assert.Equal(t, http.StatusBadRequest, writer.Code)
-		assert.Contains(t, writer.Body.String(), "failed to update stream")
+		writer := makeAuthenticatedRequest(router, "PATCH", "/livestreams/update/"+primitive.NewObjectID().Hex(), nil, token)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+		assert.Contains(t, writer.Body.String(), "failed to find stream")
+	})
+
+	t.Run("Not the publisher", func(t *testing.T) {
+		otherToken := generateTestToken(env, primitive.NewObjectID())
+		writer := makeAuthenticatedRequest(router, "PATCH", "/livestreams/update/"+id.Hex(), UpdateLiveStreamBody{Name: "Hijacked"}, otherToken)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
 	})
 }
 
 func TestGetLiveStreamData(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 	router := setupRouter(env)
 
 	user := createTestUser(env)
-	streamID, _ := env.liveStreamsRepository.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
 
 	t.Run("Correct ID", func(t *testing.T) {
 		writer := makeRequest(router, "GET", "/livestreams/info/"+streamID.(primitive.ObjectID).Hex(), nil)
@@ -154,37 +173,421 @@ func TestGetLiveStreamData(t *testing.T) {
 }
 
 func TestGetLiveStreamFeed(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 	router := setupRouter(env)
 	user := createTestUser(env)
 
 	// Create some live streams
 	for i := 0; i < 5; i++ {
-		streamID, _ := env.liveStreamsRepository.CreateLiveStream("Test Stream "+strconv.Itoa(i), "fake-thumbnail", "streamkey-test"+strconv.Itoa(i), user.ID)
+		streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream "+strconv.Itoa(i), "fake-thumbnail", "streamkey-test"+strconv.Itoa(i), user.ID)
 		id := streamID.(primitive.ObjectID)
 
 		newData := bson.M{"live_stream_status": true}
-		env.liveStreamsRepository.UpdateLiveStream(id, newData)
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, newData)
 	}
 
 	t.Run("DefaultNumStreams", func(t *testing.T) {
 		writer := makeRequest(router, "GET", "/livestreams/feed", nil)
 		assert.Equal(t, http.StatusOK, writer.Code)
 		assert.Contains(t, writer.Body.String(), "Test Stream")
+		assert.Contains(t, writer.Body.String(), "test_username")
+		assertNoPasswordHash(t, writer.Body.String())
 	})
 
-	t.Run("CustomNumStreams", func(t *testing.T) {
-		writer := makeRequest(router, "GET", "/livestreams/feed?q=3", nil)
+	t.Run("Pages", func(t *testing.T) {
+		var first struct {
+			Entries    []models.FeedEntry `json:"entries"`
+			NextCursor string             `json:"next_cursor"`
+		}
+		writer := makeRequest(router, "GET", "/livestreams/feed?limit=3", nil)
 		assert.Equal(t, http.StatusOK, writer.Code)
-		assert.Contains(t, writer.Body.String(), "Test Stream")
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &first))
+		assert.Len(t, first.Entries, 3)
+		assert.NotEmpty(t, first.NextCursor)
+
+		var second struct {
+			Entries    []models.FeedEntry `json:"entries"`
+			NextCursor string             `json:"next_cursor"`
+			PrevCursor string             `json:"prev_cursor"`
+		}
+		writer = makeRequest(router, "GET", "/livestreams/feed?limit=3&cursor="+first.NextCursor, nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &second))
+		assert.Len(t, second.Entries, 2)
+		assert.Empty(t, second.NextCursor)
+		assert.NotEmpty(t, second.PrevCursor)
+	})
+
+	t.Run("EmbeddedPublisher", func(t *testing.T) {
+		var response struct {
+			Entries []models.FeedEntry `json:"entries"`
+		}
+		writer := makeRequest(router, "GET", "/livestreams/feed", nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+
+		for _, entry := range response.Entries {
+			if assert.NotNil(t, entry.Publisher) {
+				assert.Equal(t, entry.LiveStream.PublisherId, entry.Publisher.ID)
+				assert.Equal(t, "test_username", entry.Publisher.Username)
+			}
+		}
+	})
+
+	t.Run("DeletedPublisher", func(t *testing.T) {
+		// Uma live ativa cujo publisher foi removido não derruba o feed
+		orphanID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Orphan Stream", "fake-thumbnail", "streamkey-orphan", primitive.NewObjectID())
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), orphanID.(primitive.ObjectID), bson.M{"live_stream_status": true})
+
+		var response struct {
+			Entries []models.FeedEntry `json:"entries"`
+		}
+		writer := makeRequest(router, "GET", "/livestreams/feed", nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+		assert.Len(t, response.Entries, 6)
+
+		for _, entry := range response.Entries {
+			if entry.LiveStream.ID == orphanID {
+				assert.Nil(t, entry.Publisher)
+			} else {
+				assert.NotNil(t, entry.Publisher)
+			}
+		}
+	})
+
+	t.Run("InvalidLimit", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/feed?limit=invalid", nil)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+		assert.Contains(t, writer.Body.String(), "limit needs to be an integer between 1 and 100")
 	})
 
-	t.Run("InvalidQueryParam", func(t *testing.T) {
-		writer := makeRequest(router, "GET", "/livestreams/feed?q=invalid", nil)
+	t.Run("InvalidCursor", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/feed?cursor=invalid", nil)
 		assert.Equal(t, http.StatusBadRequest, writer.Code)
-		assert.Contains(t, writer.Body.String(), "q needs to be an integer")
+		assert.Contains(t, writer.Body.String(), "invalid cursor")
+	})
+}
+
+func TestGetPersonalFeed(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	viewer := createTestUser(env)
+
+	followedID, _ := env.userRepository.CreateUser(context.Background(), "followed", "followed@email.com", hashPassword("test_pass"))
+	otherID, _ := env.userRepository.CreateUser(context.Background(), "other", "other@email.com", hashPassword("test_pass"))
+	followTestUser(env, viewer.ID, followedID.(primitive.ObjectID))
+
+	// A live seguida tem menos espectadores, mas vem primeiro
+	streams := map[string]primitive.ObjectID{"followed": followedID.(primitive.ObjectID), "other": otherID.(primitive.ObjectID)}
+	viewers := map[string]int{"followed": 1, "other": 50}
+	for name, publisherID := range streams {
+		streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), name+" stream", "fake-thumbnail", "streamkey-"+name, publisherID)
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), streamID.(primitive.ObjectID), bson.M{"live_stream_status": true, "viewer_count": viewers[name]})
+	}
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/feed/personal", nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Following first", func(t *testing.T) {
+		var response struct {
+			Entries []models.FeedEntry `json:"entries"`
+		}
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/feed/personal", nil, generateTestToken(env, viewer.ID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+
+		if assert.Len(t, response.Entries, 2) {
+			assert.Equal(t, "followed stream", response.Entries[0].LiveStream.Name)
+			assert.True(t, response.Entries[0].Following)
+			assert.Equal(t, "other stream", response.Entries[1].LiveStream.Name)
+			assert.False(t, response.Entries[1].Following)
+		}
+	})
+
+	t.Run("Pages across sections", func(t *testing.T) {
+		var first struct {
+			Entries    []models.FeedEntry `json:"entries"`
+			NextCursor string             `json:"next_cursor"`
+		}
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/feed/personal?limit=1", nil, generateTestToken(env, viewer.ID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &first))
+		assert.Len(t, first.Entries, 1)
+		assert.NotEmpty(t, first.NextCursor)
+
+		writer = makeAuthenticatedRequest(router, "GET", "/livestreams/feed/personal?limit=1&cursor="+first.NextCursor, nil, generateTestToken(env, viewer.ID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "other stream")
+		assert.Contains(t, writer.Body.String(), `"next_cursor":""`)
+	})
+}
+
+func TestEndStream(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"live_stream_status": true})
+	env.liveStreamsRepository.IncrementLiveStreamUserCount(context.Background(), id)
+
+	t.Run("Known stream key", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_publish_done?name=streamkey-test", nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.False(t, ls.LiveStatus)
+		assert.Equal(t, 0, ls.ViewerCount)
+		assert.NotNil(t, ls.LastSessionEndedAt)
+	})
+
+	t.Run("Unknown stream key", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_publish_done?name=unknown", nil)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+	})
+}
+
+func TestPublishEvents(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	sub, _, _ := env.events.Subscribe(events.ForLiveStream(id), 0)
+	defer sub.Close()
+
+	writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&clientid=1", nil)
+	assert.Equal(t, http.StatusFound, writer.Code)
+
+	ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+	assert.True(t, ls.LiveStatus)
+
+	// Uma reconexão com a live já ativa não é anunciada de novo
+	writer = makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&clientid=2", nil)
+	assert.Equal(t, http.StatusFound, writer.Code)
+
+	writer = makeRequest(router, "GET", "/livestreams/on_publish_done?name=streamkey-test", nil)
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	var types []events.Type
+	for len(types) < 2 {
+		select {
+		case event := <-sub.Events():
+			types = append(types, event.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("expected 2 events, got %v", types)
+		}
+	}
+	assert.Equal(t, []events.Type{events.TypeStreamLive, events.TypeStreamOffline}, types)
+
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("unexpected event %s", event.Type)
+	default:
+	}
+}
+
+func TestValidateStream(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	t.Run("Valid stream key", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&addr=192.168.0.10", nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+		assert.Equal(t, "rtmp://127.0.0.1/hls-live/"+id.Hex(), writer.Header().Get("Location"))
+	})
+
+	t.Run("Invalid stream key", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_publish?name=wrong-key&addr=192.168.0.10", nil)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+	})
+
+	t.Run("Address allowlist", func(t *testing.T) {
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"allowed_ips": []string{"10.0.0.0/8", "192.168.0.20"}})
+
+		writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&addr=192.168.0.10", nil)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&addr=192.168.0.20", nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&addr=10.1.2.3", nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+	})
+
+	t.Run("Stream key is never serialized", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/info/"+id.Hex(), nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.NotContains(t, writer.Body.String(), "stream_key")
+	})
+
+	t.Run("Allowlist is only shown to the publisher", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/info/"+id.Hex(), nil)
+		assert.NotContains(t, writer.Body.String(), "allowed_ips")
+		assert.NotContains(t, writer.Body.String(), "10.0.0.0/8")
+
+		writer = makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/settings", nil, generateTestToken(env, user.ID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), `"allowed_ips":["10.0.0.0/8","192.168.0.20"]`)
+
+		writer = makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/settings", nil, generateTestToken(env, primitive.NewObjectID()))
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+
+		writer = makeRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/settings", nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+}
+
+type fakePublishController struct {
+	dropped []string
+}
+
+func (fc *fakePublishController) DropPublisher(ctx context.Context, clientID string) error {
+	fc.dropped = append(fc.dropped, clientID)
+	return nil
+}
+
+func TestRotateStreamKey(t *testing.T) {
+	env := setupEnv()
+	controller := &fakePublishController{}
+	env.publishController = controller
+
+	router := setupRouter(env)
+	user := createTestUser(env)
+	token := generateTestToken(env, user.ID)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	// Simula uma transmissão ativa
+	writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&clientid=7", nil)
+	assert.Equal(t, http.StatusFound, writer.Code)
+
+	t.Run("Not the publisher", func(t *testing.T) {
+		otherToken := generateTestToken(env, primitive.NewObjectID())
+		writer := makeAuthenticatedRequest(router, "POST", "/livestreams/rotate_key/"+id.Hex(), nil, otherToken)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+	})
+
+	var rotatedKey string
+	t.Run("Rotate and terminate", func(t *testing.T) {
+		body := RotateStreamKeyBody{Reason: "leaked on stream", TerminatePublish: true}
+		writer := makeAuthenticatedRequest(router, "POST", "/livestreams/rotate_key/"+id.Hex(), body, token)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		var response struct {
+			StreamKey         string `json:"stream_key"`
+			TerminatedPublish bool   `json:"terminated_publish"`
+		}
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.StreamKey)
+		assert.True(t, response.TerminatedPublish)
+		rotatedKey = response.StreamKey
+		assert.Equal(t, []string{"7"}, controller.dropped)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test", nil)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_publish?name="+response.StreamKey, nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.Len(t, ls.StreamKeyRotations, 1)
+		assert.Equal(t, "leaked on stream", ls.StreamKeyRotations[0].Reason)
+		assert.Equal(t, user.ID, ls.StreamKeyRotations[0].RotatedBy)
+	})
+
+	t.Run("Rotations are only shown to the publisher", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/info/"+id.Hex(), nil)
+		assert.NotContains(t, writer.Body.String(), "stream_key_rotations")
+		assert.NotContains(t, writer.Body.String(), "leaked on stream")
+
+		writer = makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/settings", nil, token)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "leaked on stream")
+	})
+
+	t.Run("Rotation ends the stream", func(t *testing.T) {
+		streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Other Stream", "fake-thumbnail", "streamkey-done", user.ID)
+		doneID := streamID.(primitive.ObjectID)
+
+		writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-done&clientid=8", nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+
+		writer = makeAuthenticatedRequest(router, "POST", "/livestreams/rotate_key/"+doneID.Hex(), RotateStreamKeyBody{Reason: "routine"}, token)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), doneID)
+		assert.False(t, ls.LiveStatus)
+		assert.Empty(t, ls.PublisherClientID)
+
+		_, err := env.streamSessionRepository.GetOpenStreamSession(context.Background(), doneID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// O `on_publish_done` com a chave antiga não encontra mais a live
+		writer = makeRequest(router, "GET", "/livestreams/on_publish_done?name=streamkey-done&clientid=8", nil)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+	})
+
+	t.Run("Client id alone does not end a stream", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_publish?name="+rotatedKey+"&clientid=9", nil)
+		assert.Equal(t, http.StatusFound, writer.Code)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_publish_done?name=unknown&clientid=9", nil)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.True(t, ls.LiveStatus)
+	})
+}
+
+func TestLiveStreamSessions(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+	token := generateTestToken(env, user.ID)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	writer := makeRequest(router, "GET", "/livestreams/on_publish?name=streamkey-test&addr=10.0.0.1&clientid=3&flashver=FMLE/3.0", nil)
+	assert.Equal(t, http.StatusFound, writer.Code)
+
+	writer = makeRequest(router, "GET", "/livestreams/on_publish_done?name=streamkey-test", nil)
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	t.Run("Publisher", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/sessions?limit=5", nil, token)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "FMLE/3.0")
+		assert.Contains(t, writer.Body.String(), "10.0.0.1")
+		assert.Contains(t, writer.Body.String(), `"next_cursor":""`)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/sessions?cursor=not-a-cursor", nil, token)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+
+	t.Run("Invalid pagination", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/sessions?limit=0", nil, token)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+
+	t.Run("Not the publisher", func(t *testing.T) {
+		otherToken := generateTestToken(env, primitive.NewObjectID())
+		writer := makeAuthenticatedRequest(router, "GET", "/livestreams/info/"+id.Hex()+"/sessions", nil, otherToken)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
 	})
 }
@@ -0,0 +1,127 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chave do contexto do gin onde o id do usuário autenticado é guardado.
+const authUserIDKey = "auth_user_id"
+
+// Valida o token enviado no cabeçalho `Authorization: Bearer <token>`
+// e guarda o id do usuário no contexto da requisição. Requisições sem
+// token ou com token inválido são abortadas com 401.
+func (env *ServerEnv) authMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "missing bearer token"})
+			return
+		}
+
+		claims, err := env.tokenManager.VerifyAccessToken(token)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid or expired token"})
+			return
+		}
+
+		userID, _ := claims.UserID()
+		ctx.Set(authUserIDKey, userID)
+
+		ctx.Next()
+	}
+}
+
+// Como o `authMiddleware`, mas deixa passar requisições sem o cabeçalho
+// `Authorization`, que seguem como anônimas. Um token enviado ainda
+// precisa ser válido.
+func (env *ServerEnv) optionalAuthMiddleware() gin.HandlerFunc {
+	authenticate := env.authMiddleware()
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader("Authorization") == "" {
+			ctx.Next()
+			return
+		}
+
+		authenticate(ctx)
+	}
+}
+
+// Retorna o id do usuário autenticado pelo `authMiddleware`. Em
+// requisições anônimas o id é vazio.
+func authenticatedUserID(ctx *gin.Context) primitive.ObjectID {
+	id, _ := ctx.Get(authUserIDKey)
+	userID, _ := id.(primitive.ObjectID)
+	return userID
+}
+
+// Restringe a rota aos administradores (`ADMIN_USER_IDS`), respondendo
+// com 403 aos demais. Deve vir depois do `authMiddleware`.
+func (env *ServerEnv) adminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !env.adminIDs[authenticatedUserID(ctx)] {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "admin access required"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// Garante que o usuário autenticado é o dono do recurso (`ownerID`),
+// respondendo com 403 caso contrário.
+func requireOwner(ctx *gin.Context, ownerID primitive.ObjectID) bool {
+	if authenticatedUserID(ctx) != ownerID {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": "you are not allowed to modify this resource"})
+		return false
+	}
+
+	return true
+}
+
+// Garante que o usuário autenticado é o publisher da live `streamID`,
+// respondendo com 404 caso ela não exista ou 403 caso pertença a outro
+// usuário. Retorna a live lida.
+func (env *ServerEnv) requireStreamOwner(ctx *gin.Context, streamID primitive.ObjectID) (*models.LiveStream, bool) {
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+	if err != nil {
+		respondWithError(ctx, err, "failed to find stream")
+		return nil, false
+	}
+
+	return livestream, requireOwner(ctx, livestream.PublisherId)
+}
+
+// Garante que o usuário autenticado não foi bloqueado pelo publisher
+// `publisherID`, respondendo com 403 caso contrário. Requisições anônimas
+// passam.
+func (env *ServerEnv) requireNotBlockedBy(ctx *gin.Context, publisherID primitive.ObjectID) bool {
+	return env.requireViewerNotBlockedBy(ctx, publisherID, authenticatedUserID(ctx))
+}
+
+// Como o `requireNotBlockedBy`, mas para o usuário `viewerID`, como o
+// dono de um token de espectador. Um id vazio (um anônimo) passa: sem
+// login, não há como saber se é alguém bloqueado.
+func (env *ServerEnv) requireViewerNotBlockedBy(ctx *gin.Context, publisherID, viewerID primitive.ObjectID) bool {
+	if viewerID.IsZero() {
+		return true
+	}
+
+	blocked, err := env.relationRepository.HasRelation(ctx.Request.Context(), publisherID, viewerID, models.RelationBlock)
+	if err != nil {
+		respondWithError(ctx, err, "could not check if the user is blocked")
+		return false
+	}
+
+	if blocked {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": "you were blocked by this streamer"})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	env := ServerEnv{tokenManager: auth.NewTokenManager("test-secret", time.Hour, time.Hour)}
+
+	router := gin.New()
+	router.GET("/protected", env.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"user_id": authenticatedUserID(ctx)})
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/protected", nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		assert.Contains(t, writer.Body.String(), "missing bearer token")
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/protected", nil, "not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		assert.Contains(t, writer.Body.String(), "invalid or expired token")
+	})
+
+	t.Run("Valid token", func(t *testing.T) {
+		userID := primitive.NewObjectID()
+		writer := makeAuthenticatedRequest(router, "GET", "/protected", nil, generateTestToken(env, userID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), userID.Hex())
+	})
+}
+
+func TestAdminMiddleware(t *testing.T) {
+	adminID := primitive.NewObjectID()
+	env := ServerEnv{
+		tokenManager: auth.NewTokenManager("test-secret", time.Hour, time.Hour),
+		adminIDs:     map[primitive.ObjectID]bool{adminID: true},
+	}
+
+	router := gin.New()
+	router.GET("/admin", env.authMiddleware(), env.adminMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	t.Run("Not an admin", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/admin", nil, generateTestToken(env, primitive.NewObjectID()))
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+		assert.Contains(t, writer.Body.String(), "admin access required")
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/admin", nil, generateTestToken(env, adminID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+	})
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Prazo para restaurar um usuário removido, antes que ele seja
+	// apagado definitivamente
+	defaultRestoreWindow = 30 * 24 * time.Hour
+	defaultPurgePeriod   = time.Hour
+)
+
+// O que foi apagado definitivamente junto com um usuário.
+type purgeReport struct {
+	LiveStreams      int64
+	FollowReferences int64
+	Relations        int64
+	StreamSessions   int64
+	RefreshTokens    int64
+	Thumbnails       int
+}
+
+// Periodicamente apaga os usuários e lives removidos há mais tempo que
+// a janela de restauração.
+func (env *ServerEnv) runPurger(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		env.purgeDeleted(context.Background(), time.Now().Add(-env.restoreWindow))
+	}
+}
+
+// Apaga definitivamente os usuários e lives removidos antes de `before`.
+// Falhas são registradas e o item é tentado de novo na próxima execução.
+func (env *ServerEnv) purgeDeleted(ctx context.Context, before time.Time) {
+	// Lida antes dos usuários, já que a remoção deles apaga as lives
+	// junto e as thumbnails seriam perdidas
+	liveStreams, err := env.liveStreamsRepository.GetLiveStreamsDeletedBefore(ctx, before)
+	if err != nil {
+		log.Printf("purger: failed to get deleted live streams: %s\n", err)
+		return
+	}
+
+	byPublisher := make(map[primitive.ObjectID][]*models.LiveStream)
+	for _, ls := range liveStreams {
+		byPublisher[ls.PublisherId] = append(byPublisher[ls.PublisherId], ls)
+	}
+
+	users, err := env.userRepository.GetUsersDeletedBefore(ctx, before)
+	if err != nil {
+		log.Printf("purger: failed to get deleted users: %s\n", err)
+		return
+	}
+
+	for _, user := range users {
+		report, err := env.purgeUser(ctx, user.ID, byPublisher[user.ID])
+		if err != nil {
+			log.Printf("purger: failed to purge user %s: %s\n", user.ID.Hex(), err)
+			continue
+		}
+
+		log.Printf("purger: purged user %s: %+v\n", user.ID.Hex(), *report)
+		delete(byPublisher, user.ID)
+	}
+
+	var thumbnails []string
+	for _, publisherStreams := range byPublisher {
+		for _, ls := range publisherStreams {
+			if err := env.liveStreamsRepository.PurgeLiveStream(ctx, ls.ID); err != nil {
+				log.Printf("purger: failed to purge live stream %s: %s\n", ls.ID.Hex(), err)
+				continue
+			}
+			thumbnails = append(thumbnails, ls.Thumbnail)
+		}
+	}
+
+	env.removeThumbnails(ctx, thumbnails)
+}
+
+// Apaga o usuário e tudo que o referencia em uma única unidade de
+// trabalho. As thumbnails de `liveStreams` só são apagadas depois que a
+// transação é confirmada, já que o disco não participa dela.
+func (env *ServerEnv) purgeUser(ctx context.Context, userID primitive.ObjectID, liveStreams []*models.LiveStream) (*purgeReport, error) {
+	var report purgeReport
+
+	err := env.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		// A transação pode ser repetida, então nada é acumulado entre tentativas
+		report = purgeReport{}
+
+		var err error
+		if report.LiveStreams, err = env.liveStreamsRepository.PurgeLiveStreamsByPublisher(ctx, userID); err != nil {
+			return err
+		}
+
+		if report.StreamSessions, err = env.streamSessionRepository.DeleteStreamSessionsByPublisher(ctx, userID); err != nil {
+			return err
+		}
+
+		if report.RefreshTokens, err = env.refreshTokenRepository.DeleteAllUserRefreshTokens(ctx, userID); err != nil {
+			return err
+		}
+
+		follows, err := env.followRepository.DeleteUserFollows(ctx, userID)
+		if err != nil {
+			return err
+		}
+		report.FollowReferences = int64(len(follows))
+
+		// Quem estava do outro lado de cada follow é descontado
+		for _, follow := range follows {
+			if follow.FollowerID == userID {
+				err = env.userRepository.AddFollowCounts(ctx, follow.FolloweeID, -1, 0)
+			} else {
+				err = env.userRepository.AddFollowCounts(ctx, follow.FollowerID, 0, -1)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if report.Relations, err = env.relationRepository.DeleteUserRelations(ctx, userID); err != nil {
+			return err
+		}
+
+		return env.userRepository.PurgeUser(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnails := make([]string, 0, len(liveStreams))
+	for _, ls := range liveStreams {
+		thumbnails = append(thumbnails, ls.Thumbnail)
+	}
+
+	report.Thumbnails = env.removeThumbnails(ctx, thumbnails)
+	return &report, nil
+}
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// swagger:route PATCH /users/block/{user_id} users blockUser
+//
+// Makes the authenticated user block `user_id`. Follows between the two users are
+// removed in both directions, neither of them can follow the other while the block
+// lasts, and the blocked user can no longer watch the authenticated user's live streams.
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: messageResponse
+//	401: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) blockUser(ctx *gin.Context) {
+	targetID, ok := env.parseRelationTarget(ctx)
+	if !ok {
+		return
+	}
+
+	userID := authenticatedUserID(ctx)
+
+	// O bloqueio e a remoção dos follows, junto com os contadores, mudam juntos
+	err := env.unitOfWork.Do(ctx.Request.Context(), func(txCtx context.Context) error {
+		if err := env.relationRepository.AddRelation(txCtx, userID, targetID, models.RelationBlock); err != nil {
+			return err
+		}
+
+		for _, follow := range []models.Follow{{FollowerID: userID, FolloweeID: targetID}, {FollowerID: targetID, FolloweeID: userID}} {
+			err := env.unfollow(txCtx, follow.FollowerID, follow.FolloweeID)
+			if err != nil && !errors.Is(err, repository.ErrNoChange) {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		respondWithError(ctx, err, "could not block this user")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// swagger:route PATCH /users/unblock/{user_id} users unblockUser
+//
+// Makes the authenticated user unblock `user_id`. Removed follows are not restored.
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) unblockUser(ctx *gin.Context) {
+	env.removeRelation(ctx, models.RelationBlock, "could not unblock this user")
+}
+
+// swagger:route PATCH /users/mute/{user_id} users muteUser
+//
+// Makes the authenticated user mute `user_id`, hiding their live streams from the
+// authenticated user's personal feed. Follows are kept.
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: messageResponse
+//	401: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) muteUser(ctx *gin.Context) {
+	targetID, ok := env.parseRelationTarget(ctx)
+	if !ok {
+		return
+	}
+
+	if err := env.relationRepository.AddRelation(ctx.Request.Context(), authenticatedUserID(ctx), targetID, models.RelationMute); err != nil {
+		respondWithError(ctx, err, "could not mute this user")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// swagger:route PATCH /users/unmute/{user_id} users unmuteUser
+//
+// Makes the authenticated user unmute `user_id`.
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) unmuteUser(ctx *gin.Context) {
+	env.removeRelation(ctx, models.RelationMute, "could not unmute this user")
+}
+
+// swagger:route GET /users/blocked users getBlockedUsers
+//
+// Get a page of the users blocked by the authenticated user.
+//
+// Responses:
+//
+//	200: followListResponse
+//	400: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getBlockedUsers(ctx *gin.Context) {
+	env.listRelations(ctx, models.RelationBlock)
+}
+
+// swagger:route GET /users/muted users getMutedUsers
+//
+// Get a page of the users muted by the authenticated user.
+//
+// Responses:
+//
+//	200: followListResponse
+//	400: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) getMutedUsers(ctx *gin.Context) {
+	env.listRelations(ctx, models.RelationMute)
+}
+
+// Lê o usuário `user_id` dos parâmetros, que precisa existir, respondendo
+// com 400 caso o id seja inválido ou 404 caso ele não exista.
+func (env *ServerEnv) parseRelationTarget(ctx *gin.Context) (primitive.ObjectID, bool) {
+	targetID, err := primitive.ObjectIDFromHex(ctx.Param("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return primitive.NilObjectID, false
+	}
+
+	if _, err := env.userRepository.GetUserById(ctx.Request.Context(), targetID); err != nil {
+		respondWithError(ctx, err, "could not fetch user from db")
+		return primitive.NilObjectID, false
+	}
+
+	return targetID, true
+}
+
+// Desfaz a relação `kind` do usuário autenticado com `user_id`. O outro
+// usuário não precisa mais existir.
+func (env *ServerEnv) removeRelation(ctx *gin.Context, kind models.RelationKind, message string) {
+	targetID, err := primitive.ObjectIDFromHex(ctx.Param("user_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	if err := env.relationRepository.RemoveRelation(ctx.Request.Context(), authenticatedUserID(ctx), targetID, kind); err != nil {
+		respondWithError(ctx, err, message)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// Responde com os perfis públicos da página de relações `kind` do
+// usuário autenticado.
+func (env *ServerEnv) listRelations(ctx *gin.Context, kind models.RelationKind) {
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
+	}
+
+	page, err := env.relationRepository.GetRelations(ctx.Request.Context(), authenticatedUserID(ctx), kind, req)
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch relations from db")
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(page.Items))
+	for _, relation := range page.Items {
+		ids = append(ids, relation.TargetID)
+	}
+
+	env.respondWithProfiles(ctx, ids, page.NextCursor, page.PrevCursor)
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBlockUser(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+
+	id1, _ := env.userRepository.CreateUser(context.Background(), "test_username1", "test1@email.com", hashPassword("test1"))
+	id2, _ := env.userRepository.CreateUser(context.Background(), "test_username2", "test2@email.com", hashPassword("test2"))
+	user1ID := id1.(primitive.ObjectID)
+	user2ID := id2.(primitive.ObjectID)
+
+	assert.Equal(t, http.StatusOK, followTestUser(env, user1ID, user2ID))
+	assert.Equal(t, http.StatusOK, followTestUser(env, user2ID, user1ID))
+
+	writer := makeAuthenticatedRequest(router, "PATCH", "/user/block/"+user2ID.Hex(), nil, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	// Os follows dos dois sentidos foram desfeitos junto com os contadores
+	for _, id := range []primitive.ObjectID{user1ID, user2ID} {
+		following, err := env.followRepository.GetFollowingIDs(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Empty(t, following)
+
+		user, err := env.userRepository.GetUserById(context.Background(), id)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, user.FollowerCount)
+		assert.Equal(t, 0, user.FollowingCount)
+	}
+
+	// Nenhum dos dois pode voltar a seguir o outro
+	assert.Equal(t, http.StatusForbidden, followTestUser(env, user1ID, user2ID))
+	assert.Equal(t, http.StatusForbidden, followTestUser(env, user2ID, user1ID))
+
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/block/"+user1ID.Hex(), nil, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "users cannot block or mute themselves")
+
+	var response struct {
+		Users []models.PublicProfile `json:"users"`
+	}
+	writer = makeAuthenticatedRequest(router, "GET", "/user/blocked", nil, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+	if assert.Len(t, response.Users, 1) {
+		assert.Equal(t, user2ID, response.Users[0].ID)
+	}
+
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/unblock/"+user2ID.Hex(), nil, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Body.String(), "success")
+
+	assert.Equal(t, http.StatusOK, followTestUser(env, user2ID, user1ID))
+}
+
+func TestBlockedViewerHeartbeat(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	streamer := createTestUser(env)
+
+	viewerID, _ := env.userRepository.CreateUser(context.Background(), "viewer", "viewer@email.com", hashPassword("test_pass"))
+	viewer := viewerID.(primitive.ObjectID)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", streamer.ID)
+	id := streamID.(primitive.ObjectID)
+	env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"live_stream_status": true})
+
+	// O token é emitido antes do bloqueio, que ainda assim passa a valer
+	writer := makeAuthenticatedRequest(router, "POST", "/livestreams/watch/"+id.Hex(), nil, generateTestToken(env, viewer))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	var response struct {
+		ViewerToken string `json:"viewer_token"`
+	}
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+
+	writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: response.ViewerToken})
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/block/"+viewer.Hex(), nil, generateTestToken(env, streamer.ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: response.ViewerToken})
+	assert.Equal(t, http.StatusForbidden, writer.Code)
+	assert.Contains(t, writer.Body.String(), "you were blocked by this streamer")
+
+	writer = makeAuthenticatedRequest(router, "POST", "/livestreams/watch/"+id.Hex(), nil, generateTestToken(env, viewer))
+	assert.Equal(t, http.StatusForbidden, writer.Code)
+
+	// Espectadores anônimos continuam sendo contados
+	writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: watchTestStream(t, router, id)})
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Body.String(), `"viewer_count":1`)
+}
+
+func TestMuteUser(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	viewer := createTestUser(env)
+
+	mutedID, _ := env.userRepository.CreateUser(context.Background(), "muted", "muted@email.com", hashPassword("test_pass"))
+	muted := mutedID.(primitive.ObjectID)
+	assert.Equal(t, http.StatusOK, followTestUser(env, viewer.ID, muted))
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "muted stream", "fake-thumbnail", "streamkey-muted", muted)
+	env.liveStreamsRepository.UpdateLiveStream(context.Background(), streamID.(primitive.ObjectID), bson.M{"live_stream_status": true})
+
+	writer := makeAuthenticatedRequest(router, "PATCH", "/user/mute/"+muted.Hex(), nil, generateTestToken(env, viewer.ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/mute/"+muted.Hex(), nil, generateTestToken(env, viewer.ID))
+	assert.Contains(t, writer.Body.String(), "nothing to update")
+
+	// Silenciar não desfaz o follow, mas tira as lives do feed
+	following, err := env.followRepository.GetFollowingIDs(context.Background(), viewer.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{muted}, following)
+
+	var response struct {
+		Entries []models.FeedEntry `json:"entries"`
+	}
+	writer = makeAuthenticatedRequest(router, "GET", "/livestreams/feed/personal", nil, generateTestToken(env, viewer.ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+	assert.Empty(t, response.Entries)
+
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/unmute/"+muted.Hex(), nil, generateTestToken(env, viewer.ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	writer = makeAuthenticatedRequest(router, "GET", "/livestreams/feed/personal", nil, generateTestToken(env, viewer.ID))
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+	assert.Len(t, response.Entries, 1)
+}
@@ -12,15 +12,50 @@
 package http
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/application/ranking"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/gtvb/livestream/infra/rtmp"
 	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// Diretório das thumbnails enviadas, servidas em `/thumbs`
+	uploadsDir = "uploads"
 )
 
 type ServerEnv struct {
-	liveStreamsRepository models.LiveStreamRepositoryInterface
-	userRepository        models.UserRepositoryInterface
+	liveStreamsRepository    models.LiveStreamRepositoryInterface
+	userRepository           models.UserRepositoryInterface
+	refreshTokenRepository   models.RefreshTokenRepositoryInterface
+	streamSessionRepository  models.StreamSessionRepositoryInterface
+	viewerPresenceRepository models.ViewerPresenceRepositoryInterface
+	followRepository         models.FollowRepositoryInterface
+	relationRepository       models.RelationRepositoryInterface
+	unitOfWork               models.UnitOfWork
+
+	tokenManager      *auth.TokenManager
+	publishController rtmp.PublishController
+	feed              *ranking.Feed
+	events            *events.Bus
+
+	viewerHeartbeatTTL time.Duration
+	viewerTokenTTL     time.Duration
+	restoreWindow      time.Duration
+
+	// Usuários que podem acessar as rotas administrativas
+	adminIDs map[primitive.ObjectID]bool
 }
 
 func CORSMiddleware() gin.HandlerFunc {
@@ -28,7 +63,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -46,41 +81,154 @@ func setupRouter(env ServerEnv) *gin.Engine {
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "success"})
 	})
-	router.Static("/thumbs", "./uploads")
+	router.Static("/thumbs", uploadsDir)
+
+	authenticated := env.authMiddleware()
 
 	users := router.Group("/user")
 	users.POST("/login", env.login)
 	users.POST("/signup", env.signup)
+	users.POST("/refresh", env.refreshToken)
+	users.POST("/logout", env.logout)
+	users.POST("/restore", env.restoreUser)
+	users.POST("/logout_all", authenticated, env.logoutAll)
+	users.GET("/me", authenticated, env.getSelfProfile)
 	users.GET("/:id", env.getUserProfile)
-	users.DELETE("/delete/:id", env.deleteUser)
-	users.PATCH("/update/:id", env.updateUser)
-	users.PATCH("/follow/:user_id", env.followUser)
-	users.PATCH("/unfollow/:user_id", env.unfollowUser)
-
-	// Pode ser removida mais tarde, apenas auxiliar
-	// users.GET("/all", env.getAllUsers)
+	users.GET("/:id/followers", env.getFollowers)
+	users.GET("/:id/following", env.getFollowing)
+	users.DELETE("/delete/:id", authenticated, env.deleteUser)
+	users.PATCH("/update/:id", authenticated, env.updateUser)
+	users.PATCH("/follow/:user_id", authenticated, env.followUser)
+	users.PATCH("/unfollow/:user_id", authenticated, env.unfollowUser)
+	users.GET("/blocked", authenticated, env.getBlockedUsers)
+	users.GET("/muted", authenticated, env.getMutedUsers)
+	users.PATCH("/block/:user_id", authenticated, env.blockUser)
+	users.PATCH("/unblock/:user_id", authenticated, env.unblockUser)
+	users.PATCH("/mute/:user_id", authenticated, env.muteUser)
+	users.PATCH("/unmute/:user_id", authenticated, env.unmuteUser)
+	users.GET("/all", authenticated, env.adminMiddleware(), env.getAllUsers)
 
 	streams := router.Group("/livestreams")
-	streams.POST("/create", env.createLiveStream)
-	streams.DELETE("/delete/:id", env.deleteLiveStream)
-	streams.PATCH("/update/:id", env.updateLiveStream)
+	streams.POST("/create", authenticated, env.createLiveStream)
+	streams.DELETE("/delete/:id", authenticated, env.deleteLiveStream)
+	streams.PATCH("/update/:id", authenticated, env.updateLiveStream)
+	streams.POST("/rotate_key/:id", authenticated, env.rotateStreamKey)
 	streams.GET("/feed", env.getFeed)
+	streams.GET("/feed/personal", authenticated, env.getPersonalFeed)
 	streams.GET("/:user_id", env.getUserLiveStreams)
 	streams.GET("/info/:id", env.getLiveStreamData)
+	streams.GET("/info/:id/settings", authenticated, env.getLiveStreamSettings)
+	streams.GET("/info/:id/sessions", authenticated, env.getLiveStreamSessions)
 	streams.GET("/on_publish", env.validateStream)
+	streams.GET("/on_publish_done", env.endStream)
+	streams.POST("/watch/:id", env.optionalAuthMiddleware(), env.watchStream)
+	streams.POST("/heartbeat/:id", env.viewerHeartbeat)
+	streams.POST("/leave/:id", env.viewerLeave)
+	streams.GET("/on_play", env.playCallback)
 
-	// streams.GET("/all", env.getAllStreams)
+	router.GET("/events", env.optionalAuthMiddleware(), env.streamEvents)
 
 	return router
 }
 
 // Inicia um servidor HTTP e define as rotas padrão da aplicação
-func RunServer(lr models.LiveStreamRepositoryInterface, ur models.UserRepositoryInterface) {
-	env := ServerEnv{
-		liveStreamsRepository: lr,
-		userRepository:        ur,
-	}
+func RunServer(lr models.LiveStreamRepositoryInterface, ur models.UserRepositoryInterface, rr models.RefreshTokenRepositoryInterface, sr models.StreamSessionRepositoryInterface, vr models.ViewerPresenceRepositoryInterface, fr models.FollowRepositoryInterface, rlr models.RelationRepositoryInterface, uw models.UnitOfWork) {
+	env := newServerEnv(lr, ur, rr, sr, vr, fr, rlr, uw)
+
+	go env.runViewerSweeper(durationFromEnv("VIEWER_SWEEP_PERIOD", defaultViewerSweepPeriod))
+	go env.runPurger(durationFromEnv("ACCOUNT_PURGE_PERIOD", defaultPurgePeriod))
+	go env.runFollowCountRepair(durationFromEnv("FOLLOW_REPAIR_PERIOD", defaultFollowRepairPeriod))
 
 	router := setupRouter(env)
 	router.Run(":" + os.Getenv("SERVER_PORT"))
 }
+
+// Monta o ambiente do servidor a partir dos repositórios e das variáveis
+// de ambiente.
+func newServerEnv(lr models.LiveStreamRepositoryInterface, ur models.UserRepositoryInterface, rr models.RefreshTokenRepositoryInterface, sr models.StreamSessionRepositoryInterface, vr models.ViewerPresenceRepositoryInterface, fr models.FollowRepositoryInterface, rlr models.RelationRepositoryInterface, uw models.UnitOfWork) ServerEnv {
+	accessTokenTTL := durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+	refreshTokenTTL := durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+
+	weights, err := ranking.ParseWeights(os.Getenv("FEED_RANKING_WEIGHTS"))
+	if err != nil {
+		log.Fatalf("invalid FEED_RANKING_WEIGHTS: %s\n", err)
+	}
+
+	env := ServerEnv{
+		liveStreamsRepository:    lr,
+		userRepository:           ur,
+		refreshTokenRepository:   rr,
+		streamSessionRepository:  sr,
+		viewerPresenceRepository: vr,
+		followRepository:         fr,
+		relationRepository:       rlr,
+		unitOfWork:               uw,
+		tokenManager:             auth.NewTokenManager(os.Getenv("ACCESS_TOKEN_SECRET"), accessTokenTTL, refreshTokenTTL),
+		feed:                     ranking.NewFeed(lr, sr, ranking.NewWeightedRanker(weights), intFromEnv("FEED_MAX_CANDIDATES", ranking.DefaultMaxCandidates)),
+		events:                   events.NewBus(defaultEventBufferSize, defaultEventHistorySize),
+		viewerHeartbeatTTL:       durationFromEnv("VIEWER_HEARTBEAT_TTL", defaultViewerHeartbeatTTL),
+		viewerTokenTTL:           durationFromEnv("VIEWER_TOKEN_TTL", defaultViewerTokenTTL),
+		restoreWindow:            durationFromEnv("ACCOUNT_RESTORE_WINDOW", defaultRestoreWindow),
+		adminIDs:                 adminIDsFromEnv("ADMIN_USER_IDS"),
+	}
+
+	// Sem o endereço do módulo de controle do nginx-rtmp, não é
+	// possível encerrar transmissões ativas
+	if controlURL := os.Getenv("RTMP_CONTROL_URL"); controlURL != "" {
+		env.publishController = rtmp.NewController(controlURL)
+	}
+
+	return env
+}
+
+// Lê uma duração (ex: "15m", "720h") da variável de ambiente `name`,
+// utilizando `fallback` caso ela não esteja definida.
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %s\n", name, err)
+	}
+
+	return parsed
+}
+
+// Lê um inteiro positivo da variável de ambiente `name`, ou `fallback`
+// caso ela não esteja definida.
+func intFromEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("invalid %s: %q\n", name, value)
+	}
+
+	return parsed
+}
+
+// Lê da variável de ambiente `name` uma lista de ids de usuário separados
+// por vírgula.
+func adminIDsFromEnv(name string) map[primitive.ObjectID]bool {
+	ids := make(map[primitive.ObjectID]bool)
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			log.Fatalf("invalid %s: %s\n", name, err)
+		}
+		ids[id] = true
+	}
+
+	return ids
+}
@@ -1,6 +1,9 @@
 package http
 
 import (
+	"time"
+
+	"github.com/gtvb/livestream/application/events"
 	"github.com/gtvb/livestream/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -45,6 +48,9 @@ type LoginBody struct {
 	// User's password
 	// required: true
 	Password string `json:"password"`
+	// Name of the device starting the session. Defaults to the User-Agent
+	// required: false
+	Device string `json:"device"`
 }
 
 // LoginParamsWrapper contains parameters for user login.
@@ -73,13 +79,51 @@ type SignupParamsWrapper struct {
 	Body SignupBody
 }
 
-// UserResponseWrapper contains a user response.
+type RefreshTokenBody struct {
+	// Refresh token obtained on login, signup or a previous refresh
+	// required: true
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenParamsWrapper contains parameters for refreshing or revoking a session.
+// swagger:parameters refreshToken logout
+type RefreshTokenParamsWrapper struct {
+	// in:body
+	Body RefreshTokenBody
+}
+
+// UserResponseWrapper contains a public user profile.
 // swagger:response userResponse
 type UserResponseWrapper struct {
 	// in:body
 	Body struct {
 		// The user details
-		User models.User `json:"user"`
+		User models.PublicProfile `json:"user"`
+	}
+}
+
+// SelfUserResponseWrapper contains the profile of the authenticated user.
+// swagger:response selfUserResponse
+type SelfUserResponseWrapper struct {
+	// in:body
+	Body struct {
+		// The user details
+		User models.SelfProfile `json:"user"`
+	}
+}
+
+// FollowListResponseWrapper contains a page of followers, followed users, blocked
+// users or muted users.
+// swagger:response followListResponse
+type FollowListResponseWrapper struct {
+	// in:body
+	Body struct {
+		// The users' public profiles
+		Users []models.PublicProfile `json:"users"`
+		// Cursor of the next page, empty on the last one
+		NextCursor string `json:"next_cursor"`
+		// Cursor of the previous page, empty on the first one
+		PrevCursor string `json:"prev_cursor"`
 	}
 }
 
@@ -89,7 +133,11 @@ type UserListResponseWrapper struct {
 	// in:body
 	Body struct {
 		// The user details
-		Users []models.User `json:"users"`
+		Users []models.AdminUserView `json:"users"`
+		// Cursor of the next page, empty on the last one
+		NextCursor string `json:"next_cursor"`
+		// Cursor of the previous page, empty on the first one
+		PrevCursor string `json:"prev_cursor"`
 	}
 }
 
@@ -100,19 +148,108 @@ type LiveStreamsResponseWrapper struct {
 	Body struct {
 		// List of live streams
 		LiveStreams []models.LiveStream `json:"livestreams"`
+		// Cursor of the next page, empty on the last one
+		NextCursor string `json:"next_cursor"`
+		// Cursor of the previous page, empty on the first one
+		PrevCursor string `json:"prev_cursor"`
+	}
+}
+
+// LiveStreamFeedResponseWrapper contains a page of the live stream feed.
+// swagger:response liveStreamFeedResponse
+type LiveStreamFeedResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Live streams of the page along with their publishers, best ranked first
+		Entries []models.FeedEntry `json:"entries"`
+		// Cursor of the next page, empty on the last one
+		NextCursor string `json:"next_cursor"`
+		// Cursor of the previous page, empty on the first one
+		PrevCursor string `json:"prev_cursor"`
 	}
 }
 
+// CursorPaginationParamsWrapper contains the parameters of cursor paginated listings.
+// swagger:parameters getLiveStreamFeed getPersonalFeed getUserLiveStreams getAllUsers getFollowers getFollowing getBlockedUsers getMutedUsers getLiveStreamSessions
+type CursorPaginationParamsWrapper struct {
+	// Maximum amount of items in the page, between 1 and 100. Defaults to 20
+	// in:query
+	Limit int `json:"limit"`
+	// `next_cursor` or `prev_cursor` of a previous page. Omit for the first page
+	// in:query
+	Cursor string `json:"cursor"`
+}
+
 // TokenResponseWrapper contains a token response.
 // swagger:response tokenResponse
 type TokenResponseWrapper struct {
-	// The JWT token for future protected requests.
-	// required: true
+	// in:body
+	Body struct {
+		// The JWT token for future protected requests.
+		// required: true
+		Token string `json:"token"`
+		// When the token stops being accepted
+		// required: true
+		ExpiresAt time.Time `json:"expires_at"`
+		// Single use token to obtain a new token pair once the access token expires.
+		// required: true
+		RefreshToken string `json:"refresh_token"`
+		// When the refresh token stops being accepted
+		// required: true
+		RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	}
+}
+
+// LoginResponseWrapper contains a token response along with the logged user.
+// swagger:response loginResponse
+type LoginResponseWrapper struct {
+	// in:body
 	Body struct {
+		// The JWT token for future protected requests.
+		// required: true
 		Token string `json:"token"`
+		// When the token stops being accepted
+		// required: true
+		ExpiresAt time.Time `json:"expires_at"`
+		// Single use token to obtain a new token pair once the access token expires.
+		// required: true
+		RefreshToken string `json:"refresh_token"`
+		// When the refresh token stops being accepted
+		// required: true
+		RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+		// The logged user
+		User models.SelfProfile `json:"user"`
 	}
 }
 
+// UserDeletionResponseWrapper contains the deadline to restore a deleted user.
+// swagger:response userDeletionResponse
+type UserDeletionResponseWrapper struct {
+	// in:body
+	Body struct {
+		// A descriptive message
+		Message string `json:"message"`
+		// Until when the user can be restored
+		RestoreUntil time.Time `json:"restore_until"`
+		// What was deleted along with the user
+		Removed DeletionReport `json:"removed"`
+	}
+}
+
+// What was deleted along with a user. Everything but the live streams stays in
+// place until the restore window closes, when it is permanently removed.
+type DeletionReport struct {
+	// Live streams deleted along with the user
+	LiveStreams int64 `json:"live_streams"`
+	// Sessions of the user's live streams
+	StreamSessions int64 `json:"stream_sessions"`
+	// Follows the user takes part in, in both directions
+	FollowReferences int64 `json:"follow_references"`
+	// Uploaded thumbnails of the deleted live streams. Files shared with other
+	// live streams are kept
+	Thumbnails int `json:"thumbnails"`
+}
+
 // MessageResponseWrapper contains a message response.
 // swagger:response messageResponse
 type MessageResponseWrapper struct {
@@ -131,6 +268,9 @@ type UpdateLiveStreamBody struct {
 	// Name of the live stream
 	// required: false
 	Name string `json:"name"`
+	// IPs or CIDR blocks allowed to publish with the stream key. Empty allows any address
+	// required: false
+	AllowedIPs *[]string `json:"allowed_ips"`
 }
 
 // UpdateLiveStreamParamsWrapper contains parameters for updating a live stream.
@@ -149,7 +289,7 @@ type CreateLiveStreamBody struct {
 	Name string `json:"name"`
 	// User password (unhashed, obviously)
 	// required: true
-	Thubmnail string `json:"thumbnail"`
+	Thumbnail string `json:"thumbnail"`
 }
 
 // CreateLiveStreamParamsWrapper contains parameters for creating a live stream.
@@ -166,5 +306,121 @@ type LiveStreamResponseWrapper struct {
 	Body struct {
 		// ID of the live stream
 		StreamId primitive.ObjectID `json:"stream_id"`
+		// Secret used by the encoder to publish. Only shown once, on creation
+		StreamKey string `json:"stream_key"`
 	}
 }
+
+// LiveStreamSettingsResponseWrapper contains the publishing settings of a live stream.
+// swagger:response liveStreamSettingsResponse
+type LiveStreamSettingsResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Settings of the live stream, visible only to its publisher
+		Settings models.LiveStreamSettings `json:"settings"`
+	}
+}
+
+type RotateStreamKeyBody struct {
+	// Why the key is being rotated, kept in the rotation history
+	// required: false
+	Reason string `json:"reason"`
+	// Whether the active publish (if any) should be terminated
+	// required: false
+	TerminatePublish bool `json:"terminate_publish"`
+}
+
+// RotateStreamKeyParamsWrapper contains parameters for rotating a stream key.
+// swagger:parameters rotateStreamKey
+type RotateStreamKeyParamsWrapper struct {
+	// in:body
+	Body RotateStreamKeyBody
+}
+
+// StreamKeyResponseWrapper contains a newly generated stream key.
+// swagger:response streamKeyResponse
+type StreamKeyResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Secret used by the encoder to publish. Only shown once
+		StreamKey string `json:"stream_key"`
+		// Whether the active publish was terminated
+		TerminatedPublish bool `json:"terminated_publish"`
+	}
+}
+
+// StreamSessionsResponseWrapper contains a page of stream sessions.
+// swagger:response streamSessionsResponse
+type StreamSessionsResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Sessions of the live stream, most recent first
+		Sessions []models.StreamSession `json:"sessions"`
+		// Cursor of the next page, empty on the last one
+		NextCursor string `json:"next_cursor"`
+		// Cursor of the previous page, empty on the first one
+		PrevCursor string `json:"prev_cursor"`
+	}
+}
+
+type ViewerBody struct {
+	// Viewer token returned by `POST /livestreams/watch/{id}`
+	// required: true
+	ViewerToken string `json:"viewer_token"`
+}
+
+// ViewerParamsWrapper contains parameters for viewer presence operations.
+// swagger:parameters viewerHeartbeat viewerLeave
+type ViewerParamsWrapper struct {
+	// in:body
+	Body ViewerBody
+}
+
+// ViewerTokenResponseWrapper contains the token that identifies a viewer of a live stream.
+// swagger:response viewerTokenResponse
+type ViewerTokenResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Token to send in the heartbeats and when leaving
+		ViewerToken string `json:"viewer_token"`
+		// When the token expires, after which a new one must be requested
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+}
+
+// ViewerCountResponseWrapper contains the current amount of viewers of a live stream.
+// swagger:response viewerCountResponse
+type ViewerCountResponseWrapper struct {
+	// in:body
+	Body struct {
+		// Amount of viewers with a valid heartbeat
+		ViewerCount int `json:"viewer_count"`
+	}
+}
+
+// ###### EVENT RELATED TYPES ######
+
+// StreamEventsParamsWrapper contains the parameters of an event subscription.
+// swagger:parameters streamEvents
+type StreamEventsParamsWrapper struct {
+	// Id of the live stream whose events are received
+	// in:query
+	Stream string `json:"stream"`
+	// Id of the user whose events are received
+	// in:query
+	User string `json:"user"`
+	// Receive the events of the users followed by the authenticated user
+	// in:query
+	Following bool `json:"following"`
+	// Id of the last event received, to resume a previous subscription
+	// in:header
+	LastEventID string `json:"Last-Event-ID"`
+}
+
+// EventStreamResponseWrapper contains a stream of Server-Sent Events. The `event` field
+// of each message is the event type and the data is the event below, in JSON.
+// swagger:response eventStreamResponse
+type EventStreamResponseWrapper struct {
+	// in:body
+	Body events.Event
+}
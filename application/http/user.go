@@ -1,13 +1,23 @@
 package http
 
 import (
+	"context"
 	"errors"
+	"io/fs"
+	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,7 +27,7 @@ import (
 //
 // Responses:
 //
-//	200: messageResponse
+//	200: loginResponse
 //	400: messageResponse
 //	404: messageResponse
 //	500: messageResponse
@@ -29,13 +39,9 @@ func (env *ServerEnv) login(ctx *gin.Context) {
 		return
 	}
 
-	user, err := env.userRepository.GetUserByEmail(loginBody.Email)
+	user, err := env.userRepository.GetUserByEmail(ctx.Request.Context(), loginBody.Email)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			ctx.JSON(http.StatusNotFound, gin.H{"message": "a user with this email/password combination does not exist"})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		}
+		respondWithError(ctx, err, "a user with this email/password combination does not exist")
 		return
 	}
 
@@ -45,7 +51,19 @@ func (env *ServerEnv) login(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"user": user})
+	device := loginBody.Device
+	if device == "" {
+		device = ctx.Request.UserAgent()
+	}
+
+	tokens, err := env.issueTokens(ctx.Request.Context(), user.ID, primitive.NewObjectID(), device)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate tokens"})
+		return
+	}
+
+	tokens["user"] = user.SelfProfile()
+	ctx.JSON(http.StatusOK, tokens)
 }
 
 // swagger:route POST /users/signup users signupUser
@@ -54,8 +72,9 @@ func (env *ServerEnv) login(ctx *gin.Context) {
 //
 // Responses:
 //
-//	201: messageResponse
+//	201: tokenResponse
 //	400: messageResponse
+//	409: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) signup(ctx *gin.Context) {
 	var signupBody SignupBody
@@ -65,14 +84,14 @@ func (env *ServerEnv) signup(ctx *gin.Context) {
 		return
 	}
 
-	user, err := env.userRepository.GetUserByEmail(signupBody.Email)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	user, err := env.userRepository.GetUserByEmail(ctx.Request.Context(), signupBody.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		respondWithError(ctx, err, "failed to check the email")
 		return
 	}
 
 	if user != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "a user with this email already exists"})
+		ctx.JSON(http.StatusConflict, gin.H{"message": "a user with this email already exists"})
 		return
 	}
 
@@ -82,13 +101,170 @@ func (env *ServerEnv) signup(ctx *gin.Context) {
 		return
 	}
 
-	_, err = env.userRepository.CreateUser(signupBody.Username, signupBody.Email, string(hashedPassword))
+	id, err := env.userRepository.CreateUser(ctx.Request.Context(), signupBody.Username, signupBody.Email, string(hashedPassword))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		// A checagem acima não cobre cadastros concorrentes nem usernames
+		// repetidos, que são barrados pelos índices únicos
+		respondWithError(ctx, err, "a user with this email or username already exists")
+		return
+	}
+
+	userID, ok := id.(primitive.ObjectID)
+	if !ok {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "unexpected id for the created user"})
+		return
+	}
+
+	tokens, err := env.issueTokens(ctx.Request.Context(), userID, primitive.NewObjectID(), ctx.Request.UserAgent())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate tokens"})
+		return
+	}
+
+	tokens["message"] = "success"
+	ctx.JSON(http.StatusCreated, tokens)
+}
+
+// swagger:route POST /users/refresh users refreshToken
+//
+// Exchange a refresh token for a new token pair. Every refresh token can be used
+// only once: presenting an already used token revokes the whole session.
+//
+// Responses:
+//
+//	200: tokenResponse
+//	400: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) refreshToken(ctx *gin.Context) {
+	var refreshBody RefreshTokenBody
+	if err := ctx.ShouldBindBodyWithJSON(&refreshBody); err != nil || refreshBody.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "failed to get request body"})
+		return
+	}
+
+	stored, err := env.refreshTokenRepository.GetRefreshTokenByHash(ctx.Request.Context(), auth.HashRefreshToken(refreshBody.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"message": "invalid refresh token"})
+		} else {
+			respondWithError(ctx, err, "failed to find refresh token")
+		}
+		return
+	}
+
+	// Um token já utilizado sendo reapresentado indica que ele vazou,
+	// então toda a sessão (família) é revogada
+	if stored.Revoked() {
+		env.revokeReusedFamily(ctx, stored.FamilyID)
+		return
+	}
+
+	if stored.Expired() {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"message": "refresh token expired"})
+		return
+	}
+
+	// Se outra requisição rotacionou o mesmo token ao mesmo tempo,
+	// tratamos como reuso
+	if err := env.refreshTokenRepository.RevokeRefreshToken(ctx.Request.Context(), stored.ID); err != nil {
+		if !errors.Is(err, repository.ErrNoChange) {
+			respondWithError(ctx, err, "failed to revoke refresh token")
+			return
+		}
+
+		env.revokeReusedFamily(ctx, stored.FamilyID)
+		return
+	}
+
+	tokens, err := env.issueTokens(ctx.Request.Context(), stored.UserID, stored.FamilyID, stored.Device)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate tokens"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// swagger:route POST /users/logout users logout
+//
+// Revoke the session (device) the given refresh token belongs to.
+//
+// Responses:
+//
+//	200: messageResponse
+//	400: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) logout(ctx *gin.Context) {
+	var refreshBody RefreshTokenBody
+	if err := ctx.ShouldBindBodyWithJSON(&refreshBody); err != nil || refreshBody.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "failed to get request body"})
+		return
+	}
+
+	stored, err := env.refreshTokenRepository.GetRefreshTokenByHash(ctx.Request.Context(), auth.HashRefreshToken(refreshBody.RefreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			// Nada para revogar, o resultado é o mesmo de um logout bem sucedido
+			ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+		} else {
+			respondWithError(ctx, err, "failed to find refresh token")
+		}
+		return
+	}
+
+	if err := env.refreshTokenRepository.RevokeRefreshTokenFamily(ctx.Request.Context(), stored.FamilyID); err != nil {
+		respondWithError(ctx, err, "failed to revoke session")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"message": "success"})
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// swagger:route POST /users/logout_all users logoutAll
+//
+// Revoke every session of the authenticated user. Access tokens already
+// issued stay valid until they expire.
+//
+// Responses:
+//
+//	200: messageResponse
+//	401: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) logoutAll(ctx *gin.Context) {
+	if err := env.refreshTokenRepository.RevokeAllUserRefreshTokens(ctx.Request.Context(), authenticatedUserID(ctx)); err != nil {
+		respondWithError(ctx, err, "failed to revoke sessions")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// Emite um novo par de tokens (acesso + refresh) para o usuário. O
+// refresh token pertence à família `familyID`, que representa a sessão
+// iniciada em um dispositivo.
+func (env *ServerEnv) issueTokens(ctx context.Context, userID, familyID primitive.ObjectID, device string) (gin.H, error) {
+	token, expiresAt, err := env.tokenManager.GenerateAccessToken(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshTokenHash, refreshExpiresAt, err := env.tokenManager.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = env.refreshTokenRepository.CreateRefreshToken(ctx, userID, familyID, refreshTokenHash, device, refreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":              token,
+		"expires_at":         expiresAt,
+		"refresh_token":      refreshToken,
+		"refresh_expires_at": refreshExpiresAt,
+	}, nil
 }
 
 // swagger:route GET /users/{id} users getUserProfile
@@ -98,94 +274,328 @@ func (env *ServerEnv) signup(ctx *gin.Context) {
 // Responses:
 //
 //	200: userResponse
+//	400: messageResponse
 //	404: messageResponse
 func (env *ServerEnv) getUserProfile(ctx *gin.Context) {
 	id := ctx.Param("id")
 	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	user, err := env.userRepository.GetUserById(ctx.Request.Context(), objId)
+	if err != nil {
+		respondWithError(ctx, err, "could not find a user with this id")
 		return
 	}
 
-	user, err := env.userRepository.GetUserById(objId)
+	ctx.JSON(http.StatusOK, gin.H{"user": user.PublicProfile()})
+}
+
+// swagger:route GET /users/me users getSelfProfile
+//
+// Get the profile of the authenticated user, including private data.
+//
+// Responses:
+//
+//	200: selfUserResponse
+//	401: messageResponse
+//	404: messageResponse
+func (env *ServerEnv) getSelfProfile(ctx *gin.Context) {
+	user, err := env.userRepository.GetUserById(ctx.Request.Context(), authenticatedUserID(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "could not find a user with this id"})
+		respondWithError(ctx, err, "could not find a user with this id")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"user": user})
+	ctx.JSON(http.StatusOK, gin.H{"user": user.SelfProfile()})
 }
 
 // swagger:route GET /livestreams/{user_id} livestreams getUserLiveStreams
 //
-// Get all live streams that belong to the user specified by `user_id`.
+// Get a page of the live streams that belong to the user specified by `user_id`.
 //
 // Responses:
 //
 //	200: liveStreamsResponse
+//	400: messageResponse
 //	404: messageResponse
 func (env *ServerEnv) getUserLiveStreams(ctx *gin.Context) {
 	id := ctx.Param("user_id")
 	objId, err := primitive.ObjectIDFromHex(id)
 
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
 		return
 	}
 
-	livestreams, err := env.liveStreamsRepository.GetAllLiveStreamsByUserId(objId)
+	page, err := env.liveStreamsRepository.GetAllLiveStreamsByUserId(ctx.Request.Context(), objId, req)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		respondWithError(ctx, err, "failed to get livestreams")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"livestreams": livestreams})
+	ctx.JSON(http.StatusOK, gin.H{"livestreams": page.Items, "next_cursor": page.NextCursor, "prev_cursor": page.PrevCursor})
 }
 
 // swagger:route DELETE /users/{id} users deleteUser
 //
-// Delete a user from the database along with all their registered live streams.
+// Delete a user along with their live streams and sessions. The account can be
+// restored with the same credentials until `restore_until`; after that the user
+// and everything that references them (live streams and stream sessions, refresh
+// tokens, entries in other users' follow lists and uploaded thumbnails) are
+// permanently removed. The response reports how many of each were deleted along
+// with the user. Only the user themselves can perform this operation.
 //
 // Responses:
 //
-//	200: messageResponse
+//	200: userDeletionResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 //	404: messageResponse
 func (env *ServerEnv) deleteUser(ctx *gin.Context) {
 	id := ctx.Param("id")
 	objId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	if !requireOwner(ctx, objId) {
 		return
 	}
 
-	err = env.liveStreamsRepository.DeleteLiveStreamsByPublisher(objId)
+	// O mesmo horário marca o usuário e as lives, para que a restauração
+	// traga de volta apenas as lives removidas junto com ele
+	deletedAt := time.Now()
+	var report DeletionReport
+	err = env.unitOfWork.Do(ctx.Request.Context(), func(ctx context.Context) error {
+		// A transação pode ser repetida, então nada é acumulado entre tentativas
+		report = DeletionReport{}
+
+		user, err := env.userRepository.GetUserById(ctx, objId)
+		if err != nil {
+			return err
+		}
+		report.FollowReferences = int64(user.FollowerCount + user.FollowingCount)
+
+		// Lidas antes da remoção, que esconde as lives das buscas
+		thumbnails, err := env.publisherThumbnails(ctx, objId)
+		if err != nil {
+			return err
+		}
+		report.Thumbnails = len(thumbnails)
+
+		if report.LiveStreams, err = env.liveStreamsRepository.DeleteLiveStreamsByPublisher(ctx, objId, deletedAt); err != nil {
+			return err
+		}
+
+		if report.StreamSessions, err = env.streamSessionRepository.CountStreamSessionsByPublisher(ctx, objId); err != nil {
+			return err
+		}
+
+		if err := env.refreshTokenRepository.RevokeAllUserRefreshTokens(ctx, objId); err != nil {
+			return err
+		}
+
+		return env.userRepository.DeleteUser(ctx, objId, deletedAt)
+	})
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "failed to delete all streams for this user"})
+		respondWithError(ctx, err, "failed to delete this user")
 		return
 	}
 
-	err = env.userRepository.DeleteUser(objId)
+	ctx.JSON(http.StatusOK, gin.H{"message": "success", "restore_until": deletedAt.Add(env.restoreWindow), "removed": report})
+}
+
+// swagger:route POST /users/restore users restoreUser
+//
+// Restore a deleted user, along with the live streams deleted with them, while
+// the restore window is still open. Logs the user in, like the login route.
+//
+// Responses:
+//
+//	200: loginResponse
+//	400: messageResponse
+//	404: messageResponse
+//	410: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) restoreUser(ctx *gin.Context) {
+	var loginBody LoginBody
+
+	if err := ctx.ShouldBindBodyWithJSON(&loginBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "failed to get request body"})
+		return
+	}
+
+	user, err := env.userRepository.GetDeletedUserByEmail(ctx.Request.Context(), loginBody.Email)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": "failed to delete this user"})
+		respondWithError(ctx, err, "a deleted user with this email/password combination does not exist")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginBody.Password))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "passwords don't match"})
+		return
+	}
+
+	if time.Now().After(user.DeletedAt.Add(env.restoreWindow)) {
+		ctx.JSON(http.StatusGone, gin.H{"message": "restore window has expired"})
+		return
+	}
+
+	err = env.unitOfWork.Do(ctx.Request.Context(), func(ctx context.Context) error {
+		if err := env.userRepository.RestoreUser(ctx, user.ID); err != nil {
+			return err
+		}
+
+		_, err := env.liveStreamsRepository.RestoreLiveStreamsByPublisher(ctx, user.ID, *user.DeletedAt)
+		return err
+	})
+	if err != nil {
+		respondWithError(ctx, err, "failed to restore this user")
+		return
+	}
+	user.DeletedAt = nil
+
+	device := loginBody.Device
+	if device == "" {
+		device = ctx.Request.UserAgent()
+	}
+
+	tokens, err := env.issueTokens(ctx.Request.Context(), user.ID, primitive.NewObjectID(), device)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate tokens"})
+		return
+	}
+
+	tokens["user"] = user.SelfProfile()
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// Apaga do disco as thumbnails enviadas que não são mais usadas por
+// nenhuma live, retornando quantos arquivos foram removidos. Falhas são
+// apenas registradas, já que as lives já foram removidas.
+func (env *ServerEnv) removeThumbnails(ctx context.Context, thumbnails []string) int {
+	if len(thumbnails) == 0 {
+		return 0
+	}
+
+	// Arquivos com o mesmo nome são compartilhados entre lives
+	inUse := make(map[string]bool)
+	req := models.PageRequest{Limit: maxPageSize}
+	for {
+		remaining, err := env.liveStreamsRepository.GetAllLiveStreams(ctx, req)
+		if err != nil {
+			log.Printf("Failed to list live streams, keeping thumbnails: %s\n", err.Error())
+			return 0
+		}
+		for _, ls := range remaining.Items {
+			if name, ok := thumbnailFileName(ls.Thumbnail); ok {
+				inUse[name] = true
+			}
+		}
+
+		if remaining.NextCursor == "" {
+			break
+		}
+		if req.Cursor, err = models.DecodeCursor(remaining.NextCursor); err != nil {
+			log.Printf("Failed to list live streams, keeping thumbnails: %s\n", err.Error())
+			return 0
+		}
+	}
+
+	removed := 0
+	for _, thumbnail := range thumbnails {
+		name, ok := thumbnailFileName(thumbnail)
+		if !ok || inUse[name] {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(uploadsDir, name))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Failed to remove thumbnail %s: %s\n", name, err.Error())
+			continue
+		}
+		if err == nil {
+			removed++
+		}
+
+		// Evita contar duas vezes um arquivo usado por mais de uma live
+		inUse[name] = true
+	}
+
+	return removed
+}
+
+// Nomes dos arquivos de thumbnail enviados para as lives não removidas
+// do usuário, sem repetições.
+func (env *ServerEnv) publisherThumbnails(ctx context.Context, publisherID primitive.ObjectID) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+
+	req := models.PageRequest{Limit: maxPageSize}
+	for {
+		page, err := env.liveStreamsRepository.GetAllLiveStreamsByUserId(ctx, publisherID, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, ls := range page.Items {
+			if name, ok := thumbnailFileName(ls.Thumbnail); ok && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+
+		if page.NextCursor == "" {
+			return names, nil
+		}
+		if req.Cursor, err = models.DecodeCursor(page.NextCursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Extrai o nome do arquivo de uma URL de thumbnail gerada em
+// `createLiveStream` (`<BASE_URL>/thumbs/<arquivo>`).
+func thumbnailFileName(thumbnailURL string) (string, bool) {
+	_, name, found := strings.Cut(thumbnailURL, "/thumbs/")
+	if !found || !filepath.IsLocal(name) || name != filepath.Base(name) {
+		return "", false
+	}
+
+	return name, true
 }
 
 // swagger:route PATCH /users/{id} users updateUser
 //
 // Update the user's data identified by the specified `id` parameter.
+// Only the user themselves can perform this operation.
 //
 // Responses:
 //
 //	200: messageResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	409: messageResponse
 func (env *ServerEnv) updateUser(ctx *gin.Context) {
 	id := ctx.Param("id")
 	userID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "invalid id"})
+		return
+	}
+
+	if !requireOwner(ctx, userID) {
 		return
 	}
 
@@ -208,9 +618,9 @@ func (env *ServerEnv) updateUser(ctx *gin.Context) {
 		newData["password"] = updateBody.Email
 	}
 
-	err = env.userRepository.UpdateUser(userID, newData)
+	err = env.userRepository.UpdateUser(ctx.Request.Context(), userID, newData)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not update user: " + err.Error()})
+		respondWithError(ctx, err, "could not update user, the email or username may already be in use")
 		return
 	}
 
@@ -220,11 +630,15 @@ func (env *ServerEnv) updateUser(ctx *gin.Context) {
 // swagger:route PATCH /users/follow/{user_id} users followUser
 //
 // Makes the user id on the body follow `user_id` in the params.
+// The user id on the body must belong to the authenticated user, and
+// neither user may have blocked the other.
 //
 // Responses:
 //
 //	200: messageResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 func (env *ServerEnv) followUser(ctx *gin.Context) {
 	followee := ctx.Param("user_id")
 	followeeID, err := primitive.ObjectIDFromHex(followee)
@@ -234,9 +648,9 @@ func (env *ServerEnv) followUser(ctx *gin.Context) {
 	}
 
 	// Obtemos o que vai ser seguido no banco
-	followeeFromDb, err := env.userRepository.GetUserById(followeeID)
+	followeeFromDb, err := env.userRepository.GetUserById(ctx.Request.Context(), followeeID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not fetch user from db: " + err.Error()})
+		respondWithError(ctx, err, "could not fetch user from db")
 		return
 	}
 
@@ -246,29 +660,53 @@ func (env *ServerEnv) followUser(ctx *gin.Context) {
 		return
 	}
 
+	// Só o próprio usuário autenticado pode seguir/deixar de seguir alguém
+	if !requireOwner(ctx, followBody.UserID) {
+		return
+	}
+
 	// Obtemos também o que vai seguir
-	followerFromDb, err := env.userRepository.GetUserById(followBody.UserID)
+	followerFromDb, err := env.userRepository.GetUserById(ctx.Request.Context(), followBody.UserID)
+	if err != nil {
+		respondWithError(ctx, err, "could not fetch user from db")
+		return
+	}
+
+	// Ninguém segue quem o bloqueou ou quem bloqueou
+	blocked, err := env.relationRepository.IsBlockedBetween(ctx.Request.Context(), followerFromDb.ID, followeeFromDb.ID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not fetch user from db: " + err.Error()})
+		respondWithError(ctx, err, "could not check if the user is blocked")
+		return
+	}
+	if blocked {
+		ctx.JSON(http.StatusForbidden, gin.H{"message": "you cannot follow this user"})
 		return
 	}
 
-	if err = env.userRepository.UpdateUserAddToFollowList(followerFromDb.ID, followeeFromDb.ID); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not follow this user: " + err.Error()})
+	err = env.unitOfWork.Do(ctx.Request.Context(), func(txCtx context.Context) error {
+		return env.follow(txCtx, followerFromDb.ID, followeeFromDb.ID)
+	})
+	if err != nil {
+		respondWithError(ctx, err, "could not follow this user")
 		return
 	}
 
+	env.events.Publish(events.UserFollowed(followerFromDb.ID, followeeFromDb.ID))
+
 	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
 }
 
 // swagger:route PATCH /users/unfollow/{user_id} users unfollowUser
 //
 // Makes the user id on the body unfollow `user_id` in the params.
+// The user id on the body must belong to the authenticated user.
 //
 // Responses:
 //
 //	200: messageResponse
 //	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 func (env *ServerEnv) unfollowUser(ctx *gin.Context) {
 	followee := ctx.Param("user_id")
 	followeeID, err := primitive.ObjectIDFromHex(followee)
@@ -278,9 +716,9 @@ func (env *ServerEnv) unfollowUser(ctx *gin.Context) {
 	}
 
 	// Obtemos o que vai ser seguido no banco
-	followeeFromDb, err := env.userRepository.GetUserById(followeeID)
+	followeeFromDb, err := env.userRepository.GetUserById(ctx.Request.Context(), followeeID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not fetch user from db: " + err.Error()})
+		respondWithError(ctx, err, "could not fetch user from db")
 		return
 	}
 
@@ -290,15 +728,23 @@ func (env *ServerEnv) unfollowUser(ctx *gin.Context) {
 		return
 	}
 
+	// Só o próprio usuário autenticado pode seguir/deixar de seguir alguém
+	if !requireOwner(ctx, followBody.UserID) {
+		return
+	}
+
 	// Obtemos também o que vai seguir
-	followerFromDb, err := env.userRepository.GetUserById(followBody.UserID)
+	followerFromDb, err := env.userRepository.GetUserById(ctx.Request.Context(), followBody.UserID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not fetch user from db: " + err.Error()})
+		respondWithError(ctx, err, "could not fetch user from db")
 		return
 	}
 
-	if err = env.userRepository.UpdateUserRemoveFromFollowList(followerFromDb.ID, followeeFromDb.ID); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"message": "could not follow this user: " + err.Error()})
+	err = env.unitOfWork.Do(ctx.Request.Context(), func(txCtx context.Context) error {
+		return env.unfollow(txCtx, followerFromDb.ID, followeeFromDb.ID)
+	})
+	if err != nil {
+		respondWithError(ctx, err, "could not unfollow this user")
 		return
 	}
 
@@ -307,18 +753,42 @@ func (env *ServerEnv) unfollowUser(ctx *gin.Context) {
 
 // swagger:route GET /users/all users getAllUsers
 //
-// Get all users.
+// Get a page of all users. Only administrators can perform this operation.
 //
 // Responses:
 //
-//	200: []User
+//	200: userListResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
 //	500: messageResponse
 func (env *ServerEnv) getAllUsers(ctx *gin.Context) {
-	users, err := env.userRepository.GetAllUsers()
+	req, ok := parseCursorPagination(ctx)
+	if !ok {
+		return
+	}
+
+	page, err := env.userRepository.GetAllUsers(ctx.Request.Context(), req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to fetch all users"})
+		respondWithError(ctx, err, "failed to fetch all users")
+		return
+	}
+
+	views := make([]*models.AdminUserView, 0, len(page.Items))
+	for _, user := range page.Items {
+		views = append(views, user.AdminView())
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"users": views, "next_cursor": page.NextCursor, "prev_cursor": page.PrevCursor})
+}
+
+// Revoga a família de um refresh token reapresentado. A resposta só diz
+// que a sessão foi revogada se a revogação de fato aconteceu.
+func (env *ServerEnv) revokeReusedFamily(ctx *gin.Context, familyID primitive.ObjectID) {
+	if err := env.refreshTokenRepository.RevokeRefreshTokenFamily(ctx.Request.Context(), familyID); err != nil {
+		respondWithError(ctx, err, "failed to revoke session")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, users)
+	ctx.JSON(http.StatusUnauthorized, gin.H{"message": "refresh token reuse detected, session revoked"})
 }
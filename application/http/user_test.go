@@ -1,20 +1,25 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/gtvb/livestream/infra/repository"
 	"github.com/gtvb/livestream/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func TestUserSignup(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 
 	signupBody := SignupBody{
 		Email:    "test@email.com",
@@ -27,14 +32,26 @@ func TestUserSignup(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, writer.Code)
 	assert.Contains(t, writer.Body.String(), "success")
+	assert.Contains(t, writer.Body.String(), "token")
+
+	t.Run("Duplicate email", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/user/signup", signupBody)
+		assert.Equal(t, http.StatusConflict, writer.Code)
+	})
+
+	t.Run("Duplicate username", func(t *testing.T) {
+		duplicate := signupBody
+		duplicate.Email = "other@email.com"
+
+		writer := makeRequest(router, "POST", "/user/signup", duplicate)
+		assert.Equal(t, http.StatusConflict, writer.Code)
+	})
 }
 
 func TestUserLogin(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
-	env.userRepository.CreateUser("test_username", "test@email.com", hashPassword("test_pass"))
+	env := setupEnv()
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
+	userID := id.(primitive.ObjectID)
 
 	loginBody := LoginBody{
 		Email:    "test@email.com",
@@ -46,15 +63,22 @@ func TestUserLogin(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, writer.Code)
 	assert.Contains(t, writer.Body.String(), "test_username")
+	assertNoPasswordHash(t, writer.Body.String())
+
+	var response struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+
+	claims, err := env.tokenManager.VerifyAccessToken(response.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID.Hex(), claims.Subject)
 }
 
 func TestGetUserProfile(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
+	env := setupEnv()
 
-	env := setupEnv(container.Database)
-
-	id, _ := env.userRepository.CreateUser("test_username", "test@email.com", hashPassword("test_pass"))
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
 	userID := id.(primitive.ObjectID)
 
 	router := setupRouter(env)
@@ -62,31 +86,188 @@ func TestGetUserProfile(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, writer.Code)
 	assert.Contains(t, writer.Body.String(), "test")
-}
+	assert.NotContains(t, writer.Body.String(), "test@email.com")
+	assertNoPasswordHash(t, writer.Body.String())
 
-func TestDeleteUser(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
+	writer = makeRequest(router, "GET", "/user/not-an-id", nil)
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+
+	writer = makeRequest(router, "GET", "/user/"+primitive.NewObjectID().Hex(), nil)
+	assert.Equal(t, http.StatusNotFound, writer.Code)
+}
 
-	env := setupEnv(container.Database)
+func TestGetSelfProfile(t *testing.T) {
+	env := setupEnv()
 
-	id, _ := env.userRepository.CreateUser("test_username", "test@email.com", hashPassword("test_pass"))
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
 	userID := id.(primitive.ObjectID)
 
 	router := setupRouter(env)
-	writer := makeRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil)
+	writer := makeAuthenticatedRequest(router, "GET", "/user/me", nil, generateTestToken(env, userID))
 
 	assert.Equal(t, http.StatusOK, writer.Code)
-	assert.Contains(t, writer.Body.String(), "success")
+	assert.Contains(t, writer.Body.String(), "test@email.com")
+	assertNoPasswordHash(t, writer.Body.String())
 }
 
-func TestUpdateUser(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
+func TestDeleteUser(t *testing.T) {
+	env := setupEnv()
+
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
+	userID := id.(primitive.ObjectID)
+
+	router := setupRouter(env)
 
-	env := setupEnv(container.Database)
+	t.Run("Missing token", func(t *testing.T) {
+		writer := makeRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil)
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Another user", func(t *testing.T) {
+		token := generateTestToken(env, primitive.NewObjectID())
+		writer := makeAuthenticatedRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil, token)
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+	})
+
+	ctx := context.Background()
+
+	followerID, _ := env.userRepository.CreateUser(ctx, "follower", "follower@email.com", hashPassword("test_pass"))
+	require.Equal(t, http.StatusOK, followTestUser(env, followerID.(primitive.ObjectID), userID))
+
+	thumbnail := "delete_user_" + userID.Hex() + ".png"
+	require.NoError(t, os.MkdirAll(uploadsDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(uploadsDir, thumbnail), []byte("png"), 0o644))
+	t.Cleanup(func() { os.Remove(filepath.Join(uploadsDir, thumbnail)) })
+
+	id, err := env.liveStreamsRepository.CreateLiveStream(ctx, "test_stream", "http://localhost:3333/thumbs/"+thumbnail, "streamkey", userID)
+	require.NoError(t, err)
+	streamID := id.(primitive.ObjectID)
+	_, err = env.streamSessionRepository.CreateStreamSession(ctx, streamID, userID, "127.0.0.1", "1", models.EncoderInfo{})
+	require.NoError(t, err)
+	_, err = env.refreshTokenRepository.CreateRefreshToken(ctx, userID, primitive.NewObjectID(), "token_hash", "test", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	credentials := LoginBody{Email: "test@email.com", Password: "test_pass"}
+
+	t.Run("Owner", func(t *testing.T) {
+		token := generateTestToken(env, userID)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil, token)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		var response struct {
+			Message      string         `json:"message"`
+			RestoreUntil time.Time      `json:"restore_until"`
+			Removed      DeletionReport `json:"removed"`
+		}
+		require.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+		assert.Equal(t, "success", response.Message)
+		assert.WithinDuration(t, time.Now().Add(env.restoreWindow), response.RestoreUntil, time.Minute)
+		assert.Equal(t, DeletionReport{LiveStreams: 1, StreamSessions: 1, FollowReferences: 1, Thumbnails: 1}, response.Removed)
+
+		_, err := env.userRepository.GetUserById(ctx, userID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = env.liveStreamsRepository.GetLiveStreamById(ctx, streamID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		refreshToken, err := env.refreshTokenRepository.GetRefreshTokenByHash(ctx, "token_hash")
+		require.NoError(t, err)
+		assert.True(t, refreshToken.Revoked())
+
+		// Nada é apagado definitivamente antes do fim da janela
+		following, err := env.followRepository.GetFollowingIDs(ctx, followerID.(primitive.ObjectID))
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{userID}, following)
+		assert.FileExists(t, filepath.Join(uploadsDir, thumbnail))
+	})
+
+	t.Run("Already deleted", func(t *testing.T) {
+		token := generateTestToken(env, userID)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil, token)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+	})
+
+	t.Run("Restore with wrong password", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/user/restore", LoginBody{Email: "test@email.com", Password: "wrong"})
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/user/restore", credentials)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "refresh_token")
+
+		_, err := env.userRepository.GetUserById(ctx, userID)
+		assert.NoError(t, err)
+		_, err = env.liveStreamsRepository.GetLiveStreamById(ctx, streamID)
+		assert.NoError(t, err)
+
+		writer = makeRequest(router, "POST", "/user/restore", credentials)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+	})
+
+	t.Run("Restore window expired", func(t *testing.T) {
+		token := generateTestToken(env, userID)
+		writer := makeAuthenticatedRequest(router, "DELETE", "/user/delete/"+userID.Hex(), nil, token)
+		require.Equal(t, http.StatusOK, writer.Code)
+
+		expired := env
+		expired.restoreWindow = -time.Minute
+		writer = makeRequest(setupRouter(expired), "POST", "/user/restore", credentials)
+		assert.Equal(t, http.StatusGone, writer.Code)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		// Nenhum item removido dentro da janela é apagado
+		env.purgeDeleted(ctx, time.Now().Add(-time.Hour))
+		_, err := env.userRepository.GetDeletedUserByEmail(ctx, "test@email.com")
+		require.NoError(t, err)
+
+		env.purgeDeleted(ctx, time.Now().Add(time.Minute))
+
+		_, err = env.userRepository.GetDeletedUserByEmail(ctx, "test@email.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		deleted, err := env.liveStreamsRepository.GetLiveStreamsDeletedBefore(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, deleted)
+
+		sessions, err := env.streamSessionRepository.GetStreamSessionsByLiveStream(ctx, streamID, models.PageRequest{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, sessions.Items)
+
+		_, err = env.refreshTokenRepository.GetRefreshTokenByHash(ctx, "token_hash")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		following, err := env.followRepository.GetFollowingIDs(ctx, followerID.(primitive.ObjectID))
+		require.NoError(t, err)
+		assert.Empty(t, following)
+
+		follower, err := env.userRepository.GetUserById(ctx, followerID.(primitive.ObjectID))
+		require.NoError(t, err)
+		assert.Zero(t, follower.FollowingCount)
+		assert.NoFileExists(t, filepath.Join(uploadsDir, thumbnail))
+
+		// Com o usuário apagado, o email pode ser cadastrado de novo
+		_, err = env.userRepository.CreateUser(ctx, "test_username", "test@email.com", hashPassword("test_pass"))
+		assert.NoError(t, err)
+	})
+}
+
+func TestThumbnailFileName(t *testing.T) {
+	name, ok := thumbnailFileName("http://localhost:3333/thumbs/image.png")
+	assert.True(t, ok)
+	assert.Equal(t, "image.png", name)
+
+	for _, url := range []string{"", "http://example.com/image.png", "http://localhost:3333/thumbs/", "http://localhost:3333/thumbs/..", "http://localhost:3333/thumbs/../main.go"} {
+		_, ok := thumbnailFileName(url)
+		assert.False(t, ok, url)
+	}
+}
 
-	id, _ := env.userRepository.CreateUser("test_username", "test@email.com", hashPassword("test_pass"))
+func TestUpdateUser(t *testing.T) {
+	env := setupEnv()
+
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
 	userID := id.(primitive.ObjectID)
 
 	updateBody := UpdateUserBody{
@@ -95,20 +276,17 @@ func TestUpdateUser(t *testing.T) {
 	}
 
 	router := setupRouter(env)
-	writer := makeRequest(router, "PATCH", "/user/update/"+userID.Hex(), updateBody)
+	writer := makeAuthenticatedRequest(router, "PATCH", "/user/update/"+userID.Hex(), updateBody, generateTestToken(env, userID))
 
 	assert.Equal(t, http.StatusOK, writer.Code)
 	assert.Contains(t, writer.Body.String(), "success")
 }
 
 func TestFollowUser(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
-
-	env := setupEnv(container.Database)
+	env := setupEnv()
 
-	id1, _ := env.userRepository.CreateUser("test_username1", "test1@email.com", hashPassword(("test1")))
-	id2, _ := env.userRepository.CreateUser("test_username2", "test2@email.com", hashPassword(("test2")))
+	id1, _ := env.userRepository.CreateUser(context.Background(), "test_username1", "test1@email.com", hashPassword(("test1")))
+	id2, _ := env.userRepository.CreateUser(context.Background(), "test_username2", "test2@email.com", hashPassword(("test2")))
 	user1ID := id1.(primitive.ObjectID)
 	user2ID := id2.(primitive.ObjectID)
 
@@ -117,39 +295,109 @@ func TestFollowUser(t *testing.T) {
 	}
 
 	router := setupRouter(env)
-	writer := makeRequest(router, "PATCH", "/user/follow/"+user2ID.Hex(), followBody)
+	writer := makeAuthenticatedRequest(router, "PATCH", "/user/follow/"+user2ID.Hex(), followBody, generateTestToken(env, user1ID))
 
 	assert.Equal(t, http.StatusOK, writer.Code)
 	assert.Contains(t, writer.Body.String(), "success")
+
+	followee, err := env.userRepository.GetUserById(context.Background(), user2ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, followee.FollowerCount)
+
+	// Seguir de novo não conta o seguidor duas vezes
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/follow/"+user2ID.Hex(), followBody, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusOK, writer.Code)
+	assert.Contains(t, writer.Body.String(), "nothing to update")
+
+	followee, err = env.userRepository.GetUserById(context.Background(), user2ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, followee.FollowerCount)
+
+	following, err := env.followRepository.GetFollowingIDs(context.Background(), user1ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{user2ID}, following)
+
+	// Ninguém segue a si mesmo
+	writer = makeAuthenticatedRequest(router, "PATCH", "/user/follow/"+user1ID.Hex(), followBody, generateTestToken(env, user1ID))
+	assert.Equal(t, http.StatusBadRequest, writer.Code)
+	assert.Contains(t, writer.Body.String(), "users cannot follow themselves")
 }
 
 func TestUnFollowUser(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
+	env := setupEnv()
 
-	env := setupEnv(container.Database)
-
-	id1, _ := env.userRepository.CreateUser("test_username1", "test1@email.com", hashPassword(("test1")))
-	id2, _ := env.userRepository.CreateUser("test_username2", "test2@email.com", hashPassword(("test2")))
+	id1, _ := env.userRepository.CreateUser(context.Background(), "test_username1", "test1@email.com", hashPassword(("test1")))
+	id2, _ := env.userRepository.CreateUser(context.Background(), "test_username2", "test2@email.com", hashPassword(("test2")))
 	user1ID := id1.(primitive.ObjectID)
 	user2ID := id2.(primitive.ObjectID)
 
+	assert.Equal(t, http.StatusOK, followTestUser(env, user1ID, user2ID))
+
 	followBody := FollowBody{
 		UserID: user1ID,
 	}
 
 	router := setupRouter(env)
-	writer := makeRequest(router, "PATCH", "/user/unfollow/"+user2ID.Hex(), followBody)
+	writer := makeAuthenticatedRequest(router, "PATCH", "/user/unfollow/"+user2ID.Hex(), followBody, generateTestToken(env, user1ID))
 
 	assert.Equal(t, http.StatusOK, writer.Code)
 	assert.Contains(t, writer.Body.String(), "success")
 }
 
-func TestGetAllUsers(t *testing.T) {
-	container := setupDatabase()
-	defer container.Terminate()
+func TestGetFollowers(t *testing.T) {
+	env := setupEnv()
+
+	var ids []primitive.ObjectID
+	for i := range 3 {
+		id, _ := env.userRepository.CreateUser(context.Background(), fmt.Sprintf("test_username%d", i), fmt.Sprintf("test%d@email.com", i), hashPassword("test_pass"))
+		ids = append(ids, id.(primitive.ObjectID))
+	}
+
+	// O primeiro usuário segue os outros dois
+	assert.Equal(t, http.StatusOK, followTestUser(env, ids[0], ids[1]))
+	assert.Equal(t, http.StatusOK, followTestUser(env, ids[0], ids[2]))
+	assert.Equal(t, http.StatusOK, followTestUser(env, ids[1], ids[2]))
+
+	router := setupRouter(env)
+
+	t.Run("Followers", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/user/"+ids[2].Hex()+"/followers?limit=1", nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "test_username0")
+		assert.NotContains(t, writer.Body.String(), "test0@email.com")
+		assertNoPasswordHash(t, writer.Body.String())
+
+		var response struct {
+			NextCursor string `json:"next_cursor"`
+		}
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.NextCursor)
+
+		writer = makeRequest(router, "GET", "/user/"+ids[2].Hex()+"/followers?limit=1&cursor="+response.NextCursor, nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "test_username1")
+		assert.Contains(t, writer.Body.String(), `"next_cursor":""`)
+	})
+
+	t.Run("Following", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/user/"+ids[0].Hex()+"/following", nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "test_username1")
+		assert.Contains(t, writer.Body.String(), "test_username2")
+		assert.Contains(t, writer.Body.String(), `"follower_count":2`)
+	})
+
+	t.Run("Unknown user", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/user/"+primitive.NewObjectID().Hex()+"/followers", nil)
+		assert.Equal(t, http.StatusNotFound, writer.Code)
+
+		writer = makeRequest(router, "GET", "/user/not-an-id/following", nil)
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+}
 
-	env := setupEnv(container.Database)
+func TestGetAllUsers(t *testing.T) {
+	env := setupEnv()
 
 	users := []models.User{
 		{Username: "test_username1", Email: "test1@email.com", Password: hashPassword("test_pass1")},
@@ -157,18 +405,166 @@ func TestGetAllUsers(t *testing.T) {
 		{Username: "test_username3", Email: "test3@email.com", Password: hashPassword("test_pass3")},
 	}
 
+	var ids []primitive.ObjectID
 	for _, user := range users {
-		env.userRepository.CreateUser(user.Username, user.Email, user.Password)
+		id, _ := env.userRepository.CreateUser(context.Background(), user.Username, user.Email, user.Password)
+		ids = append(ids, id.(primitive.ObjectID))
 	}
+	env.adminIDs = map[primitive.ObjectID]bool{ids[0]: true}
 
 	router := setupRouter(env)
-	writer := makeRequest(router, "GET", "/user/all", nil)
 
-	assert.Equal(t, http.StatusOK, writer.Code)
-	// assert.JSONEq(t, string(expectedJSON), writer.Body.String())
+	t.Run("Not an admin", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/user/all", nil, generateTestToken(env, ids[1]))
+		assert.Equal(t, http.StatusForbidden, writer.Code)
+	})
+
+	t.Run("Paginated", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/user/all?limit=2", nil, generateTestToken(env, ids[0]))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "test1@email.com")
+		assert.NotContains(t, writer.Body.String(), "test3@email.com")
+		assertNoPasswordHash(t, writer.Body.String())
+
+		var response struct {
+			NextCursor string `json:"next_cursor"`
+		}
+		assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.NextCursor)
+
+		writer = makeAuthenticatedRequest(router, "GET", "/user/all?limit=2&cursor="+response.NextCursor, nil, generateTestToken(env, ids[0]))
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), "test3@email.com")
+		assert.Contains(t, writer.Body.String(), `"next_cursor":""`)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		writer := makeAuthenticatedRequest(router, "GET", "/user/all?cursor=garbage", nil, generateTestToken(env, ids[0]))
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+		assert.Contains(t, writer.Body.String(), "invalid cursor")
+	})
+}
+
+// Garante que nenhum hash bcrypt (nem o campo de senha) aparece no corpo
+func assertNoPasswordHash(t *testing.T, body string) {
+	t.Helper()
+
+	assert.NotContains(t, body, "$2a$")
+	assert.NotContains(t, body, "password")
+}
+
+// Faz `followerID` seguir `followeeID` pela rota de follow, como um cliente.
+func followTestUser(env ServerEnv, followerID, followeeID primitive.ObjectID) int {
+	body := FollowBody{UserID: followerID}
+	writer := makeAuthenticatedRequest(setupRouter(env), "PATCH", "/user/follow/"+followeeID.Hex(), body, generateTestToken(env, followerID))
+	return writer.Code
 }
 
 func hashPassword(password string) string {
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(hashed)
 }
+
+func TestRefreshToken(t *testing.T) {
+	env := setupEnv()
+	env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
+
+	router := setupRouter(env)
+	writer := makeRequest(router, "POST", "/user/login", LoginBody{Email: "test@email.com", Password: "test_pass"})
+
+	var loginResponse struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &loginResponse))
+
+	writer = makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: loginResponse.RefreshToken})
+	assert.Equal(t, http.StatusOK, writer.Code)
+
+	var refreshResponse struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &refreshResponse))
+	assert.NotEmpty(t, refreshResponse.Token)
+	assert.NotEqual(t, loginResponse.RefreshToken, refreshResponse.RefreshToken)
+
+	t.Run("Reusing a rotated token revokes the session", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: loginResponse.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		assert.Contains(t, writer.Body.String(), "reuse detected")
+
+		writer = makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: refreshResponse.RefreshToken})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: "unknown"})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	env := setupEnv()
+	id, _ := env.userRepository.CreateUser(context.Background(), "test_username", "test@email.com", hashPassword("test_pass"))
+	userID := id.(primitive.ObjectID)
+
+	router := setupRouter(env)
+	login := func() string {
+		writer := makeRequest(router, "POST", "/user/login", LoginBody{Email: "test@email.com", Password: "test_pass"})
+
+		var response struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		json.Unmarshal(writer.Body.Bytes(), &response)
+		return response.RefreshToken
+	}
+
+	t.Run("Single device", func(t *testing.T) {
+		refreshToken := login()
+
+		writer := makeRequest(router, "POST", "/user/logout", RefreshTokenBody{RefreshToken: refreshToken})
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		writer = makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: refreshToken})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("All devices", func(t *testing.T) {
+		first, second := login(), login()
+
+		writer := makeAuthenticatedRequest(router, "POST", "/user/logout_all", nil, generateTestToken(env, userID))
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		for _, refreshToken := range []string{first, second} {
+			writer = makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: refreshToken})
+			assert.Equal(t, http.StatusUnauthorized, writer.Code)
+		}
+	})
+}
+
+// Refresh tokens que sempre estão revogados e cuja família nunca consegue
+// ser revogada.
+type failingRevocationTokens struct {
+	models.RefreshTokenRepositoryInterface
+}
+
+func (failingRevocationTokens) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	revokedAt := time.Now()
+	token := models.NewRefreshToken(primitive.NewObjectID(), primitive.NewObjectID(), tokenHash, "device", time.Now().Add(time.Hour))
+	token.RevokedAt = &revokedAt
+	return token, nil
+}
+
+func (failingRevocationTokens) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	return repository.ErrUnavailable
+}
+
+func TestRefreshTokenReuseRevocationFailure(t *testing.T) {
+	env := ServerEnv{refreshTokenRepository: failingRevocationTokens{}}
+	router := setupRouter(env)
+
+	// A sessão não é dada como revogada se a revogação falhou
+	writer := makeRequest(router, "POST", "/user/refresh", RefreshTokenBody{RefreshToken: "stolen-token"})
+	assert.Equal(t, http.StatusServiceUnavailable, writer.Code)
+	assert.NotContains(t, writer.Body.String(), "session revoked")
+}
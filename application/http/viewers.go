@@ -0,0 +1,289 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/application/events"
+	"github.com/gtvb/livestream/infra/auth"
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultViewerHeartbeatTTL = 30 * time.Second
+	defaultViewerSweepPeriod  = 15 * time.Second
+	defaultViewerTokenTTL     = 12 * time.Hour
+)
+
+// swagger:route POST /livestreams/watch/{id} livestreams watchStream
+//
+// Start watching the live stream identified by `id`. The returned viewer token identifies
+// the viewer's session and must be sent in the heartbeats and when leaving. Once it
+// expires, the player must request a new one. The token of an authenticated viewer is
+// bound to the user, who counts as a single viewer however many tokens they request,
+// and viewers blocked by the publisher are refused. Anonymous viewers are not checked.
+//
+// Responses:
+//
+//	200: viewerTokenResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	409: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) watchStream(ctx *gin.Context) {
+	streamID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
+		return
+	}
+
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+	if err != nil {
+		respondWithError(ctx, err, "failed to find stream")
+		return
+	}
+
+	if !livestream.LiveStatus {
+		ctx.JSON(http.StatusConflict, gin.H{"message": "stream is offline"})
+		return
+	}
+
+	if !env.requireNotBlockedBy(ctx, livestream.PublisherId) {
+		return
+	}
+
+	token, expiresAt, err := env.tokenManager.GenerateViewerToken(streamID, authenticatedUserID(ctx), env.viewerTokenTTL)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to generate viewer token"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"viewer_token": token, "expires_at": expiresAt})
+}
+
+// swagger:route POST /livestreams/heartbeat/{id} livestreams viewerHeartbeat
+//
+// Register that the viewer identified by `viewer_token` is still watching the live stream
+// identified by `id`. Players must call this periodically, viewers that stop sending
+// heartbeats are no longer counted. Viewers whose token was issued to a user blocked
+// by the publisher are refused, even if the block came after the token.
+//
+// Responses:
+//
+//	200: viewerCountResponse
+//	400: messageResponse
+//	401: messageResponse
+//	403: messageResponse
+//	404: messageResponse
+//	409: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) viewerHeartbeat(ctx *gin.Context) {
+	streamID, claims, ok := env.parseViewerRequest(ctx)
+	if !ok {
+		return
+	}
+
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+	if err != nil {
+		respondWithError(ctx, err, "failed to find stream")
+		return
+	}
+
+	if !livestream.LiveStatus {
+		ctx.JSON(http.StatusConflict, gin.H{"message": "stream is offline"})
+		return
+	}
+
+	// Um bloqueio feito depois da emissão do token também vale, e a
+	// presença já registrada deixa de ser contada
+	if !env.requireViewerNotBlockedBy(ctx, livestream.PublisherId, claims.UserID()) {
+		env.viewerPresenceRepository.RemoveViewer(ctx.Request.Context(), streamID, claims.SessionID())
+		return
+	}
+
+	if err := env.viewerPresenceRepository.RecordViewerHeartbeat(ctx.Request.Context(), streamID, claims.SessionID(), env.viewerHeartbeatTTL); err != nil {
+		respondWithError(ctx, err, "failed to record heartbeat")
+		return
+	}
+
+	viewers, err := env.syncViewerCount(ctx.Request.Context(), livestream)
+	if err != nil {
+		respondWithError(ctx, err, "failed to update viewer count")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"viewer_count": viewers})
+}
+
+// swagger:route POST /livestreams/leave/{id} livestreams viewerLeave
+//
+// Register that the viewer identified by `viewer_token` stopped watching the live stream
+// identified by `id`.
+//
+// Responses:
+//
+//	200: viewerCountResponse
+//	400: messageResponse
+//	401: messageResponse
+//	404: messageResponse
+//	500: messageResponse
+func (env *ServerEnv) viewerLeave(ctx *gin.Context) {
+	streamID, claims, ok := env.parseViewerRequest(ctx)
+	if !ok {
+		return
+	}
+
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+	if err != nil {
+		respondWithError(ctx, err, "failed to find stream")
+		return
+	}
+
+	if err := env.viewerPresenceRepository.RemoveViewer(ctx.Request.Context(), streamID, claims.SessionID()); err != nil {
+		respondWithError(ctx, err, "failed to remove viewer")
+		return
+	}
+
+	viewers, err := env.syncViewerCount(ctx.Request.Context(), livestream)
+	if err != nil {
+		respondWithError(ctx, err, "failed to update viewer count")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"viewer_count": viewers})
+}
+
+// Chamado pelo nginx-rtmp nos callbacks `on_play`, `on_update` e
+// `on_play_done` da aplicação `hls-live`, onde o nome da stream é o id
+// da live. Cada cliente RTMP é tratado como uma sessão de espectador.
+// Como o `clientid` não é autenticado, a rota só pode ser alcançada pelo
+// nginx-rtmp: o nginx público bloqueia `/livestreams/on_*`.
+func (env *ServerEnv) playCallback(ctx *gin.Context) {
+	streamID, err := primitive.ObjectIDFromHex(ctx.Query("name"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
+		return
+	}
+
+	sessionID := "rtmp-" + ctx.Query("clientid")
+
+	switch ctx.Query("call") {
+	case "play", "update_play":
+		err = env.viewerPresenceRepository.RecordViewerHeartbeat(ctx.Request.Context(), streamID, sessionID, env.viewerHeartbeatTTL)
+	case "play_done":
+		err = env.viewerPresenceRepository.RemoveViewer(ctx.Request.Context(), streamID, sessionID)
+	default:
+		ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+		return
+	}
+
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"message": "failed to update viewer presence"})
+		return
+	}
+
+	livestream, err := env.liveStreamsRepository.GetLiveStreamById(ctx.Request.Context(), streamID)
+	if err != nil {
+		respondWithError(ctx, err, "failed to find stream")
+		return
+	}
+
+	if _, err := env.syncViewerCount(ctx.Request.Context(), livestream); err != nil {
+		respondWithError(ctx, err, "failed to update viewer count")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "success"})
+}
+
+// Lê a live e o token de espectador da requisição, retornando as claims
+// do token. O id da sessão nunca vem do cliente, para que ninguém possa
+// renovar ou encerrar a presença de outro espectador.
+func (env *ServerEnv) parseViewerRequest(ctx *gin.Context) (primitive.ObjectID, *auth.ViewerClaims, bool) {
+	streamID, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "unparseable ID"})
+		return primitive.NilObjectID, nil, false
+	}
+
+	var viewerBody ViewerBody
+	if err := ctx.ShouldBindBodyWithJSON(&viewerBody); err != nil || viewerBody.ViewerToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"message": "missing viewer token"})
+		return primitive.NilObjectID, nil, false
+	}
+
+	claims, err := env.tokenManager.VerifyViewerToken(viewerBody.ViewerToken, streamID)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"message": "invalid or expired viewer token"})
+		return primitive.NilObjectID, nil, false
+	}
+
+	return streamID, claims, true
+}
+
+// Recalcula o contador de espectadores da live a partir das presenças
+// ainda válidas. Quando o contador difere do lido em `livestream`, a
+// mudança é publicada.
+func (env *ServerEnv) syncViewerCount(ctx context.Context, livestream *models.LiveStream) (int, error) {
+	viewers, err := env.viewerPresenceRepository.CountActiveViewers(ctx, livestream.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nada muda quando o contador já está certo e a última atualização
+	// caiu no mesmo milissegundo
+	err = env.liveStreamsRepository.SetLiveStreamViewerCount(ctx, livestream.ID, viewers)
+	if err != nil && !errors.Is(err, repository.ErrNoChange) {
+		return 0, err
+	}
+
+	if viewers != livestream.ViewerCount {
+		env.events.Publish(events.ViewerCountChanged(livestream.ID, livestream.PublisherId, viewers))
+	}
+
+	return viewers, nil
+}
+
+// Periodicamente recalcula o contador das lives ativas, para que
+// espectadores que sumiram sem avisar deixem de ser contados mesmo
+// que nenhum outro heartbeat chegue.
+func (env *ServerEnv) runViewerSweeper(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		env.sweepViewers(context.Background())
+	}
+}
+
+// Recalcula o contador de cada live ativa e registra a amostra na sessão
+// de transmissão. As amostras só são registradas aqui, em intervalos
+// regulares, para que a média da sessão seja ponderada pelo tempo e não
+// pela quantidade de heartbeats.
+func (env *ServerEnv) sweepViewers(ctx context.Context) {
+	livestreams, err := env.liveStreamsRepository.GetActiveLiveStreams(ctx)
+	if err != nil {
+		log.Printf("viewer sweeper: failed to get active streams: %s\n", err)
+		return
+	}
+
+	for _, livestream := range livestreams {
+		viewers, err := env.syncViewerCount(ctx, livestream)
+		if err != nil {
+			log.Printf("viewer sweeper: failed to sync stream %s: %s\n", livestream.ID.Hex(), err)
+			continue
+		}
+
+		if err := env.streamSessionRepository.RecordStreamSessionViewers(ctx, livestream.ID, viewers); err != nil {
+			log.Printf("viewer sweeper: failed to sample stream %s: %s\n", livestream.ID.Hex(), err)
+		}
+	}
+}
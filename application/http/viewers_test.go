@@ -0,0 +1,174 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Começa a assistir a live `id`, retornando o token de espectador.
+func watchTestStream(t *testing.T, router *gin.Engine, id primitive.ObjectID) string {
+	writer := makeRequest(router, "POST", "/livestreams/watch/"+id.Hex(), nil)
+	require.Equal(t, http.StatusOK, writer.Code)
+
+	var response struct {
+		ViewerToken string `json:"viewer_token"`
+	}
+	require.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+	require.NotEmpty(t, response.ViewerToken)
+
+	return response.ViewerToken
+}
+
+func TestViewerHeartbeat(t *testing.T) {
+	env := setupEnv()
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	t.Run("Offline stream", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/livestreams/watch/"+id.Hex(), nil)
+		assert.Equal(t, http.StatusConflict, writer.Code)
+	})
+
+	env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"live_stream_status": true})
+
+	t.Run("Missing viewer token", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{})
+		assert.Equal(t, http.StatusBadRequest, writer.Code)
+	})
+
+	t.Run("Invalid viewer token", func(t *testing.T) {
+		writer := makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: "viewer-1"})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+
+		// Tokens de acesso não identificam espectadores
+		writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: generateTestToken(env, user.ID)})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+
+		// Nem tokens emitidos para outra live
+		otherID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Other Stream", "fake-thumbnail", "streamkey-other", user.ID)
+		other := otherID.(primitive.ObjectID)
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), other, bson.M{"live_stream_status": true})
+
+		writer = makeRequest(router, "POST", "/livestreams/leave/"+id.Hex(), ViewerBody{ViewerToken: watchTestStream(t, router, other)})
+		assert.Equal(t, http.StatusUnauthorized, writer.Code)
+	})
+
+	t.Run("Heartbeats and leave", func(t *testing.T) {
+		first, second := watchTestStream(t, router, id), watchTestStream(t, router, id)
+
+		makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: first})
+		makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: first})
+		writer := makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: second})
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), `"viewer_count":2`)
+
+		writer = makeRequest(router, "POST", "/livestreams/leave/"+id.Hex(), ViewerBody{ViewerToken: first})
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), `"viewer_count":1`)
+
+		// Sair duas vezes não deixa o contador negativo
+		makeRequest(router, "POST", "/livestreams/leave/"+id.Hex(), ViewerBody{ViewerToken: second})
+		writer = makeRequest(router, "POST", "/livestreams/leave/"+id.Hex(), ViewerBody{ViewerToken: second})
+		assert.Contains(t, writer.Body.String(), `"viewer_count":0`)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.Equal(t, 0, ls.ViewerCount)
+	})
+
+	t.Run("Authenticated viewer", func(t *testing.T) {
+		// Os tokens de um mesmo usuário contam como um único espectador
+		for range 2 {
+			writer := makeAuthenticatedRequest(router, "POST", "/livestreams/watch/"+id.Hex(), nil, generateTestToken(env, user.ID))
+			assert.Equal(t, http.StatusOK, writer.Code)
+
+			var response struct {
+				ViewerToken string `json:"viewer_token"`
+			}
+			assert.NoError(t, json.Unmarshal(writer.Body.Bytes(), &response))
+
+			writer = makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: response.ViewerToken})
+			assert.Equal(t, http.StatusOK, writer.Code)
+			assert.Contains(t, writer.Body.String(), `"viewer_count":1`)
+		}
+
+		env.viewerPresenceRepository.RemoveViewer(context.Background(), id, "user-"+user.ID.Hex())
+		env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"viewer_count": 0})
+	})
+
+	t.Run("RTMP play callbacks", func(t *testing.T) {
+		writer := makeRequest(router, "GET", "/livestreams/on_play?call=play&clientid=9&name="+id.Hex(), nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		ls, _ := env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.Equal(t, 1, ls.ViewerCount)
+
+		writer = makeRequest(router, "GET", "/livestreams/on_play?call=play_done&clientid=9&name="+id.Hex(), nil)
+		assert.Equal(t, http.StatusOK, writer.Code)
+
+		ls, _ = env.liveStreamsRepository.GetLiveStreamById(context.Background(), id)
+		assert.Equal(t, 0, ls.ViewerCount)
+	})
+}
+
+// Monta o ambiente pelo mesmo caminho de `RunServer`, com os repositórios
+// de `env`.
+func newServerEnvFrom(env ServerEnv) ServerEnv {
+	return newServerEnv(env.liveStreamsRepository, env.userRepository, env.refreshTokenRepository, env.streamSessionRepository, env.viewerPresenceRepository, env.followRepository, env.relationRepository, env.unitOfWork)
+}
+
+func TestViewerHeartbeatTTLConfig(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		t.Setenv("VIEWER_HEARTBEAT_TTL", "")
+		assert.Equal(t, defaultViewerHeartbeatTTL, newServerEnvFrom(ServerEnv{}).viewerHeartbeatTTL)
+	})
+
+	t.Run("From environment", func(t *testing.T) {
+		t.Setenv("VIEWER_HEARTBEAT_TTL", "45s")
+		assert.Equal(t, 45*time.Second, newServerEnvFrom(ServerEnv{}).viewerHeartbeatTTL)
+	})
+}
+
+func TestViewerSessionSamples(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_SECRET", "test-secret")
+	env := newServerEnvFrom(setupEnv())
+	router := setupRouter(env)
+	user := createTestUser(env)
+
+	streamID, _ := env.liveStreamsRepository.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", user.ID)
+	id := streamID.(primitive.ObjectID)
+
+	env.liveStreamsRepository.UpdateLiveStream(context.Background(), id, bson.M{"live_stream_status": true})
+	env.streamSessionRepository.CreateStreamSession(context.Background(), id, user.ID, "127.0.0.1", "1", models.EncoderInfo{})
+
+	// Com o TTL configurado, o espectador continua contado depois do heartbeat
+	viewerToken := watchTestStream(t, router, id)
+	for range 3 {
+		writer := makeRequest(router, "POST", "/livestreams/heartbeat/"+id.Hex(), ViewerBody{ViewerToken: viewerToken})
+		assert.Equal(t, http.StatusOK, writer.Code)
+		assert.Contains(t, writer.Body.String(), `"viewer_count":1`)
+	}
+
+	session, err := env.streamSessionRepository.GetOpenStreamSession(context.Background(), id)
+	require.NoError(t, err)
+	assert.Zero(t, session.ViewerSamples, "heartbeats must not sample the session")
+
+	env.sweepViewers(context.Background())
+
+	session, err = env.streamSessionRepository.GetOpenStreamSession(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, 1, session.ViewerSamples)
+	assert.Equal(t, 1, session.PeakViewers)
+}
@@ -0,0 +1,149 @@
+package ranking
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Quantas lives ativas, das mais assistidas, são pontuadas por padrão a
+// cada página do feed.
+const DefaultMaxCandidates = 500
+
+// Feed de descoberta: as lives ativas, ordenadas pelo ranker.
+//
+// As notas são recalculadas a cada página, então a ordenação entre
+// páginas é aproximada: uma live cuja audiência mudou entre duas páginas
+// pode aparecer de novo ou ser pulada. Para limitar o custo de cada
+// página, apenas as `maxCandidates` lives mais assistidas são pontuadas.
+type Feed struct {
+	liveStreams   models.LiveStreamRepositoryInterface
+	sessions      models.StreamSessionRepositoryInterface
+	ranker        *Ranker
+	maxCandidates int
+}
+
+func NewFeed(liveStreams models.LiveStreamRepositoryInterface, sessions models.StreamSessionRepositoryInterface, ranker *Ranker, maxCandidates int) *Feed {
+	return &Feed{liveStreams: liveStreams, sessions: sessions, ranker: ranker, maxCandidates: maxCandidates}
+}
+
+// Retorna uma página do feed pontuado no instante `now`. O instante é
+// truncado ao minuto, para que páginas pedidas em sequência usem as
+// mesmas notas de idade e só mudem de posição as lives cuja audiência
+// mudou.
+func (f *Feed) Page(ctx context.Context, req models.PageRequest, now time.Time) (*models.Page[*Ranked], error) {
+	candidates, err := f.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := f.rank(candidates, SectionRecommended, now.Truncate(time.Minute))
+	return models.Paginate(ranked, req, true, (*Ranked).Position)
+}
+
+// Retorna uma página do feed personalizado: primeiro as lives de quem o
+// usuário segue (`following`), depois as demais, cada seção ordenada pelo
+// ranker. As lives recomendadas só são lidas quando a página alcança a
+// segunda seção. As lives dos publishers `hidden` não aparecem em
+// nenhuma das seções.
+func (f *Feed) PersonalPage(ctx context.Context, req models.PageRequest, following, hidden []primitive.ObjectID, now time.Time) (*models.Page[*Ranked], error) {
+	now = now.Truncate(time.Minute)
+
+	isHidden := make(map[primitive.ObjectID]bool, len(hidden))
+	for _, id := range hidden {
+		isHidden[id] = true
+	}
+	following = slices.DeleteFunc(slices.Clone(following), func(id primitive.ObjectID) bool { return isHidden[id] })
+
+	var followed []*Ranked
+	if len(following) > 0 {
+		liveStreams, err := f.liveStreams.GetActiveLiveStreamsByPublishers(ctx, following)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates, err := f.withSessions(ctx, liveStreams)
+		if err != nil {
+			return nil, err
+		}
+		followed = f.rank(candidates, SectionFollowing, now)
+	}
+
+	// Páginas que terminam antes do fim da primeira seção não dependem da segunda
+	cursor := req.Cursor
+	if cursor == nil || cursor.Section == SectionFollowing {
+		page, err := models.Paginate(followed, req, true, (*Ranked).Position)
+		if err != nil {
+			return nil, err
+		}
+		if (cursor != nil && cursor.Backward) || page.NextCursor != "" {
+			return page, nil
+		}
+	}
+
+	candidates, err := f.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	isFollowed := make(map[primitive.ObjectID]bool, len(following))
+	for _, id := range following {
+		isFollowed[id] = true
+	}
+	candidates = slices.DeleteFunc(candidates, func(c Candidate) bool {
+		return isFollowed[c.LiveStream.PublisherId] || isHidden[c.LiveStream.PublisherId]
+	})
+
+	recommended := f.rank(candidates, SectionRecommended, now)
+	return models.Paginate(append(followed, recommended...), req, true, (*Ranked).Position)
+}
+
+// Ordena as lives de uma seção do feed.
+func (f *Feed) rank(candidates []Candidate, section int, now time.Time) []*Ranked {
+	ranked := f.ranker.Rank(candidates, now)
+	for _, r := range ranked {
+		r.Section = section
+	}
+	return ranked
+}
+
+// Lê as lives ativas mais assistidas junto das suas sessões abertas.
+func (f *Feed) candidates(ctx context.Context) ([]Candidate, error) {
+	liveStreams, err := f.liveStreams.GetMostWatchedLiveStreams(ctx, f.maxCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.withSessions(ctx, liveStreams)
+}
+
+// Junta a cada live a sua sessão aberta.
+func (f *Feed) withSessions(ctx context.Context, liveStreams []*models.LiveStream) ([]Candidate, error) {
+	ids := make([]primitive.ObjectID, 0, len(liveStreams))
+	for _, ls := range liveStreams {
+		ids = append(ids, ls.ID)
+	}
+
+	sessions, err := f.sessions.GetOpenStreamSessions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Com mais de uma sessão aberta, a mais recente é a atual
+	current := make(map[primitive.ObjectID]*models.StreamSession, len(sessions))
+	for _, session := range sessions {
+		if other, ok := current[session.LiveStreamID]; !ok || session.StartedAt.After(other.StartedAt) {
+			current[session.LiveStreamID] = session
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(liveStreams))
+	for _, ls := range liveStreams {
+		candidates = append(candidates, Candidate{LiveStream: ls, Session: current[ls.ID]})
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,97 @@
+// Ordenação das lives ativas no feed de descoberta.
+//
+// Cada `Scorer` avalia um aspecto da live (espectadores, crescimento,
+// idade da sessão, recência) com uma nota entre 0 e 1, e o `Ranker`
+// combina as notas em uma soma ponderada. Lives com a mesma pontuação são
+// desempatadas pelo id, para que a paginação seja estável.
+package ranking
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+)
+
+// Live avaliada pelo ranking, junto da sua sessão de transmissão aberta.
+type Candidate struct {
+	LiveStream *models.LiveStream
+	// Nula caso a live esteja ativa sem uma sessão registrada
+	Session *models.StreamSession
+}
+
+// Avalia um aspecto de uma live.
+type Scorer interface {
+	// Nome usado na configuração dos pesos
+	Name() string
+	// Nota da live no instante `now`, entre 0 e 1
+	Score(candidate Candidate, now time.Time) float64
+}
+
+// Scorer com o peso da sua nota na pontuação final.
+type Weighted struct {
+	Scorer Scorer
+	Weight float64
+}
+
+// Seções do feed personalizado, na ordem em que aparecem.
+const (
+	// Lives de quem o usuário segue
+	SectionFollowing = iota
+	// Lives recomendadas, de quem o usuário não segue
+	SectionRecommended
+)
+
+// Live ordenada, com a pontuação final.
+type Ranked struct {
+	Candidate
+	Score float64
+	// Seção da live no feed. No feed de descoberta todas as lives são
+	// recomendadas
+	Section int
+}
+
+// Posição da live no feed, usada como cursor da paginação.
+func (r *Ranked) Position() models.Cursor {
+	score := r.Score
+	return models.Cursor{Section: r.Section, Key: &score, ID: r.LiveStream.ID}
+}
+
+// Combina as notas dos scorers em uma pontuação final.
+type Ranker struct {
+	scorers []Weighted
+}
+
+// Os scorers são somados na ordem dada, para que a pontuação de uma
+// mesma live seja sempre a mesma.
+func NewRanker(scorers ...Weighted) *Ranker {
+	return &Ranker{scorers: scorers}
+}
+
+// Soma ponderada das notas da live.
+func (r *Ranker) Score(candidate Candidate, now time.Time) float64 {
+	var score float64
+	for _, weighted := range r.scorers {
+		if weighted.Weight == 0 {
+			continue
+		}
+		score += weighted.Weight * weighted.Scorer.Score(candidate, now)
+	}
+
+	return score
+}
+
+// Pontua e ordena as lives, da maior pontuação para a menor, com empates
+// pelo id.
+func (r *Ranker) Rank(candidates []Candidate, now time.Time) []*Ranked {
+	ranked := make([]*Ranked, 0, len(candidates))
+	for _, candidate := range candidates {
+		ranked = append(ranked, &Ranked{Candidate: candidate, Score: r.Score(candidate, now)})
+	}
+
+	slices.SortFunc(ranked, func(a, b *Ranked) int {
+		return models.ComparePositions(a.Position(), b.Position())
+	})
+
+	return ranked
+}
@@ -0,0 +1,211 @@
+package ranking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/infra/repository/memory"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementa apenas a busca de sessões abertas usada pelo feed.
+type fakeSessions struct {
+	models.StreamSessionRepositoryInterface
+	sessions []*models.StreamSession
+}
+
+func (f *fakeSessions) GetOpenStreamSessions(ctx context.Context, liveStreamIDs []primitive.ObjectID) ([]*models.StreamSession, error) {
+	return f.sessions, nil
+}
+
+// Cria uma live ativa com o número de espectadores dado.
+func createActive(t *testing.T, repo models.LiveStreamRepositoryInterface, streamKey string, publisherID primitive.ObjectID, viewers int) primitive.ObjectID {
+	ctx := context.Background()
+
+	id, err := repo.CreateLiveStream(ctx, "Stream", "fake-thumbnail", streamKey, publisherID)
+	require.NoError(t, err)
+
+	objectID := id.(primitive.ObjectID)
+	require.NoError(t, repo.UpdateLiveStream(ctx, objectID, bson.M{"live_stream_status": true, "viewer_count": viewers}))
+
+	return objectID
+}
+
+// Percorre todas as páginas a partir da primeira, retornando os ids na
+// ordem e o cursor da página anterior à última.
+func walk(t *testing.T, limit int, page func(req models.PageRequest) (*models.Page[*Ranked], error)) ([]primitive.ObjectID, string) {
+	var order []primitive.ObjectID
+	req := models.PageRequest{Limit: limit}
+	for {
+		current, err := page(req)
+		require.NoError(t, err)
+		for _, ranked := range current.Items {
+			order = append(order, ranked.LiveStream.ID)
+		}
+
+		if current.NextCursor == "" {
+			return order, current.PrevCursor
+		}
+		req.Cursor, err = models.DecodeCursor(current.NextCursor)
+		require.NoError(t, err)
+	}
+}
+
+func TestScorers(t *testing.T) {
+	now := time.Now()
+	liveStream := &models.LiveStream{ViewerCount: 50, CreatedAt: now.Add(-24 * time.Hour)}
+	session := &models.StreamSession{StartedAt: now.Add(-30 * time.Minute), ViewerSum: 30, ViewerSamples: 3}
+
+	withSession := Candidate{LiveStream: liveStream, Session: session}
+	withoutSession := Candidate{LiveStream: liveStream}
+
+	assert.InDelta(t, 0.5, Viewers{HalfSaturation: 50}.Score(withSession, now), 1e-9)
+	assert.InDelta(t, 0.5, SessionAge{HalfSaturation: 30 * time.Minute}.Score(withSession, now), 1e-9)
+	assert.InDelta(t, 0.5, Recency{HalfLife: 24 * time.Hour}.Score(withSession, now), 1e-9)
+	// De uma média de 10 para 50 espectadores o crescimento satura
+	assert.InDelta(t, 1, Growth{}.Score(withSession, now), 1e-9)
+
+	assert.Zero(t, SessionAge{HalfSaturation: time.Minute}.Score(withoutSession, now))
+	assert.InDelta(t, 0.5, Growth{}.Score(withoutSession, now), 1e-9)
+}
+
+func TestRankTies(t *testing.T) {
+	now := time.Now()
+	ranker := NewRanker(Weighted{Scorer: Viewers{HalfSaturation: 50}, Weight: 1})
+
+	ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+	candidates := []Candidate{
+		{LiveStream: &models.LiveStream{ID: ids[2], ViewerCount: 5}},
+		{LiveStream: &models.LiveStream{ID: ids[1], ViewerCount: 5}},
+		{LiveStream: &models.LiveStream{ID: ids[0], ViewerCount: 10}},
+	}
+
+	// Empates são desempatados pelo id, independente da ordem de entrada
+	ranked := ranker.Rank(candidates, now)
+	require.Len(t, ranked, 3)
+	assert.Equal(t, ids[0], ranked[0].LiveStream.ID)
+	assert.Equal(t, ids[1], ranked[1].LiveStream.ID)
+	assert.Equal(t, ids[2], ranked[2].LiveStream.ID)
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWeights(), weights)
+
+	weights, err = ParseWeights("viewers=2, recency=0")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, weights["viewers"])
+	assert.Zero(t, weights["recency"])
+	assert.Equal(t, DefaultWeights()["growth"], weights["growth"])
+
+	for _, spec := range []string{"viewers", "unknown=1", "viewers=-1", "viewers=abc"} {
+		_, err := ParseWeights(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestFeedPage(t *testing.T) {
+	ctx := context.Background()
+	liveStreams := memory.NewLiveStreamRepository()
+
+	var ids []primitive.ObjectID
+	for i, viewers := range []int{5, 20, 5, 0} {
+		ids = append(ids, createActive(t, liveStreams, "streamkey-"+string(rune('a'+i)), primitive.NewObjectID(), viewers))
+	}
+
+	// Uma live offline não entra no feed
+	_, err := liveStreams.CreateLiveStream(ctx, "Offline", "fake-thumbnail", "streamkey-offline", primitive.NewObjectID())
+	require.NoError(t, err)
+
+	sessions := &fakeSessions{sessions: []*models.StreamSession{
+		{LiveStreamID: ids[3], StartedAt: time.Now().Add(-time.Hour)},
+	}}
+	feed := NewFeed(liveStreams, sessions, NewRanker(
+		Weighted{Scorer: Viewers{HalfSaturation: 50}, Weight: 1},
+		Weighted{Scorer: SessionAge{HalfSaturation: time.Hour}, Weight: 0.2},
+	), DefaultMaxCandidates)
+
+	now := time.Now()
+	order, _ := walk(t, 3, func(req models.PageRequest) (*models.Page[*Ranked], error) {
+		return feed.Page(ctx, req, now)
+	})
+
+	// A sessão longa compensa a falta de espectadores da última live
+	assert.Equal(t, []primitive.ObjectID{ids[1], ids[3], ids[0], ids[2]}, order)
+
+	// Apenas as lives mais assistidas são pontuadas
+	capped := NewFeed(liveStreams, sessions, NewRanker(Weighted{Scorer: Viewers{HalfSaturation: 50}, Weight: 1}), 2)
+	order, _ = walk(t, 3, func(req models.PageRequest) (*models.Page[*Ranked], error) {
+		return capped.Page(ctx, req, now)
+	})
+	assert.Equal(t, []primitive.ObjectID{ids[1], ids[0]}, order)
+
+	// Um cursor de uma listagem por id não serve para o feed
+	_, err = feed.Page(ctx, models.PageRequest{Limit: 1, Cursor: &models.Cursor{ID: ids[0]}}, now)
+	assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+}
+
+func TestPersonalFeedPage(t *testing.T) {
+	ctx := context.Background()
+	liveStreams := memory.NewLiveStreamRepository()
+	followedID, otherID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	// Quem o usuário segue aparece primeiro, mesmo com menos espectadores
+	followedLow := createActive(t, liveStreams, "streamkey-a", followedID, 1)
+	followedHigh := createActive(t, liveStreams, "streamkey-b", followedID, 2)
+	otherHigh := createActive(t, liveStreams, "streamkey-c", otherID, 10)
+	otherMid := createActive(t, liveStreams, "streamkey-d", otherID, 5)
+	otherLow := createActive(t, liveStreams, "streamkey-e", primitive.NewObjectID(), 0)
+
+	feed := NewFeed(liveStreams, &fakeSessions{}, NewRanker(Weighted{Scorer: Viewers{HalfSaturation: 50}, Weight: 1}), DefaultMaxCandidates)
+	now := time.Now()
+	personal := func(following []primitive.ObjectID) func(req models.PageRequest) (*models.Page[*Ranked], error) {
+		return func(req models.PageRequest) (*models.Page[*Ranked], error) {
+			return feed.PersonalPage(ctx, req, following, nil, now)
+		}
+	}
+
+	for _, limit := range []int{1, 2, 3, 10} {
+		order, _ := walk(t, limit, personal([]primitive.ObjectID{followedID}))
+		assert.Equal(t, []primitive.ObjectID{followedHigh, followedLow, otherHigh, otherMid, otherLow}, order, "limit %d", limit)
+	}
+
+	// Voltando da última página, a página anterior atravessa as duas seções
+	_, prev := walk(t, 2, personal([]primitive.ObjectID{followedID}))
+	req := models.PageRequest{Limit: 2}
+	var err error
+	req.Cursor, err = models.DecodeCursor(prev)
+	require.NoError(t, err)
+
+	page, err := feed.PersonalPage(ctx, req, []primitive.ObjectID{followedID}, nil, now)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, otherHigh, page.Items[0].LiveStream.ID)
+	assert.Equal(t, otherMid, page.Items[1].LiveStream.ID)
+	assert.NotEmpty(t, page.PrevCursor)
+
+	req.Cursor, err = models.DecodeCursor(page.PrevCursor)
+	require.NoError(t, err)
+	page, err = feed.PersonalPage(ctx, req, []primitive.ObjectID{followedID}, nil, now)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, followedHigh, page.Items[0].LiveStream.ID)
+	assert.Empty(t, page.PrevCursor)
+
+	// Sem ninguém seguido, o feed é o de descoberta
+	order, _ := walk(t, 2, personal(nil))
+	assert.Equal(t, []primitive.ObjectID{otherHigh, otherMid, followedHigh, followedLow, otherLow}, order)
+
+	// Publishers ocultos somem das duas seções, mesmo quando seguidos
+	order, _ = walk(t, 2, func(req models.PageRequest) (*models.Page[*Ranked], error) {
+		return feed.PersonalPage(ctx, req, []primitive.ObjectID{followedID}, []primitive.ObjectID{followedID, otherID}, now)
+	})
+	assert.Equal(t, []primitive.ObjectID{otherLow}, order)
+}
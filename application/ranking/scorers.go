@@ -0,0 +1,73 @@
+package ranking
+
+import (
+	"math"
+	"time"
+)
+
+// Nota pelo número atual de espectadores. Cresce rápido nas primeiras
+// centenas e satura depois, para que as maiores lives não dominem o feed;
+// `HalfSaturation` espectadores valem 0.5.
+type Viewers struct {
+	HalfSaturation float64
+}
+
+func (Viewers) Name() string { return "viewers" }
+
+func (s Viewers) Score(candidate Candidate, now time.Time) float64 {
+	viewers := float64(max(candidate.LiveStream.ViewerCount, 0))
+	return saturate(viewers, s.HalfSaturation)
+}
+
+// Nota pelo crescimento da audiência: o número atual de espectadores
+// comparado à média da sessão. Lives sem amostras ficam neutras (0.5).
+type Growth struct{}
+
+func (Growth) Name() string { return "growth" }
+
+func (Growth) Score(candidate Candidate, now time.Time) float64 {
+	session := candidate.Session
+	if session == nil || session.ViewerSamples == 0 {
+		return 0.5
+	}
+
+	average := float64(session.ViewerSum) / float64(session.ViewerSamples)
+	growth := (float64(candidate.LiveStream.ViewerCount) - average) / (average + 1)
+
+	return (max(-1, min(growth, 1)) + 1) / 2
+}
+
+// Nota pela duração da sessão atual, favorecendo transmissões que já se
+// estabilizaram; uma sessão com `HalfSaturation` de duração vale 0.5.
+type SessionAge struct {
+	HalfSaturation time.Duration
+}
+
+func (SessionAge) Name() string { return "session_age" }
+
+func (s SessionAge) Score(candidate Candidate, now time.Time) float64 {
+	if candidate.Session == nil {
+		return 0
+	}
+
+	age := max(now.Sub(candidate.Session.StartedAt), 0)
+	return saturate(age.Seconds(), s.HalfSaturation.Seconds())
+}
+
+// Nota pela idade da live, favorecendo canais novos; a nota cai pela
+// metade a cada `HalfLife`.
+type Recency struct {
+	HalfLife time.Duration
+}
+
+func (Recency) Name() string { return "recency" }
+
+func (s Recency) Score(candidate Candidate, now time.Time) float64 {
+	age := max(now.Sub(candidate.LiveStream.CreatedAt), 0)
+	return math.Exp2(-age.Seconds() / s.HalfLife.Seconds())
+}
+
+// Mapeia `value` (não negativo) em [0, 1), valendo 0.5 em `half`.
+func saturate(value, half float64) float64 {
+	return value / (value + half)
+}
@@ -0,0 +1,69 @@
+package ranking
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scorers disponíveis, na ordem em que são somados.
+func defaultScorers() []Scorer {
+	return []Scorer{
+		Viewers{HalfSaturation: 50},
+		Growth{},
+		SessionAge{HalfSaturation: 30 * time.Minute},
+		Recency{HalfLife: 24 * time.Hour},
+	}
+}
+
+// Peso de cada scorer quando não configurado.
+func DefaultWeights() map[string]float64 {
+	return map[string]float64{
+		"viewers":     1,
+		"growth":      0.5,
+		"session_age": 0.25,
+		"recency":     0.25,
+	}
+}
+
+// Lê pesos no formato `viewers=1,growth=0.5`. Scorers omitidos mantêm o
+// peso padrão, e um peso zero desliga o scorer.
+func ParseWeights(spec string) (map[string]float64, error) {
+	weights := DefaultWeights()
+
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(field, "=")
+		name = strings.TrimSpace(name)
+		if !found {
+			return nil, fmt.Errorf("missing weight for %q", name)
+		}
+
+		if _, ok := weights[name]; !ok {
+			return nil, fmt.Errorf("unknown scorer %q", name)
+		}
+
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("weight of %q must be a non-negative number", name)
+		}
+		weights[name] = weight
+	}
+
+	return weights, nil
+}
+
+// Cria um ranker com os scorers padrão e os pesos dados.
+func NewWeightedRanker(weights map[string]float64) *Ranker {
+	var scorers []Weighted
+	for _, scorer := range defaultScorers() {
+		scorers = append(scorers, Weighted{Scorer: scorer, Weight: weights[scorer.Name()]})
+	}
+
+	return NewRanker(scorers...)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/infra/repository/sqlrepo"
+	"github.com/gtvb/livestream/models"
+)
+
+const (
+	mongoBackend = "mongo"
+
+	defaultSQLOperationTimeout = 5 * time.Second
+)
+
+// Repositórios usados pelo servidor, todos no mesmo banco, junto da
+// unidade de trabalho desse banco.
+type repositories struct {
+	users          models.UserRepositoryInterface
+	liveStreams    models.LiveStreamRepositoryInterface
+	refreshTokens  models.RefreshTokenRepositoryInterface
+	streamSessions models.StreamSessionRepositoryInterface
+	viewerPresence models.ViewerPresenceRepositoryInterface
+	follows        models.FollowRepositoryInterface
+	relations      models.RelationRepositoryInterface
+	unitOfWork     models.UnitOfWork
+
+	// Encerra a conexão com o banco
+	close func()
+}
+
+// Cria os repositórios no backend indicado por DATABASE_BACKEND: `mongo`
+// (padrão), `postgres` ou `sqlite`. Todos os dados ficam no backend
+// escolhido; o Mongo só é usado (e exigido) pelo backend `mongo`, que
+// aplica as migrações pendentes ao iniciar. Nos
+// backends SQL a conexão é lida de DATABASE_URL e as tabelas são criadas
+// caso não existam.
+func newRepositories(ctx context.Context) (*repositories, error) {
+	backend := os.Getenv("DATABASE_BACKEND")
+	if backend == "" {
+		backend = mongoBackend
+	}
+
+	switch backend {
+	case mongoBackend:
+		return newMongoRepositories(ctx)
+	case sqlrepo.Postgres, sqlrepo.SQLite:
+		return newSQLRepositories(ctx, backend)
+	}
+
+	return nil, fmt.Errorf("unknown DATABASE_BACKEND %q", backend)
+}
+
+func newMongoRepositories(ctx context.Context) (*repositories, error) {
+	database, err := db.NewDb()
+	if err != nil {
+		return nil, err
+	}
+	disconnect := func() { database.Client().Disconnect(context.TODO()) }
+
+	// As migrações pendentes são aplicadas antes de tudo, já que o servidor
+	// depende do formato atual dos documentos (as chaves das lives, por
+	// exemplo, só são aceitas como hash) e os índices abaixo também
+	migrator, err := newMigrator(database)
+	if err == nil {
+		_, err = applyMigrations(ctx, migrator)
+	}
+	if err != nil {
+		disconnect()
+		return nil, fmt.Errorf("migrations: %w", err)
+	}
+
+	unitOfWork, err := repository.NewUnitOfWork(ctx, database)
+	if err != nil {
+		disconnect()
+		return nil, err
+	}
+
+	userRepository := repository.NewUserRepository(database, usersCollection)
+	liveStreamRepository := repository.NewLiveStreamRepository(database, liveStreamsCollection)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(database, "refresh_tokens")
+	streamSessionRepository := repository.NewStreamSessionRepository(database, "stream_sessions")
+	viewerPresenceRepository := repository.NewViewerPresenceRepository(database, "viewer_presence")
+	followRepository := repository.NewFollowRepository(database, followsCollection)
+	relationRepository := repository.NewRelationRepository(database, "relations")
+
+	// Os índices são criados antes de o servidor aceitar requisições, já
+	// que as restrições de unicidade dependem deles
+	indexed := map[string]interface {
+		EnsureIndexes(ctx context.Context) error
+	}{
+		"users":           userRepository,
+		"livestreams":     liveStreamRepository,
+		"refresh tokens":  refreshTokenRepository,
+		"stream sessions": streamSessionRepository,
+		"viewer presence": viewerPresenceRepository,
+		"follows":         followRepository,
+		"relations":       relationRepository,
+	}
+	for name, repo := range indexed {
+		if err := repo.EnsureIndexes(ctx); err != nil {
+			disconnect()
+			return nil, fmt.Errorf("%s indexes: %w", name, err)
+		}
+	}
+
+	return &repositories{
+		users:          userRepository,
+		liveStreams:    liveStreamRepository,
+		refreshTokens:  refreshTokenRepository,
+		streamSessions: streamSessionRepository,
+		viewerPresence: viewerPresenceRepository,
+		follows:        followRepository,
+		relations:      relationRepository,
+		unitOfWork:     unitOfWork,
+		close:          disconnect,
+	}, nil
+}
+
+// O prazo de cada operação é lido de DATABASE_OPERATION_TIMEOUT (padrão
+// de 5 segundos).
+func newSQLRepositories(ctx context.Context, backend string) (*repositories, error) {
+	operationTimeout := defaultSQLOperationTimeout
+	if timeout := os.Getenv("DATABASE_OPERATION_TIMEOUT"); timeout != "" {
+		var err error
+		if operationTimeout, err = time.ParseDuration(timeout); err != nil {
+			return nil, fmt.Errorf("DATABASE_OPERATION_TIMEOUT: %w", err)
+		}
+	}
+
+	sqlDb, err := sqlrepo.Open(backend, os.Getenv("DATABASE_URL"), operationTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sqlDb.ApplySchema(ctx); err != nil {
+		sqlDb.Close()
+		return nil, fmt.Errorf("%s schema: %w", backend, err)
+	}
+
+	return &repositories{
+		users:          sqlrepo.NewUserRepository(sqlDb),
+		liveStreams:    sqlrepo.NewLiveStreamRepository(sqlDb),
+		refreshTokens:  sqlrepo.NewRefreshTokenRepository(sqlDb),
+		streamSessions: sqlrepo.NewStreamSessionRepository(sqlDb),
+		viewerPresence: sqlrepo.NewViewerPresenceRepository(sqlDb),
+		follows:        sqlrepo.NewFollowRepository(sqlDb),
+		relations:      sqlrepo.NewRelationRepository(sqlDb),
+		unitOfWork:     sqlrepo.NewUnitOfWork(sqlDb),
+		close:          func() { sqlDb.Close() },
+	}, nil
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	refreshTokenBytes = 32
+	streamKeyBytes    = 24
+)
+
+// Gera um novo refresh token opaco, retornando o valor que deve ser
+// entregue ao cliente, o hash que deve ser persistido e sua expiração.
+func (tm *TokenManager) GenerateRefreshToken() (string, string, time.Time, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashRefreshToken(token), time.Now().Add(tm.refreshTokenTTL), nil
+}
+
+// Calcula o hash (SHA-256) de um refresh token. Como o token já possui
+// alta entropia, um hash rápido e determinístico é suficiente e permite
+// buscá-lo diretamente no banco.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Gera uma nova chave de stream, a credencial utilizada pelo software
+// de transmissão (ex: OBS) para publicar em uma live.
+func GenerateStreamKey() (string, error) {
+	buf := make([]byte, streamKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "live_" + base64.RawURLEncoding.EncodeToString(buf), nil
+}
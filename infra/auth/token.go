@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const tokenIssuer = "livestream"
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrMissingKey   = errors.New("missing token signing secret")
+)
+
+// Claims carregadas pelo token de acesso. O `sub` contém o id
+// (em hexadecimal) do usuário dono do token.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+}
+
+// Retorna o id do usuário contido no `sub` do token.
+func (c *AccessClaims) UserID() (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(c.Subject)
+}
+
+// Responsável por emitir e verificar os tokens de acesso
+// (JWT assinados com HS256) utilizados nas rotas protegidas,
+// além de gerar os refresh tokens que permitem renová-los.
+type TokenManager struct {
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+func NewTokenManager(secret string, accessTokenTTL, refreshTokenTTL time.Duration) *TokenManager {
+	return &TokenManager{
+		secret:          []byte(secret),
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// Gera um novo token de acesso para o usuário `userID`, retornando
+// também o instante em que ele expira.
+func (tm *TokenManager) GenerateAccessToken(userID primitive.ObjectID) (string, time.Time, error) {
+	if len(tm.secret) == 0 {
+		return "", time.Time{}, ErrMissingKey
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tm.accessTokenTTL)
+
+	claims := AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   userID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tm.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verifica a assinatura, o emissor e a expiração de `tokenString`,
+// retornando as claims caso o token seja válido.
+func (tm *TokenManager) VerifyAccessToken(tokenString string) (*AccessClaims, error) {
+	if len(tm.secret) == 0 {
+		return nil, ErrMissingKey
+	}
+
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return tm.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	if _, err := claims.UserID(); err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenerateAndVerifyAccessToken(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+	userID := primitive.NewObjectID()
+
+	token, expiresAt, err := tm.GenerateAccessToken(userID)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Second)
+
+	claims, err := tm.VerifyAccessToken(token)
+	assert.NoError(t, err)
+
+	id, err := claims.UserID()
+	assert.NoError(t, err)
+	assert.Equal(t, userID, id)
+}
+
+func TestVerifyAccessTokenWrongSecret(t *testing.T) {
+	token, _, err := NewTokenManager("test-secret", time.Minute, time.Hour).GenerateAccessToken(primitive.NewObjectID())
+	assert.NoError(t, err)
+
+	_, err = NewTokenManager("other-secret", time.Minute, time.Hour).VerifyAccessToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyAccessTokenExpired(t *testing.T) {
+	tm := NewTokenManager("test-secret", -time.Minute, time.Hour)
+
+	token, _, err := tm.GenerateAccessToken(primitive.NewObjectID())
+	assert.NoError(t, err)
+
+	_, err = tm.VerifyAccessToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestGenerateAccessTokenWithoutSecret(t *testing.T) {
+	_, _, err := NewTokenManager("", time.Minute, time.Hour).GenerateAccessToken(primitive.NewObjectID())
+	assert.ErrorIs(t, err, ErrMissingKey)
+}
+
+func TestGenerateRefreshToken(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+
+	token, hash, expiresAt, err := tm.GenerateRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, HashRefreshToken(token), hash)
+	assert.NotEqual(t, token, hash)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	other, _, _, _ := tm.GenerateRefreshToken()
+	assert.NotEqual(t, token, other)
+}
+
+func TestGenerateStreamKey(t *testing.T) {
+	key, err := GenerateStreamKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "live_"))
+
+	other, _ := GenerateStreamKey()
+	assert.NotEqual(t, key, other)
+}
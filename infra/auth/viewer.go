@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Emissor próprio dos tokens de espectador, para que eles não sejam
+// aceitos como tokens de acesso, nem o contrário.
+const viewerTokenIssuer = "livestream-viewer"
+
+const viewerSessionBytes = 16
+
+// Claims carregadas pelo token de espectador, emitido quando alguém
+// começa a assistir uma live. O `sub` contém o id da sessão do
+// espectador, gerado pelo servidor, `stream` o id da live e `viewer` o id
+// do usuário autenticado que pediu o token, vazio para anônimos.
+type ViewerClaims struct {
+	jwt.RegisteredClaims
+	StreamID string `json:"stream"`
+	ViewerID string `json:"viewer,omitempty"`
+}
+
+// Retorna o id da sessão do espectador contido no `sub` do token.
+func (c *ViewerClaims) SessionID() string {
+	return c.Subject
+}
+
+// Retorna o id do usuário dono do token, ou um id vazio caso o token
+// tenha sido emitido para um espectador anônimo.
+func (c *ViewerClaims) UserID() primitive.ObjectID {
+	id, _ := primitive.ObjectIDFromHex(c.ViewerID)
+	return id
+}
+
+// Gera um token de espectador para a live `streamID`, válido por `ttl`.
+// A sessão de um usuário autenticado (`viewerID`) é sempre a mesma, de
+// forma que ele conta como um único espectador; a de um anônimo é nova a
+// cada token. Retorna também o instante em que o token expira.
+func (tm *TokenManager) GenerateViewerToken(streamID, viewerID primitive.ObjectID, ttl time.Duration) (string, time.Time, error) {
+	if len(tm.secret) == 0 {
+		return "", time.Time{}, ErrMissingKey
+	}
+
+	var sessionID, viewer string
+	if viewerID.IsZero() {
+		buf := make([]byte, viewerSessionBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return "", time.Time{}, err
+		}
+		sessionID = "anon-" + base64.RawURLEncoding.EncodeToString(buf)
+	} else {
+		viewer = viewerID.Hex()
+		sessionID = "user-" + viewer
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	claims := ViewerClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    viewerTokenIssuer,
+			Subject:   sessionID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		StreamID: streamID.Hex(),
+		ViewerID: viewer,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tm.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// Verifica a assinatura, o emissor e a expiração de `tokenString`, e se
+// ele foi emitido para a live `streamID`, retornando as claims caso o
+// token seja válido.
+func (tm *TokenManager) VerifyViewerToken(tokenString string, streamID primitive.ObjectID) (*ViewerClaims, error) {
+	if len(tm.secret) == 0 {
+		return nil, ErrMissingKey
+	}
+
+	var claims ViewerClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return tm.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(viewerTokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidToken, err)
+	}
+
+	if claims.StreamID != streamID.Hex() || claims.Subject == "" {
+		return nil, errors.Join(ErrInvalidToken, errors.New("token issued for another stream"))
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenerateAndVerifyViewerToken(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+	streamID := primitive.NewObjectID()
+
+	token, expiresAt, err := tm.GenerateViewerToken(streamID, primitive.NilObjectID, time.Hour)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	claims, err := tm.VerifyViewerToken(token, streamID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.SessionID())
+	assert.True(t, claims.UserID().IsZero())
+
+	// Cada token anônimo tem a sua própria sessão
+	other, _, err := tm.GenerateViewerToken(streamID, primitive.NilObjectID, time.Hour)
+	assert.NoError(t, err)
+	otherClaims, err := tm.VerifyViewerToken(other, streamID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.SessionID(), otherClaims.SessionID())
+}
+
+// Os tokens de um usuário autenticado compartilham a mesma sessão.
+func TestAuthenticatedViewerToken(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+	streamID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	var sessions []string
+	for range 2 {
+		token, _, err := tm.GenerateViewerToken(streamID, userID, time.Hour)
+		assert.NoError(t, err)
+
+		claims, err := tm.VerifyViewerToken(token, streamID)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, claims.UserID())
+		sessions = append(sessions, claims.SessionID())
+	}
+
+	assert.Equal(t, sessions[0], sessions[1])
+}
+
+func TestVerifyViewerTokenOtherStream(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+
+	token, _, err := tm.GenerateViewerToken(primitive.NewObjectID(), primitive.NilObjectID, time.Hour)
+	assert.NoError(t, err)
+
+	_, err = tm.VerifyViewerToken(token, primitive.NewObjectID())
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyViewerTokenExpired(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+	streamID := primitive.NewObjectID()
+
+	token, _, err := tm.GenerateViewerToken(streamID, primitive.NilObjectID, -time.Minute)
+	assert.NoError(t, err)
+
+	_, err = tm.VerifyViewerToken(token, streamID)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+// Tokens de acesso e de espectador não são intercambiáveis.
+func TestViewerAndAccessTokensAreDistinct(t *testing.T) {
+	tm := NewTokenManager("test-secret", time.Minute, time.Hour)
+	id := primitive.NewObjectID()
+
+	accessToken, _, err := tm.GenerateAccessToken(id)
+	assert.NoError(t, err)
+	_, err = tm.VerifyViewerToken(accessToken, id)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	viewerToken, _, err := tm.GenerateViewerToken(id, primitive.NilObjectID, time.Hour)
+	assert.NoError(t, err)
+	_, err = tm.VerifyAccessToken(viewerToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
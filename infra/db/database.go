@@ -4,18 +4,35 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const defaultOperationTimeout = 5 * time.Second
+
 type Database struct {
 	*mongo.Database
+
+	// Prazo máximo de cada operação feita pelos repositórios.
+	// Um valor zero desativa o prazo, deixando apenas o do contexto
+	OperationTimeout time.Duration
+}
+
+// Deriva de `ctx` um contexto com o prazo de operação configurado.
+func (d *Database) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d.OperationTimeout)
 }
 
 // Inicia uma nova conexão com o banco Mongo, já
 // selecionando a Database contida na variável de
-// ambiente MONGODB_DATABASE_NAME.
+// ambiente MONGODB_DATABASE_NAME. O prazo de cada operação
+// é lido de MONGODB_OPERATION_TIMEOUT (padrão de 5 segundos).
 func NewDb() (*Database, error) {
 	mongoUri := os.Getenv("MONGODB_CONNECTION_STRING")
 	mongoDatabaseName := os.Getenv("MONGODB_DATABASE_NAME")
@@ -39,7 +56,16 @@ func NewDb() (*Database, error) {
 		return nil, err
 	}
 
+	operationTimeout := defaultOperationTimeout
+	if timeout := os.Getenv("MONGODB_OPERATION_TIMEOUT"); timeout != "" {
+		operationTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
 	database := client.Database(mongoDatabaseName)
 
-	return &Database{database}, nil
+	return &Database{Database: database, OperationTimeout: operationTimeout}, nil
 }
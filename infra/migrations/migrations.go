@@ -0,0 +1,181 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Coleção onde ficam registradas as versões já aplicadas.
+const SchemaMigrationsCollection = "schema_migrations"
+
+var (
+	ErrIrreversible    = errors.New("migration cannot be reverted")
+	ErrNothingToRevert = errors.New("no migration has been applied")
+)
+
+// Uma alteração no formato dos documentos do banco. `Up` e `Down` precisam
+// ser idempotentes: executá-las novamente sobre dados já migrados não pode
+// ter efeito. Migrações sem `Down` não podem ser revertidas.
+type Migration struct {
+	Version     int
+	Description string
+
+	Up   func(ctx context.Context, database *db.Database) error
+	Down func(ctx context.Context, database *db.Database) error
+}
+
+// Registro de uma migração aplicada, guardado em `schema_migrations`.
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Situação de uma migração, retornada por `Status`.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	// Momento em que a migração foi aplicada, nulo caso esteja pendente
+	AppliedAt *time.Time
+}
+
+// Aplica e reverte migrações, mantendo o registro das versões aplicadas.
+type Migrator struct {
+	Db         *db.Database
+	migrations []Migration
+}
+
+// Cria um Migrator para as migrações informadas, que precisam ter
+// versões positivas e únicas. A ordem de execução segue as versões.
+func NewMigrator(database *db.Database, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has an invalid version %d", m.Description, m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has no Up function", m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("duplicated migration version %d", m.Version)
+		}
+	}
+
+	return &Migrator{Db: database, migrations: sorted}, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	coll := m.Db.Collection(SchemaMigrationsCollection)
+
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []appliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	return applied, nil
+}
+
+// Aplica, em ordem, todas as migrações pendentes, retornando as
+// versões aplicadas. A execução para na primeira falha.
+func (m *Migrator) Up(ctx context.Context) ([]int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := m.Db.Collection(SchemaMigrationsCollection)
+	versions := make([]int, 0)
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		if err := migration.Up(ctx, m.Db); err != nil {
+			return versions, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		// Upsert para que uma execução concorrente não falhe ao registrar
+		record := appliedMigration{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now()}
+		_, err := coll.ReplaceOne(ctx, bson.M{"_id": record.Version}, record, options.Replace().SetUpsert(true))
+		if err != nil {
+			return versions, err
+		}
+
+		versions = append(versions, migration.Version)
+	}
+
+	return versions, nil
+}
+
+// Reverte a última migração aplicada, retornando sua versão.
+func (m *Migrator) Down(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if migration.Down == nil {
+			return 0, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, ErrIrreversible)
+		}
+
+		if err := migration.Down(ctx, m.Db); err != nil {
+			return 0, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+
+		coll := m.Db.Collection(SchemaMigrationsCollection)
+		if _, err := coll.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+			return 0, err
+		}
+
+		return migration.Version, nil
+	}
+
+	return 0, ErrNothingToRevert
+}
+
+// Lista todas as migrações conhecidas e se já foram aplicadas.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Description: migration.Description}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
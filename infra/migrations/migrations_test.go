@@ -0,0 +1,143 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var testCollections = Collections{
+	Users:       utils.UserCollectionTest,
+	LiveStreams: utils.LiveStreamCollectionTest,
+	Follows:     utils.FollowCollectionTest,
+}
+
+func noop(ctx context.Context, database *db.Database) error { return nil }
+
+func TestNewMigratorValidation(t *testing.T) {
+	_, err := NewMigrator(nil, []Migration{{Version: 1, Up: noop}, {Version: 1, Up: noop}})
+	assert.Error(t, err)
+
+	_, err = NewMigrator(nil, []Migration{{Version: 0, Up: noop}})
+	assert.Error(t, err)
+
+	_, err = NewMigrator(nil, []Migration{{Version: 1}})
+	assert.Error(t, err)
+
+	migrator, err := NewMigrator(nil, []Migration{{Version: 2, Up: noop}, {Version: 1, Up: noop}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrator.migrations[0].Version)
+}
+
+func TestMigrateLiveStreams(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	ctx := context.Background()
+	coll := container.Database.Collection(utils.LiveStreamCollectionTest)
+
+	// Documento no formato anterior às migrações
+	_, err := coll.InsertOne(ctx, bson.M{"name": "Legacy", "thubmnail": "thumb.png", "stream_key": "legacy-key"})
+	assert.NoError(t, err)
+
+	migrator, err := NewMigrator(container.Database, All(testCollections))
+	assert.NoError(t, err)
+
+	versions, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions)
+
+	var ls models.LiveStream
+	assert.NoError(t, coll.FindOne(ctx, bson.M{"name": "Legacy"}).Decode(&ls))
+	assert.Equal(t, "thumb.png", ls.Thumbnail)
+	assert.Equal(t, models.HashStreamKey("legacy-key"), ls.StreamKeyHash)
+
+	count, err := coll.CountDocuments(ctx, bson.M{"stream_key": bson.M{"$exists": true}})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	// Executar novamente não aplica nada
+	versions, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, versions)
+
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt)
+	}
+
+	// O hash da chave não pode ser desfeito
+	version, err := migrator.Down(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+	_, err = migrator.Down(ctx)
+	assert.ErrorIs(t, err, ErrIrreversible)
+}
+
+func TestMigrateFollows(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	ctx := context.Background()
+	users := container.Database.Collection(utils.UserCollectionTest)
+	follows := container.Database.Collection(utils.FollowCollectionTest)
+
+	// Listas no formato anterior, com um id repetido e o próprio usuário
+	follower, followee, lonely := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	_, err := users.InsertMany(ctx, []interface{}{
+		bson.M{"_id": follower, "username": "follower", "following": bson.A{followee, followee, follower}},
+		bson.M{"_id": followee, "username": "followee", "following": bson.A{}},
+		bson.M{"_id": lonely, "username": "lonely"},
+	})
+	assert.NoError(t, err)
+
+	migrator, err := NewMigrator(container.Database, All(testCollections))
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	var moved []models.Follow
+	cursor, err := follows.Find(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.NoError(t, cursor.All(ctx, &moved))
+	if assert.Len(t, moved, 1) {
+		assert.Equal(t, follower, moved[0].FollowerID)
+		assert.Equal(t, followee, moved[0].FolloweeID)
+	}
+
+	count, err := users.CountDocuments(ctx, bson.M{"following": bson.M{"$exists": true}})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	// Índice como o criado pela aplicação, que precisa sobreviver à reversão
+	_, err = follows.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "followee_id", Value: 1}}})
+	assert.NoError(t, err)
+
+	// A reversão reconstrói as listas sem as entradas descartadas
+	_, err = migrator.Down(ctx)
+	assert.NoError(t, err)
+
+	var restored struct {
+		Following []primitive.ObjectID `bson:"following"`
+	}
+	assert.NoError(t, users.FindOne(ctx, bson.M{"_id": follower}).Decode(&restored))
+	assert.Equal(t, []primitive.ObjectID{followee}, restored.Following)
+	assert.NoError(t, users.FindOne(ctx, bson.M{"_id": lonely}).Decode(&restored))
+	assert.Empty(t, restored.Following)
+
+	count, err = follows.CountDocuments(ctx, bson.M{})
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	indexes, err := follows.Indexes().ListSpecifications(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 2)
+}
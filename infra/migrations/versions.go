@@ -0,0 +1,179 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Nomes das coleções sobre as quais as migrações atuam.
+type Collections struct {
+	Users       string
+	LiveStreams string
+	Follows     string
+}
+
+// Todas as migrações da aplicação. Novas migrações devem ser adicionadas
+// ao final, com uma versão maior que a de todas as anteriores.
+func All(collections Collections) []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "rename livestreams.thubmnail to thumbnail",
+			Up: func(ctx context.Context, database *db.Database) error {
+				return renameField(ctx, database.Collection(collections.LiveStreams), "thubmnail", "thumbnail")
+			},
+			Down: func(ctx context.Context, database *db.Database) error {
+				return renameField(ctx, database.Collection(collections.LiveStreams), "thumbnail", "thubmnail")
+			},
+		},
+		{
+			// Sem Down: a chave original não pode ser recuperada a partir do hash
+			Version:     2,
+			Description: "hash legacy plaintext livestreams.stream_key",
+			Up: func(ctx context.Context, database *db.Database) error {
+				return hashLegacyStreamKeys(ctx, database.Collection(collections.LiveStreams))
+			},
+		},
+		{
+			Version:     3,
+			Description: "move users.following into the follows collection",
+			Up: func(ctx context.Context, database *db.Database) error {
+				return moveFollowLists(ctx, database.Collection(collections.Users), database.Collection(collections.Follows))
+			},
+			Down: func(ctx context.Context, database *db.Database) error {
+				return restoreFollowLists(ctx, database.Collection(collections.Users), database.Collection(collections.Follows))
+			},
+		},
+	}
+}
+
+func renameField(ctx context.Context, coll *mongo.Collection, from, to string) error {
+	filter := bson.M{from: bson.M{"$exists": true}}
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$rename": bson.M{from: to}})
+	return err
+}
+
+// Substitui a chave em texto puro das lives criadas antes de as chaves
+// passarem a ser guardadas apenas como hash.
+func hashLegacyStreamKeys(ctx context.Context, coll *mongo.Collection) error {
+	filter := bson.M{"stream_key": bson.M{"$exists": true}}
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	var legacy []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		StreamKey string             `bson:"stream_key"`
+	}
+	if err := cursor.All(ctx, &legacy); err != nil {
+		return err
+	}
+
+	for _, ls := range legacy {
+		update := bson.M{
+			"$set":   bson.M{"stream_key_hash": models.HashStreamKey(ls.StreamKey)},
+			"$unset": bson.M{"stream_key": ""},
+		}
+		if _, err := coll.UpdateByID(ctx, ls.ID, update); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Cria um follow para cada id das listas `following` dos usuários e
+// remove as listas. Ids repetidos e o próprio usuário são descartados, e
+// follows já existentes não são alterados.
+func moveFollowLists(ctx context.Context, users, follows *mongo.Collection) error {
+	filter := bson.M{"following": bson.M{"$exists": true}}
+
+	cursor, err := users.Find(ctx, filter, options.Find().SetProjection(bson.M{"following": 1}))
+	if err != nil {
+		return err
+	}
+
+	var legacy []struct {
+		ID        primitive.ObjectID   `bson:"_id"`
+		Following []primitive.ObjectID `bson:"following"`
+	}
+	if err := cursor.All(ctx, &legacy); err != nil {
+		return err
+	}
+
+	// O momento real de cada follow não é conhecido
+	now := time.Now()
+
+	for _, user := range legacy {
+		var upserts []mongo.WriteModel
+		for _, followee := range user.Following {
+			if followee == user.ID {
+				continue
+			}
+
+			upserts = append(upserts, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"follower_id": user.ID, "followee_id": followee}).
+				SetUpdate(bson.M{"$setOnInsert": bson.M{"created_at": now}}).
+				SetUpsert(true))
+		}
+
+		if len(upserts) > 0 {
+			if _, err := follows.BulkWrite(ctx, upserts); err != nil {
+				return err
+			}
+		}
+
+		if _, err := users.UpdateByID(ctx, user.ID, bson.M{"$unset": bson.M{"following": ""}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reconstrói as listas `following` a partir dos follows e esvazia a
+// coleção, mantendo os índices criados pela aplicação. Só usuários ainda
+// sem lista são alterados.
+func restoreFollowLists(ctx context.Context, users, follows *mongo.Collection) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{"_id": "$follower_id", "following": bson.M{"$push": "$followee_id"}}}},
+	}
+
+	cursor, err := follows.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var lists []struct {
+		ID        primitive.ObjectID   `bson:"_id"`
+		Following []primitive.ObjectID `bson:"following"`
+	}
+	if err := cursor.All(ctx, &lists); err != nil {
+		return err
+	}
+
+	for _, list := range lists {
+		filter := bson.M{"_id": list.ID, "following": bson.M{"$exists": false}}
+		if _, err := users.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"following": list.Following}}); err != nil {
+			return err
+		}
+	}
+
+	filter := bson.M{"following": bson.M{"$exists": false}}
+	if _, err := users.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"following": bson.A{}}}); err != nil {
+		return err
+	}
+
+	_, err = follows.DeleteMany(ctx, bson.M{})
+	return err
+}
@@ -0,0 +1,99 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/infra/repository/repositorytest"
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func setupConformanceDatabase(t *testing.T) *utils.TestContainer {
+	container, err := utils.NewTestContainer("ls-db-test")
+	require.NoError(t, err)
+
+	require.NoError(t, container.SetupDatabaseWrapper())
+	t.Cleanup(func() { container.Terminate() })
+
+	return container
+}
+
+// Cada subteste usa uma coleção nova, para que os repositórios criados
+// pela suíte não compartilhem documentos
+func uniqueCollectionName(prefix string) string {
+	return prefix + "_" + primitive.NewObjectID().Hex()
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) models.UserRepositoryInterface {
+		repo := repository.NewUserRepository(container.Database, uniqueCollectionName(utils.UserCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestLiveStreamRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunLiveStreamRepositoryTests(t, func(t *testing.T) models.LiveStreamRepositoryInterface {
+		repo := repository.NewLiveStreamRepository(container.Database, uniqueCollectionName(utils.LiveStreamCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestRefreshTokenRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunRefreshTokenRepositoryTests(t, func(t *testing.T) models.RefreshTokenRepositoryInterface {
+		repo := repository.NewRefreshTokenRepository(container.Database, uniqueCollectionName(utils.RefreshTokenCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestStreamSessionRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunStreamSessionRepositoryTests(t, func(t *testing.T) models.StreamSessionRepositoryInterface {
+		repo := repository.NewStreamSessionRepository(container.Database, uniqueCollectionName(utils.StreamSessionCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestViewerPresenceRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunViewerPresenceRepositoryTests(t, func(t *testing.T) models.ViewerPresenceRepositoryInterface {
+		repo := repository.NewViewerPresenceRepository(container.Database, uniqueCollectionName(utils.ViewerPresenceCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestFollowRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunFollowRepositoryTests(t, func(t *testing.T) models.FollowRepositoryInterface {
+		repo := repository.NewFollowRepository(container.Database, uniqueCollectionName(utils.FollowCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
+
+func TestRelationRepositoryConformance(t *testing.T) {
+	container := setupConformanceDatabase(t)
+
+	repositorytest.RunRelationRepositoryTests(t, func(t *testing.T) models.RelationRepositoryInterface {
+		repo := repository.NewRelationRepository(container.Database, uniqueCollectionName(utils.RelationCollectionTest))
+		require.NoError(t, repo.EnsureIndexes(context.Background()))
+		return repo
+	})
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Erros retornados pelos repositórios. O erro original do driver
+// continua acessível via `errors.Is`/`errors.As`.
+var (
+	// Nenhum documento corresponde ao filtro da operação
+	ErrNotFound = errors.New("document not found")
+	// A operação viola um índice único
+	ErrConflict = errors.New("document already exists")
+	// O documento existe, mas a atualização não alterou nada
+	ErrNoChange = errors.New("no changes were applied")
+	// O banco não respondeu a tempo ou não pôde ser alcançado
+	ErrUnavailable = errors.New("database unavailable")
+	// O cursor de paginação não pertence à listagem consultada
+	ErrInvalidCursor = models.ErrInvalidCursor
+)
+
+// Classifica um erro do driver em um dos erros do pacote.
+// Erros não reconhecidos são retornados sem alteração.
+func wrapError(err error) error {
+	var selectionErr topology.ServerSelectionError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled),
+		mongo.IsTimeout(err),
+		mongo.IsNetworkError(err),
+		errors.As(err, &selectionErr):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repositório de acesso aos dados da entidade `Follow`.
+// Qualquer repositório precisa implementar a interface
+// `FollowRepositoryInterface` para ser utilizada de forma
+// válida pelo servidor HTTP.
+type FollowRepository struct {
+	followCollectionName string
+	Db                   *db.Database
+}
+
+func NewFollowRepository(db *db.Database, followCollectionName string) *FollowRepository {
+	return &FollowRepository{
+		followCollectionName: followCollectionName,
+		Db:                   db,
+	}
+}
+
+// Cria o índice único do par seguidor/seguido, que também atende à
+// listagem de seguidos, e o índice da listagem de seguidores.
+func (fr *FollowRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "followee_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "followee_id", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+// O follow é criado por upsert, e não por inserção, para que um follow
+// repetido não seja um erro de chave duplicada, o que abortaria a
+// transação em andamento.
+func (fr *FollowRepository) Follow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	if followerID == followeeID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfFollow, followerID.Hex())
+	}
+
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+	follow := models.NewFollow(followerID, followeeID)
+
+	filter := bson.M{"follower_id": followerID, "followee_id": followeeID}
+	update := bson.M{"$setOnInsert": bson.M{"created_at": follow.CreatedAt}}
+
+	res, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.UpsertedCount == 0 {
+		return fmt.Errorf("%w: %s already follows %s", ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+
+	res, err := coll.DeleteOne(ctx, bson.M{"follower_id": followerID, "followee_id": followeeID})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%w: %s does not follow %s", ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) DeleteUserFollows(ctx context.Context, userID primitive.ObjectID) ([]*models.Follow, error) {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+	filter := bson.M{"$or": bson.A{bson.M{"follower_id": userID}, bson.M{"followee_id": userID}}}
+
+	follows, err := fr.findFollows(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(follows) == 0 {
+		return follows, nil
+	}
+
+	// Apenas os follows lidos são apagados, para que o retorno corresponda
+	// exatamente ao que foi removido
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.ID)
+	}
+
+	if _, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return follows, nil
+}
+
+func (fr *FollowRepository) GetFollowingIDs(ctx context.Context, followerID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"followee_id": 1})
+
+	follows, err := fr.findFollows(ctx, bson.M{"follower_id": followerID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.FolloweeID)
+	}
+
+	return ids, nil
+}
+
+func (fr *FollowRepository) GetFollowers(ctx context.Context, followeeID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+	return findPage(ctx, coll, bson.M{"followee_id": followeeID}, "", page, (*models.Follow).Position)
+}
+
+func (fr *FollowRepository) GetFollowing(ctx context.Context, followerID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+	return findPage(ctx, coll, bson.M{"follower_id": followerID}, "", page, (*models.Follow).Position)
+}
+
+func (fr *FollowRepository) CountFollows(ctx context.Context) (map[primitive.ObjectID]models.FollowCounts, error) {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	counts := make(map[primitive.ObjectID]models.FollowCounts)
+
+	// Uma agregação por lado do follow. Os resultados são lidos um a um:
+	// juntá-los em um único documento (como um `$facet`) esbarraria no
+	// limite de 16MB por documento
+	err := fr.countBy(ctx, "$followee_id", func(id primitive.ObjectID, count int) {
+		user := counts[id]
+		user.Followers = count
+		counts[id] = user
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = fr.countBy(ctx, "$follower_id", func(id primitive.ObjectID, count int) {
+		user := counts[id]
+		user.Following = count
+		counts[id] = user
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Conta os follows agrupados por `field`, entregando cada contagem a `add`.
+func (fr *FollowRepository) countBy(ctx context.Context, field string, add func(id primitive.ObjectID, count int)) error {
+	coll := fr.Db.Collection(fr.followCollectionName)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": field, "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return wrapError(err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var c struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Count int                `bson:"count"`
+		}
+		if err := cursor.Decode(&c); err != nil {
+			return wrapError(err)
+		}
+		add(c.ID, c.Count)
+	}
+
+	return wrapError(cursor.Err())
+}
+
+func (fr *FollowRepository) findFollows(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]*models.Follow, error) {
+	ctx, cancel := fr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := fr.Db.Collection(fr.followCollectionName)
+
+	cursor, err := coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	follows := make([]*models.Follow, 0)
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return follows, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFollow(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	followRepo := NewFollowRepository(container.Database, utils.FollowCollectionTest)
+	assert.NoError(t, followRepo.EnsureIndexes(context.Background()))
+
+	follower, followee := primitive.NewObjectID(), primitive.NewObjectID()
+
+	assert.NoError(t, followRepo.Follow(context.Background(), follower, followee))
+	// Seguir de novo não cria um segundo follow
+	assert.ErrorIs(t, followRepo.Follow(context.Background(), follower, followee), ErrNoChange)
+	assert.ErrorIs(t, followRepo.Follow(context.Background(), follower, follower), models.ErrSelfFollow)
+
+	ids, err := followRepo.GetFollowingIDs(context.Background(), follower)
+	assert.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{followee}, ids)
+
+	assert.NoError(t, followRepo.Unfollow(context.Background(), follower, followee))
+	assert.ErrorIs(t, followRepo.Unfollow(context.Background(), follower, followee), ErrNoChange)
+
+	ids, err = followRepo.GetFollowingIDs(context.Background(), follower)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestGetFollowers(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	followRepo := NewFollowRepository(container.Database, utils.FollowCollectionTest)
+	followee := primitive.NewObjectID()
+
+	var followers []primitive.ObjectID
+	for range 3 {
+		follower := primitive.NewObjectID()
+		assert.NoError(t, followRepo.Follow(context.Background(), follower, followee))
+		followers = append(followers, follower)
+	}
+
+	page, err := followRepo.GetFollowers(context.Background(), followee, models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, followers[0], page.Items[0].FollowerID)
+		assert.Equal(t, followers[1], page.Items[1].FollowerID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	cursor, err := models.DecodeCursor(page.NextCursor)
+	assert.NoError(t, err)
+
+	page, err = followRepo.GetFollowers(context.Background(), followee, models.PageRequest{Limit: 2, Cursor: cursor})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, followers[2], page.Items[0].FollowerID)
+	}
+	assert.Empty(t, page.NextCursor)
+
+	page, err = followRepo.GetFollowing(context.Background(), followers[0], models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, followee, page.Items[0].FolloweeID)
+	}
+}
+
+func TestDeleteUserFollows(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	followRepo := NewFollowRepository(container.Database, utils.FollowCollectionTest)
+	user, other, unrelated := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	assert.NoError(t, followRepo.Follow(context.Background(), user, other))
+	assert.NoError(t, followRepo.Follow(context.Background(), other, user))
+	assert.NoError(t, followRepo.Follow(context.Background(), other, unrelated))
+
+	counts, err := followRepo.CountFollows(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, models.FollowCounts{Followers: 1, Following: 2}, counts[other])
+	assert.Equal(t, models.FollowCounts{Followers: 1}, counts[unrelated])
+
+	deleted, err := followRepo.DeleteUserFollows(context.Background(), user)
+	assert.NoError(t, err)
+	assert.Len(t, deleted, 2)
+
+	counts, err = followRepo.CountFollows(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, models.FollowCounts{Following: 1}, counts[other])
+	assert.NotContains(t, counts, user)
+}
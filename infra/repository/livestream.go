@@ -9,6 +9,7 @@ import (
 	"github.com/gtvb/livestream/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -28,69 +29,176 @@ func NewLiveStreamRepository(db *db.Database, liveStreamCollectionName string) *
 	}
 }
 
-func (lr *LiveStreamRepository) CreateLiveStream(name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error) {
+// Cria o índice único da chave de stream, usado na autenticação do
+// broadcaster, e os índices das buscas por publisher, por lives ativas
+// e por lives removidas.
+func (lr *LiveStreamRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+
+	// Versões anteriores criavam o índice único sem filtro, o que impede
+	// mais de uma live sem hash (as criadas antes da migração das chaves)
+	if err := dropUnfilteredIndex(ctx, coll, "stream_key_hash_1"); err != nil {
+		return err
+	}
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// Apenas as lives com hash participam da unicidade
+		{
+			Keys: bson.D{{Key: "stream_key_hash", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"stream_key_hash": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "publisher_id", Value: 1}}},
+		// Lives ativas de quem o usuário segue
+		{Keys: bson.D{{Key: "publisher_id", Value: 1}, {Key: "live_stream_status", Value: 1}}},
+		{Keys: bson.D{{Key: "live_stream_status", Value: 1}}},
+		// Lives ativas mais assistidas, candidatas do feed
+		{Keys: bson.D{{Key: "live_stream_status", Value: 1}, {Key: "viewer_count", Value: -1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+// Remove o índice `name`, caso ele exista sem um filtro parcial.
+func dropUnfilteredIndex(ctx context.Context, coll *mongo.Collection, name string) error {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	var specs []struct {
+		Name                    string   `bson:"name"`
+		PartialFilterExpression bson.Raw `bson:"partialFilterExpression"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return wrapError(err)
+	}
+
+	for _, spec := range specs {
+		if spec.Name != name || spec.PartialFilterExpression != nil {
+			continue
+		}
+
+		_, err := coll.Indexes().DropOne(ctx, name)
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (lr *LiveStreamRepository) CreateLiveStream(ctx context.Context, name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
 	doc := models.NewLiveStream(name, thumbnail, publisherId, streamKey)
 
-	res, err := coll.InsertOne(context.TODO(), doc)
+	res, err := coll.InsertOne(ctx, doc)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return res.InsertedID, nil
 }
 
-func (lr *LiveStreamRepository) DeleteLiveStream(id primitive.ObjectID) error {
+func (lr *LiveStreamRepository) DeleteLiveStream(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return lr.updateLiveStream(ctx, id, bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+}
+
+func (lr *LiveStreamRepository) DeleteLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
-	filter := bson.M{"_id": id}
+	filter := notDeleted(bson.M{"publisher_id": id})
 
-	res, err := coll.DeleteOne(context.TODO(), filter)
+	res, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"deleted_at": deletedAt}})
 	if err != nil {
-		return err
+		return 0, wrapError(err)
+	}
+
+	return res.ModifiedCount, nil
+}
+
+func (lr *LiveStreamRepository) RestoreLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+	filter := bson.M{"publisher_id": id, "deleted_at": deletedAt}
+	update := bson.M{"$set": bson.M{"deleted_at": nil, "updated_at": time.Now()}}
+
+	res, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return res.ModifiedCount, nil
+}
+
+func (lr *LiveStreamRepository) PurgeLiveStream(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+
+	res, err := coll.DeleteOne(ctx, deleted(bson.M{"_id": id}))
+	if err != nil {
+		return wrapError(err)
 	}
 
 	if res.DeletedCount != 1 {
-		return fmt.Errorf("expected one document to be deleted, got %d", res.DeletedCount)
+		return fmt.Errorf("%w: no deleted live stream with _id %s", ErrNotFound, id.Hex())
 	}
 
 	return nil
 }
 
-func (lr *LiveStreamRepository) DeleteLiveStreamsByPublisher(id primitive.ObjectID) error {
+func (lr *LiveStreamRepository) PurgeLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
-	filter := bson.M{"publisher_id": id}
 
-	_, err := coll.DeleteMany(context.TODO(), filter)
+	res, err := coll.DeleteMany(ctx, bson.M{"publisher_id": id})
 	if err != nil {
-		return err
+		return 0, wrapError(err)
 	}
 
-	return nil
+	return res.DeletedCount, nil
 }
 
-func (lr *LiveStreamRepository) updateLiveStream(id primitive.ObjectID, updateQuery primitive.M) error {
+func (lr *LiveStreamRepository) updateLiveStream(ctx context.Context, id primitive.ObjectID, updateQuery interface{}) error {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
 
-	res, err := coll.UpdateByID(context.TODO(), id, updateQuery)
+	res, err := coll.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), updateQuery)
 	if err != nil {
-		return err
+		return wrapError(err)
 	}
 
 	if res.MatchedCount != 1 {
-		return fmt.Errorf("no match for _id %d", id)
+		return fmt.Errorf("%w: no match for _id %s", ErrNotFound, id.Hex())
 	}
 
 	if res.ModifiedCount != 1 {
-		return fmt.Errorf("expected one document to be updated, got %d", res.ModifiedCount)
+		return fmt.Errorf("%w: _id %s", ErrNoChange, id.Hex())
 	}
 
 	return nil
 }
 
-func (lr *LiveStreamRepository) UpdateLiveStream(id primitive.ObjectID, newData bson.M) error {
+func (lr *LiveStreamRepository) UpdateLiveStream(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
 	newData["updated_at"] = time.Now()
 
-	err := lr.updateLiveStream(id, bson.M{"$set": newData})
+	err := lr.updateLiveStream(ctx, id, bson.M{"$set": newData})
 	if err != nil {
 		return err
 	}
@@ -98,97 +206,144 @@ func (lr *LiveStreamRepository) UpdateLiveStream(id primitive.ObjectID, newData
 	return nil
 }
 
-func (lr *LiveStreamRepository) IncrementLiveStreamUserCount(id primitive.ObjectID) error {
+// Substitui a chave de stream da live, registrando a rotação no
+// histórico. A chave antiga deixa de ser aceita imediatamente.
+func (lr *LiveStreamRepository) RotateLiveStreamKey(ctx context.Context, id primitive.ObjectID, newStreamKey string, rotation *models.StreamKeyRotation) error {
+	update := bson.M{
+		"$set": bson.M{
+			"stream_key_hash": models.HashStreamKey(newStreamKey),
+			"updated_at":      time.Now(),
+		},
+		"$push": bson.M{"stream_key_rotations": rotation},
+	}
+	return lr.updateLiveStream(ctx, id, update)
+}
+
+func (lr *LiveStreamRepository) IncrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
 	update := bson.M{
 		"$set": bson.M{"updated_at": time.Now()},
 		"$inc": bson.M{"viewer_count": 1},
 	}
-	return lr.updateLiveStream(id, update)
+	return lr.updateLiveStream(ctx, id, update)
 }
 
-func (lr *LiveStreamRepository) DecrementLiveStreamUserCount(id primitive.ObjectID) error {
+// Decrementa o contador de espectadores, sem deixá-lo ficar negativo.
+func (lr *LiveStreamRepository) DecrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"updated_at":   time.Now(),
+			"viewer_count": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$viewer_count", 1}}}},
+		}}},
+	}
+	return lr.updateLiveStream(ctx, id, update)
+}
+
+func (lr *LiveStreamRepository) SetLiveStreamViewerCount(ctx context.Context, id primitive.ObjectID, viewers int) error {
 	update := bson.M{
-		"$set": bson.M{"updated_at": time.Now()},
-		"$inc": bson.M{"viewer_count": -1},
+		"$set": bson.M{"updated_at": time.Now(), "viewer_count": max(viewers, 0)},
 	}
-	return lr.updateLiveStream(id, update)
+	return lr.updateLiveStream(ctx, id, update)
 }
 
-func (lr *LiveStreamRepository) getLiveStreamByParam(fieldName string, param any) (*models.LiveStream, error) {
+func (lr *LiveStreamRepository) getLiveStreamByParam(ctx context.Context, fieldName string, param any) (*models.LiveStream, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	var liveStream models.LiveStream
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
 
-	filter := bson.M{fieldName: param}
+	filter := notDeleted(bson.M{fieldName: param})
 
-	res := coll.FindOne(context.TODO(), filter)
+	res := coll.FindOne(ctx, filter)
 	err := res.Decode(&liveStream)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return &liveStream, nil
 }
 
-func (lr *LiveStreamRepository) getLiveStreamByParamBatch(filter primitive.M) ([]*models.LiveStream, error) {
+func (lr *LiveStreamRepository) getLiveStreamByParamBatch(ctx context.Context, filter primitive.M, opts ...*options.FindOptions) ([]*models.LiveStream, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
 	var liveStreams []*models.LiveStream
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
 
-	cursor, err := coll.Find(context.TODO(), filter)
+	cursor, err := coll.Find(ctx, notDeleted(filter), opts...)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
-	err = cursor.All(context.TODO(), &liveStreams)
+	err = cursor.All(ctx, &liveStreams)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return liveStreams, nil
 }
 
-func (lr *LiveStreamRepository) GetLiveStreamById(id primitive.ObjectID) (*models.LiveStream, error) {
-	return lr.getLiveStreamByParam("_id", id)
+func (lr *LiveStreamRepository) GetLiveStreamById(ctx context.Context, id primitive.ObjectID) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, "_id", id)
 }
 
-func (lr *LiveStreamRepository) GetLiveStreamByName(name string) (*models.LiveStream, error) {
-	return lr.getLiveStreamByParam("name", name)
+func (lr *LiveStreamRepository) GetLiveStreamByName(ctx context.Context, name string) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, "name", name)
 }
 
-func (lr *LiveStreamRepository) GetLiveStreamByStreamKey(key string) (*models.LiveStream, error) {
-	return lr.getLiveStreamByParam("stream_key", key)
+func (lr *LiveStreamRepository) GetLiveStreamByStreamKey(ctx context.Context, key string) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, "stream_key_hash", models.HashStreamKey(key))
 }
 
-func (lr *LiveStreamRepository) GetAllLiveStreamsByUserId(id primitive.ObjectID) ([]*models.LiveStream, error) {
-	return lr.getLiveStreamByParamBatch(bson.M{"publisher_id": id})
-}
+func (lr *LiveStreamRepository) GetAllLiveStreamsByUserId(ctx context.Context, id primitive.ObjectID, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
 
-func (lr *LiveStreamRepository) GetLiveStreamFeed(maxStreams int) ([]*models.LiveStream, error) {
-	var liveStreams []*models.LiveStream
 	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+	return findPage(ctx, coll, notDeleted(bson.M{"publisher_id": id}), "", page, (*models.LiveStream).Position)
+}
 
-	// oneHourAgo := time.Now().Add(-1 * time.Hour)
+func (lr *LiveStreamRepository) GetActiveLiveStreams(ctx context.Context) ([]*models.LiveStream, error) {
+	return lr.getLiveStreamByParamBatch(ctx, bson.M{"live_stream_status": true})
+}
 
-	filter := bson.M{
-		// "created_at": bson.M{
-		// 	"$gte": oneHourAgo,
-		// },
-		"live_stream_status": true,
+func (lr *LiveStreamRepository) GetMostWatchedLiveStreams(ctx context.Context, limit int) ([]*models.LiveStream, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "viewer_count", Value: -1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+	return lr.getLiveStreamByParamBatch(ctx, bson.M{"live_stream_status": true}, opts)
+}
+
+func (lr *LiveStreamRepository) GetActiveLiveStreamsByPublishers(ctx context.Context, publisherIDs []primitive.ObjectID) ([]*models.LiveStream, error) {
+	if len(publisherIDs) == 0 {
+		return []*models.LiveStream{}, nil
 	}
+	return lr.getLiveStreamByParamBatch(ctx, bson.M{"publisher_id": bson.M{"$in": publisherIDs}, "live_stream_status": true})
+}
+
+func (lr *LiveStreamRepository) GetAllLiveStreams(ctx context.Context, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+	return findPage(ctx, coll, notDeleted(bson.M{}), "", page, (*models.LiveStream).Position)
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamsDeletedBefore(ctx context.Context, before time.Time) ([]*models.LiveStream, error) {
+	ctx, cancel := lr.Db.WithTimeout(ctx)
+	defer cancel()
 
-	cursor, err := coll.Find(context.TODO(), filter, options.Find().SetLimit(int64(maxStreams)))
+	var liveStreams []*models.LiveStream
+	coll := lr.Db.Collection(lr.liveStreamCollectionName)
+
+	cursor, err := coll.Find(ctx, bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": before}})
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
-	err = cursor.All(context.TODO(), &liveStreams)
-	if err != nil {
-		return nil, err
+	if err = cursor.All(ctx, &liveStreams); err != nil {
+		return nil, wrapError(err)
 	}
 
 	return liveStreams, nil
 }
-
-// Método genérico, pode ser substituído por uma busca mais específica
-func (lr *LiveStreamRepository) GetAllLiveStreams() ([]*models.LiveStream, error) {
-	return lr.getLiveStreamByParamBatch(bson.M{})
-}
@@ -1,12 +1,18 @@
 package repository
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/gtvb/livestream/models"
 	"github.com/gtvb/livestream/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func TestCreateLiveStream(t *testing.T) {
@@ -16,12 +22,56 @@ func TestCreateLiveStream(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 
 	publisherID := primitive.NewObjectID()
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 
 	assert.NoError(t, err)
 	assert.NotEqual(t, primitive.NilObjectID, insertedID)
 }
 
+func TestCreateLiveStreamDuplicateKey(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
+	assert.NoError(t, liveStreamRepo.EnsureIndexes(context.Background()))
+
+	publisherID := primitive.NewObjectID()
+	_, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	assert.NoError(t, err)
+
+	_, err = liveStreamRepo.CreateLiveStream(context.Background(), "Other Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestEnsureIndexesLegacyStreamKeys(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	ctx := context.Background()
+	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
+	coll := container.Database.Collection(utils.LiveStreamCollectionTest)
+
+	// Índice criado pelas versões anteriores, sem filtro parcial
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "stream_key_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	require.NoError(t, err)
+	require.NoError(t, liveStreamRepo.EnsureIndexes(ctx))
+
+	// Lives de antes da migração das chaves ainda não têm hash
+	_, err = coll.InsertMany(ctx, []interface{}{
+		bson.M{"name": "Legacy Stream", "stream_key": "legacy-1"},
+		bson.M{"name": "Other Legacy Stream", "stream_key": "legacy-2"},
+	})
+	assert.NoError(t, err)
+
+	_, err = liveStreamRepo.CreateLiveStream(ctx, "Test Stream", "fake-thumbnail", "streamkey-test", primitive.NewObjectID())
+	assert.NoError(t, err)
+	_, err = liveStreamRepo.CreateLiveStream(ctx, "Other Stream", "fake-thumbnail", "streamkey-test", primitive.NewObjectID())
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
 func TestDeleteLiveStream(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
@@ -30,10 +80,10 @@ func TestDeleteLiveStream(t *testing.T) {
 
 	publisherID := primitive.NewObjectID()
 
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	err = liveStreamRepo.DeleteLiveStream(insertedID.(primitive.ObjectID))
+	err = liveStreamRepo.DeleteLiveStream(context.Background(), insertedID.(primitive.ObjectID), time.Now())
 	assert.NoError(t, err)
 }
 
@@ -44,13 +94,14 @@ func TestDeleteLiveStreamsByPublisher(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	_, err := liveStreamRepo.CreateLiveStream("Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
-	_, err = liveStreamRepo.CreateLiveStream("Test Stream 2", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err = liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 2", "fake-thumbnail", "streamkey-test-2", publisherID)
 	assert.NoError(t, err)
 
-	err = liveStreamRepo.DeleteLiveStreamsByPublisher(publisherID)
+	deleted, err := liveStreamRepo.DeleteLiveStreamsByPublisher(context.Background(), publisherID, time.Now())
 	assert.NoError(t, err)
+	assert.EqualValues(t, 2, deleted)
 }
 
 func TestUpdateLiveStream(t *testing.T) {
@@ -60,17 +111,41 @@ func TestUpdateLiveStream(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	err = liveStreamRepo.UpdateLiveStream(insertedID.(primitive.ObjectID), bson.M{"name": "(Updated) Live Stream 1", "live_stream_status": true})
+	err = liveStreamRepo.UpdateLiveStream(context.Background(), insertedID.(primitive.ObjectID), bson.M{"name": "(Updated) Live Stream 1", "live_stream_status": true})
 	assert.NoError(t, err)
 
-	ls, _ := liveStreamRepo.GetLiveStreamById(insertedID.(primitive.ObjectID))
+	ls, _ := liveStreamRepo.GetLiveStreamById(context.Background(), insertedID.(primitive.ObjectID))
 	assert.Equal(t, "(Updated) Live Stream 1", ls.Name)
 	assert.Equal(t, true, ls.LiveStatus)
 }
 
+func TestRotateLiveStreamKey(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
+	publisherID := primitive.NewObjectID()
+
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	assert.NoError(t, err)
+	id := insertedID.(primitive.ObjectID)
+
+	rotation := models.NewStreamKeyRotation(publisherID, models.HashStreamKey("streamkey-test"), "leaked")
+	err = liveStreamRepo.RotateLiveStreamKey(context.Background(), id, "streamkey-new", rotation)
+	assert.NoError(t, err)
+
+	_, err = liveStreamRepo.GetLiveStreamByStreamKey(context.Background(), "streamkey-test")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ls, err := liveStreamRepo.GetLiveStreamByStreamKey(context.Background(), "streamkey-new")
+	assert.NoError(t, err)
+	assert.Equal(t, id, ls.ID)
+	assert.Len(t, ls.StreamKeyRotations, 1)
+}
+
 func TestIncrementLiveStreamUserCount(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
@@ -78,10 +153,10 @@ func TestIncrementLiveStreamUserCount(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	err = liveStreamRepo.IncrementLiveStreamUserCount(insertedID.(primitive.ObjectID))
+	err = liveStreamRepo.IncrementLiveStreamUserCount(context.Background(), insertedID.(primitive.ObjectID))
 	assert.NoError(t, err)
 }
 
@@ -92,11 +167,32 @@ func TestDecrementLiveStreamUserCount(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	err = liveStreamRepo.DecrementLiveStreamUserCount(insertedID.(primitive.ObjectID))
+	err = liveStreamRepo.DecrementLiveStreamUserCount(context.Background(), insertedID.(primitive.ObjectID))
 	assert.NoError(t, err)
+
+	// O contador nunca fica negativo
+	ls, _ := liveStreamRepo.GetLiveStreamById(context.Background(), insertedID.(primitive.ObjectID))
+	assert.Equal(t, 0, ls.ViewerCount)
+}
+
+func TestSetLiveStreamViewerCount(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
+	insertedID, _ := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", primitive.NewObjectID())
+	id := insertedID.(primitive.ObjectID)
+
+	assert.NoError(t, liveStreamRepo.SetLiveStreamViewerCount(context.Background(), id, 5))
+	ls, _ := liveStreamRepo.GetLiveStreamById(context.Background(), id)
+	assert.Equal(t, 5, ls.ViewerCount)
+
+	assert.NoError(t, liveStreamRepo.SetLiveStreamViewerCount(context.Background(), id, -3))
+	ls, _ = liveStreamRepo.GetLiveStreamById(context.Background(), id)
+	assert.Equal(t, 0, ls.ViewerCount)
 }
 
 func TestGetLiveStreamById(t *testing.T) {
@@ -106,10 +202,10 @@ func TestGetLiveStreamById(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	insertedID, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	insertedID, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	liveStream, err := liveStreamRepo.GetLiveStreamById(insertedID.(primitive.ObjectID))
+	liveStream, err := liveStreamRepo.GetLiveStreamById(context.Background(), insertedID.(primitive.ObjectID))
 
 	assert.NoError(t, err)
 	assert.Equal(t, "Test Stream", liveStream.Name)
@@ -122,10 +218,10 @@ func TestGetLiveStreamByName(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 
 	publisherID := primitive.NewObjectID()
-	_, err := liveStreamRepo.CreateLiveStream("Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
 
-	liveStream, err := liveStreamRepo.GetLiveStreamByName("Test Stream")
+	liveStream, err := liveStreamRepo.GetLiveStreamByName(context.Background(), "Test Stream")
 
 	assert.NoError(t, err)
 	assert.Equal(t, "Test Stream", liveStream.Name)
@@ -138,15 +234,15 @@ func TestGetAllLiveStreamsByUserId(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	_, err := liveStreamRepo.CreateLiveStream("Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
-	_, err = liveStreamRepo.CreateLiveStream("Test Stream 2", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err = liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 2", "fake-thumbnail", "streamkey-test-2", publisherID)
 	assert.NoError(t, err)
 
-	liveStreams, err := liveStreamRepo.GetAllLiveStreamsByUserId(publisherID)
+	liveStreams, err := liveStreamRepo.GetAllLiveStreamsByUserId(context.Background(), publisherID, models.PageRequest{Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Len(t, liveStreams, 2)
+	assert.Len(t, liveStreams.Items, 2)
 }
 
 func TestGetAllLiveStreams(t *testing.T) {
@@ -156,13 +252,13 @@ func TestGetAllLiveStreams(t *testing.T) {
 	liveStreamRepo := NewLiveStreamRepository(container.Database, utils.LiveStreamCollectionTest)
 	publisherID := primitive.NewObjectID()
 
-	_, err := liveStreamRepo.CreateLiveStream("Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err := liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 1", "fake-thumbnail", "streamkey-test", publisherID)
 	assert.NoError(t, err)
-	_, err = liveStreamRepo.CreateLiveStream("Test Stream 2", "fake-thumbnail", "streamkey-test", publisherID)
+	_, err = liveStreamRepo.CreateLiveStream(context.Background(), "Test Stream 2", "fake-thumbnail", "streamkey-test-2", publisherID)
 	assert.NoError(t, err)
 
-	liveStreams, err := liveStreamRepo.GetAllLiveStreams()
+	liveStreams, err := liveStreamRepo.GetAllLiveStreams(context.Background(), models.PageRequest{Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Len(t, liveStreams, 2)
+	assert.Len(t, liveStreams.Items, 2)
 }
@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Coleção de documentos de uma entidade, com as operações de que os
+// repositórios sem regras próprias de unicidade precisam. As entidades
+// são buscadas e alteradas por funções que recebem a entidade decodificada.
+type collection[T any] struct {
+	mu   sync.RWMutex
+	docs map[primitive.ObjectID]bson.Raw
+	// Ids na ordem de inserção, a mesma ordem natural do Mongo
+	order []primitive.ObjectID
+}
+
+func newCollection[T any]() *collection[T] {
+	return &collection[T]{docs: make(map[primitive.ObjectID]bson.Raw)}
+}
+
+// Insere `v` com um novo id, que é retornado.
+func (c *collection[T]) insert(ctx context.Context, v *T) (primitive.ObjectID, error) {
+	if err := checkContext(ctx); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.insertLocked(v)
+}
+
+// Deve ser chamado com `mu` travado para escrita.
+func (c *collection[T]) insertLocked(v *T) (primitive.ObjectID, error) {
+	id := primitive.NewObjectID()
+
+	raw, err := encode(v)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	// O id é gravado pelo BSON, já que as entidades o declaram como `_id`
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return primitive.NilObjectID, err
+	}
+	m["_id"] = id
+	if raw, err = encode(m); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	c.docs[id] = raw
+	c.order = append(c.order, id)
+
+	return id, nil
+}
+
+// Insere a entidade criada por `create` apenas se nenhuma entidade
+// satisfaz `match`, de forma atômica, como o upsert do Mongo. Retorna o
+// id da entidade inserida, ou um id nulo se ela já existia.
+func (c *collection[T]) insertUnless(ctx context.Context, match func(v *T) bool, create func() *T) (primitive.ObjectID, error) {
+	if err := checkContext(ctx); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, err := c.findLocked(match, 1)
+	if err != nil || len(found) > 0 {
+		return primitive.NilObjectID, err
+	}
+
+	return c.insertLocked(create())
+}
+
+// Aplica `change` à primeira entidade que satisfaz `match` ou, se nenhuma
+// satisfaz, insere a entidade criada por `create`, como o upsert do Mongo.
+func (c *collection[T]) upsert(ctx context.Context, match func(v *T) bool, change func(v *T), create func() *T) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range c.order {
+		v, err := decode[T](c.docs[id])
+		if err != nil {
+			return err
+		}
+		if !match(v) {
+			continue
+		}
+
+		change(v)
+		c.docs[id], err = encode(v)
+		return err
+	}
+
+	_, err := c.insertLocked(create())
+	return err
+}
+
+// Retorna as entidades que satisfazem `match`, na ordem de inserção.
+func (c *collection[T]) find(ctx context.Context, match func(v *T) bool) ([]*T, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.findLocked(match, 0)
+}
+
+// Como `find`, limitado a `limit` entidades quando positivo. Deve ser
+// chamado com `mu` travado.
+func (c *collection[T]) findLocked(match func(v *T) bool, limit int) ([]*T, error) {
+	found := make([]*T, 0)
+	for _, id := range c.order {
+		v, err := decode[T](c.docs[id])
+		if err != nil {
+			return nil, err
+		}
+
+		if match(v) {
+			found = append(found, v)
+			if len(found) == limit {
+				break
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// Aplica `change` às entidades que satisfazem `match`, retornando quantas
+// foram de fato alteradas.
+func (c *collection[T]) updateMany(ctx context.Context, match func(v *T) bool, change func(v *T)) (int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var modified int64
+	for _, id := range c.order {
+		v, err := decode[T](c.docs[id])
+		if err != nil {
+			return modified, err
+		}
+		if !match(v) {
+			continue
+		}
+
+		updated, err := apply(c.docs[id], modify(change))
+		if errors.Is(err, repository.ErrNoChange) {
+			continue
+		}
+		if err != nil {
+			return modified, err
+		}
+
+		c.docs[id] = updated
+		modified++
+	}
+
+	return modified, nil
+}
+
+// Remove as entidades que satisfazem `match`, retornando as removidas.
+func (c *collection[T]) deleteMany(ctx context.Context, match func(v *T) bool) ([]*T, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Nada é removido antes de todos os documentos serem decodificados
+	deleted := make([]*T, 0)
+	var deletedIDs []primitive.ObjectID
+	kept := make([]primitive.ObjectID, 0, len(c.order))
+	for _, id := range c.order {
+		v, err := decode[T](c.docs[id])
+		if err != nil {
+			return nil, err
+		}
+
+		if match(v) {
+			deleted = append(deleted, v)
+			deletedIDs = append(deletedIDs, id)
+		} else {
+			kept = append(kept, id)
+		}
+	}
+
+	for _, id := range deletedIDs {
+		delete(c.docs, id)
+	}
+	c.order = kept
+
+	return deleted, nil
+}
+
+// Página das entidades que satisfazem `match`, na ordem da listagem.
+func findPage[T any](ctx context.Context, c *collection[T], match func(v *T) bool, keyed bool, req models.PageRequest, position func(v *T) models.Cursor) (*models.Page[*T], error) {
+	found, err := c.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Paginate(found, req, keyed, position)
+}
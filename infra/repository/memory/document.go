@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// As entidades são guardadas como documentos BSON, da mesma forma que
+// no Mongo. Assim os nomes dos campos aceitos em `UpdateUser` e
+// `UpdateLiveStream` e a precisão dos horários são os mesmos nas duas
+// implementações, e nenhum ponteiro é compartilhado com quem chama.
+
+// Mesmo comportamento do driver quando o contexto já foi encerrado.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", repository.ErrUnavailable, err)
+	}
+	return nil
+}
+
+func encode(v interface{}) (bson.Raw, error) {
+	return bson.Marshal(v)
+}
+
+func decode[T any](doc bson.Raw) (*T, error) {
+	var v T
+	if err := bson.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Uma alteração sobre um documento, que retorna o documento alterado.
+type update func(doc bson.Raw) (bson.Raw, error)
+
+// Equivalente a um `$set`: sobrescreve os campos do documento presentes
+// em `fields`, que usam os nomes dos campos no BSON.
+func setFields[T any](fields bson.M) update {
+	return func(doc bson.Raw) (bson.Raw, error) {
+		var m bson.M
+		if err := bson.Unmarshal(doc, &m); err != nil {
+			return nil, err
+		}
+
+		for key, value := range fields {
+			m[key] = value
+		}
+
+		withFields, err := bson.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+
+		// Decodificar e codificar novamente mantém os campos na
+		// ordem da struct, para que a comparação com o original funcione
+		v, err := decode[T](withFields)
+		if err != nil {
+			return nil, err
+		}
+		return encode(v)
+	}
+}
+
+// Altera a entidade decodificada do documento.
+func modify[T any](change func(v *T)) update {
+	return func(doc bson.Raw) (bson.Raw, error) {
+		v, err := decode[T](doc)
+		if err != nil {
+			return nil, err
+		}
+
+		change(v)
+		return encode(v)
+	}
+}
+
+// Aplica `u` sobre `doc`, retornando `repository.ErrNoChange` caso
+// nada tenha sido alterado, assim como o Mongo reporta pelo ModifiedCount.
+func apply(doc bson.Raw, u update) (bson.Raw, error) {
+	updated, err := u(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(doc, updated) {
+		return nil, repository.ErrNoChange
+	}
+
+	return updated, nil
+}
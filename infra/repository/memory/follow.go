@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `FollowRepositoryInterface`, com a mesma
+// semântica da implementação Mongo com os índices criados: cada par
+// seguidor/seguido é único.
+type FollowRepository struct {
+	follows *collection[models.Follow]
+}
+
+func NewFollowRepository() *FollowRepository {
+	return &FollowRepository{follows: newCollection[models.Follow]()}
+}
+
+func followPair(followerID, followeeID primitive.ObjectID) func(follow *models.Follow) bool {
+	return func(follow *models.Follow) bool {
+		return follow.FollowerID == followerID && follow.FolloweeID == followeeID
+	}
+}
+
+func (fr *FollowRepository) Follow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	if followerID == followeeID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfFollow, followerID.Hex())
+	}
+
+	id, err := fr.follows.insertUnless(ctx, followPair(followerID, followeeID), func() *models.Follow {
+		return models.NewFollow(followerID, followeeID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if id.IsZero() {
+		return fmt.Errorf("%w: %s already follows %s", repository.ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	deleted, err := fr.follows.deleteMany(ctx, followPair(followerID, followeeID))
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		return fmt.Errorf("%w: %s does not follow %s", repository.ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) DeleteUserFollows(ctx context.Context, userID primitive.ObjectID) ([]*models.Follow, error) {
+	return fr.follows.deleteMany(ctx, func(follow *models.Follow) bool {
+		return follow.FollowerID == userID || follow.FolloweeID == userID
+	})
+}
+
+func (fr *FollowRepository) GetFollowingIDs(ctx context.Context, followerID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	follows, err := fr.follows.find(ctx, func(follow *models.Follow) bool { return follow.FollowerID == followerID })
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.FolloweeID)
+	}
+
+	return ids, nil
+}
+
+func (fr *FollowRepository) GetFollowers(ctx context.Context, followeeID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	return findPage(ctx, fr.follows, func(follow *models.Follow) bool {
+		return follow.FolloweeID == followeeID
+	}, false, page, (*models.Follow).Position)
+}
+
+func (fr *FollowRepository) GetFollowing(ctx context.Context, followerID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	return findPage(ctx, fr.follows, func(follow *models.Follow) bool {
+		return follow.FollowerID == followerID
+	}, false, page, (*models.Follow).Position)
+}
+
+func (fr *FollowRepository) CountFollows(ctx context.Context) (map[primitive.ObjectID]models.FollowCounts, error) {
+	follows, err := fr.follows.find(ctx, func(*models.Follow) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[primitive.ObjectID]models.FollowCounts)
+	for _, follow := range follows {
+		followee := counts[follow.FolloweeID]
+		followee.Followers++
+		counts[follow.FolloweeID] = followee
+
+		follower := counts[follow.FollowerID]
+		follower.Following++
+		counts[follow.FollowerID] = follower
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,375 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `LiveStreamRepositoryInterface`, com a
+// mesma semântica da implementação Mongo com os índices criados: o hash
+// da chave de stream é único.
+type LiveStreamRepository struct {
+	mu          sync.RWMutex
+	liveStreams map[primitive.ObjectID]bson.Raw
+	// Ids na ordem de inserção, a mesma ordem natural do Mongo
+	order []primitive.ObjectID
+}
+
+func NewLiveStreamRepository() *LiveStreamRepository {
+	return &LiveStreamRepository{liveStreams: make(map[primitive.ObjectID]bson.Raw)}
+}
+
+func (lr *LiveStreamRepository) CreateLiveStream(ctx context.Context, name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	doc := models.NewLiveStream(name, thumbnail, publisherId, streamKey)
+	doc.ID = primitive.NewObjectID()
+
+	if err := lr.checkUnique(doc.ID, doc.StreamKeyHash); err != nil {
+		return nil, err
+	}
+
+	raw, err := encode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	lr.liveStreams[doc.ID] = raw
+	lr.order = append(lr.order, doc.ID)
+
+	return doc.ID, nil
+}
+
+// Garante que nenhuma outra live além de `id` usa o mesmo hash de chave.
+func (lr *LiveStreamRepository) checkUnique(id primitive.ObjectID, streamKeyHash string) error {
+	for otherID, raw := range lr.liveStreams {
+		if otherID == id {
+			continue
+		}
+
+		other, err := decode[models.LiveStream](raw)
+		if err != nil {
+			return err
+		}
+
+		if other.StreamKeyHash == streamKeyHash {
+			return fmt.Errorf("%w: stream key already in use", repository.ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+func (lr *LiveStreamRepository) DeleteLiveStream(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return lr.updateLiveStream(ctx, id, modify(func(ls *models.LiveStream) {
+		ls.DeletedAt = &deletedAt
+	}))
+}
+
+func (lr *LiveStreamRepository) DeleteLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	return lr.modifyMany(ctx, func(ls *models.LiveStream) bool {
+		if ls.PublisherId != id || ls.DeletedAt != nil {
+			return false
+		}
+		ls.DeletedAt = &deletedAt
+		return true
+	})
+}
+
+func (lr *LiveStreamRepository) RestoreLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	return lr.modifyMany(ctx, func(ls *models.LiveStream) bool {
+		if ls.PublisherId != id || ls.DeletedAt == nil || !ls.DeletedAt.Equal(deletedAt) {
+			return false
+		}
+		ls.DeletedAt = nil
+		ls.UpdatedAt = time.Now()
+		return true
+	})
+}
+
+// Aplica `fn` a todas as lives, salvando as que ela alterou, e retorna
+// quantas foram alteradas.
+func (lr *LiveStreamRepository) modifyMany(ctx context.Context, fn func(ls *models.LiveStream) bool) (int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	var modified int64
+	for id, raw := range lr.liveStreams {
+		ls, err := decode[models.LiveStream](raw)
+		if err != nil {
+			return modified, err
+		}
+
+		if !fn(ls) {
+			continue
+		}
+
+		if lr.liveStreams[id], err = encode(ls); err != nil {
+			return modified, err
+		}
+		modified++
+	}
+
+	return modified, nil
+}
+
+func (lr *LiveStreamRepository) PurgeLiveStream(ctx context.Context, id primitive.ObjectID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	raw, ok := lr.liveStreams[id]
+	if !ok {
+		return fmt.Errorf("%w: no deleted live stream with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	ls, err := decode[models.LiveStream](raw)
+	if err != nil {
+		return err
+	}
+
+	if ls.DeletedAt == nil {
+		return fmt.Errorf("%w: no deleted live stream with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	lr.remove(func(other primitive.ObjectID) bool { return other == id })
+	return nil
+}
+
+func (lr *LiveStreamRepository) PurgeLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	publishedBy := make(map[primitive.ObjectID]bool)
+	var purged int64
+	for streamID, raw := range lr.liveStreams {
+		ls, err := decode[models.LiveStream](raw)
+		if err != nil {
+			return 0, err
+		}
+
+		publishedBy[streamID] = ls.PublisherId == id
+		if publishedBy[streamID] {
+			purged++
+		}
+	}
+
+	lr.remove(func(streamID primitive.ObjectID) bool { return publishedBy[streamID] })
+	return purged, nil
+}
+
+func (lr *LiveStreamRepository) remove(match func(id primitive.ObjectID) bool) {
+	for id := range lr.liveStreams {
+		if match(id) {
+			delete(lr.liveStreams, id)
+		}
+	}
+	lr.order = slices.DeleteFunc(lr.order, match)
+}
+
+func (lr *LiveStreamRepository) updateLiveStream(ctx context.Context, id primitive.ObjectID, u update) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	raw, ok := lr.liveStreams[id]
+	if !ok {
+		return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	current, err := decode[models.LiveStream](raw)
+	if err != nil {
+		return err
+	}
+	if current.DeletedAt != nil {
+		return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	updated, err := apply(raw, u)
+	if err != nil {
+		return fmt.Errorf("%w: _id %s", err, id.Hex())
+	}
+
+	ls, err := decode[models.LiveStream](updated)
+	if err != nil {
+		return err
+	}
+
+	if err := lr.checkUnique(id, ls.StreamKeyHash); err != nil {
+		return err
+	}
+
+	lr.liveStreams[id] = updated
+	return nil
+}
+
+func (lr *LiveStreamRepository) UpdateLiveStream(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
+	newData["updated_at"] = time.Now()
+	return lr.updateLiveStream(ctx, id, setFields[models.LiveStream](newData))
+}
+
+func (lr *LiveStreamRepository) RotateLiveStreamKey(ctx context.Context, id primitive.ObjectID, newStreamKey string, rotation *models.StreamKeyRotation) error {
+	return lr.updateLiveStream(ctx, id, modify(func(ls *models.LiveStream) {
+		ls.StreamKeyHash = models.HashStreamKey(newStreamKey)
+		ls.StreamKeyRotations = append(ls.StreamKeyRotations, rotation)
+		ls.UpdatedAt = time.Now()
+	}))
+}
+
+func (lr *LiveStreamRepository) IncrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
+	return lr.updateLiveStream(ctx, id, modify(func(ls *models.LiveStream) {
+		ls.ViewerCount++
+		ls.UpdatedAt = time.Now()
+	}))
+}
+
+func (lr *LiveStreamRepository) DecrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
+	return lr.updateLiveStream(ctx, id, modify(func(ls *models.LiveStream) {
+		ls.ViewerCount = max(0, ls.ViewerCount-1)
+		ls.UpdatedAt = time.Now()
+	}))
+}
+
+func (lr *LiveStreamRepository) SetLiveStreamViewerCount(ctx context.Context, id primitive.ObjectID, viewers int) error {
+	return lr.updateLiveStream(ctx, id, modify(func(ls *models.LiveStream) {
+		ls.ViewerCount = max(viewers, 0)
+		ls.UpdatedAt = time.Now()
+	}))
+}
+
+// Retorna, na ordem de inserção, até `limit` lives que satisfazem
+// `match`. Um limite zero retorna todas. Lives removidas são
+// ignoradas; `findAll` também as considera.
+func (lr *LiveStreamRepository) find(ctx context.Context, match func(ls *models.LiveStream) bool, limit int) ([]*models.LiveStream, error) {
+	return lr.findAll(ctx, func(ls *models.LiveStream) bool { return ls.DeletedAt == nil && match(ls) }, limit)
+}
+
+func (lr *LiveStreamRepository) findAll(ctx context.Context, match func(ls *models.LiveStream) bool, limit int) ([]*models.LiveStream, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	liveStreams := make([]*models.LiveStream, 0)
+	for _, id := range lr.order {
+		ls, err := decode[models.LiveStream](lr.liveStreams[id])
+		if err != nil {
+			return nil, err
+		}
+
+		if match(ls) {
+			liveStreams = append(liveStreams, ls)
+		}
+		if limit > 0 && len(liveStreams) == limit {
+			break
+		}
+	}
+
+	return liveStreams, nil
+}
+
+func (lr *LiveStreamRepository) getLiveStreamByParam(ctx context.Context, match func(ls *models.LiveStream) bool) (*models.LiveStream, error) {
+	liveStreams, err := lr.find(ctx, match, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(liveStreams) == 0 {
+		return nil, fmt.Errorf("%w: no matching live stream", repository.ErrNotFound)
+	}
+
+	return liveStreams[0], nil
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamById(ctx context.Context, id primitive.ObjectID) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, func(ls *models.LiveStream) bool { return ls.ID == id })
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamByName(ctx context.Context, name string) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, func(ls *models.LiveStream) bool { return ls.Name == name })
+}
+
+func (lr *LiveStreamRepository) GetActiveLiveStreamsByPublishers(ctx context.Context, publisherIDs []primitive.ObjectID) ([]*models.LiveStream, error) {
+	return lr.find(ctx, func(ls *models.LiveStream) bool {
+		return ls.LiveStatus && slices.Contains(publisherIDs, ls.PublisherId)
+	}, 0)
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamByStreamKey(ctx context.Context, key string) (*models.LiveStream, error) {
+	hash := models.HashStreamKey(key)
+	return lr.getLiveStreamByParam(ctx, func(ls *models.LiveStream) bool { return ls.StreamKeyHash == hash })
+}
+
+func (lr *LiveStreamRepository) GetAllLiveStreamsByUserId(ctx context.Context, id primitive.ObjectID, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	liveStreams, err := lr.find(ctx, func(ls *models.LiveStream) bool { return ls.PublisherId == id }, 0)
+	if err != nil {
+		return nil, err
+	}
+	return models.Paginate(liveStreams, page, false, (*models.LiveStream).Position)
+}
+
+func (lr *LiveStreamRepository) GetActiveLiveStreams(ctx context.Context) ([]*models.LiveStream, error) {
+	return lr.find(ctx, func(ls *models.LiveStream) bool { return ls.LiveStatus }, 0)
+}
+
+func (lr *LiveStreamRepository) GetMostWatchedLiveStreams(ctx context.Context, limit int) ([]*models.LiveStream, error) {
+	liveStreams, err := lr.GetActiveLiveStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(liveStreams, func(a, b *models.LiveStream) int {
+		if a.ViewerCount != b.ViewerCount {
+			return cmp.Compare(b.ViewerCount, a.ViewerCount)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+
+	if limit > 0 && len(liveStreams) > limit {
+		liveStreams = liveStreams[:limit]
+	}
+	return liveStreams, nil
+}
+
+func (lr *LiveStreamRepository) GetAllLiveStreams(ctx context.Context, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	liveStreams, err := lr.find(ctx, func(ls *models.LiveStream) bool { return true }, 0)
+	if err != nil {
+		return nil, err
+	}
+	return models.Paginate(liveStreams, page, false, (*models.LiveStream).Position)
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamsDeletedBefore(ctx context.Context, before time.Time) ([]*models.LiveStream, error) {
+	return lr.findAll(ctx, func(ls *models.LiveStream) bool { return ls.DeletedAt != nil && ls.DeletedAt.Before(before) }, 0)
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/gtvb/livestream/infra/repository/repositorytest"
+	"github.com/gtvb/livestream/models"
+)
+
+func TestUserRepositoryConformance(t *testing.T) {
+	repositorytest.RunUserRepositoryTests(t, func(t *testing.T) models.UserRepositoryInterface {
+		return NewUserRepository()
+	})
+}
+
+func TestLiveStreamRepositoryConformance(t *testing.T) {
+	repositorytest.RunLiveStreamRepositoryTests(t, func(t *testing.T) models.LiveStreamRepositoryInterface {
+		return NewLiveStreamRepository()
+	})
+}
+
+func TestRefreshTokenRepositoryConformance(t *testing.T) {
+	repositorytest.RunRefreshTokenRepositoryTests(t, func(t *testing.T) models.RefreshTokenRepositoryInterface {
+		return NewRefreshTokenRepository()
+	})
+}
+
+func TestStreamSessionRepositoryConformance(t *testing.T) {
+	repositorytest.RunStreamSessionRepositoryTests(t, func(t *testing.T) models.StreamSessionRepositoryInterface {
+		return NewStreamSessionRepository()
+	})
+}
+
+func TestViewerPresenceRepositoryConformance(t *testing.T) {
+	repositorytest.RunViewerPresenceRepositoryTests(t, func(t *testing.T) models.ViewerPresenceRepositoryInterface {
+		return NewViewerPresenceRepository()
+	})
+}
+
+func TestFollowRepositoryConformance(t *testing.T) {
+	repositorytest.RunFollowRepositoryTests(t, func(t *testing.T) models.FollowRepositoryInterface {
+		return NewFollowRepository()
+	})
+}
+
+func TestRelationRepositoryConformance(t *testing.T) {
+	repositorytest.RunRelationRepositoryTests(t, func(t *testing.T) models.RelationRepositoryInterface {
+		return NewRelationRepository()
+	})
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `RefreshTokenRepositoryInterface`, com a
+// mesma semântica da implementação Mongo.
+type RefreshTokenRepository struct {
+	tokens *collection[models.RefreshToken]
+}
+
+func NewRefreshTokenRepository() *RefreshTokenRepository {
+	return &RefreshTokenRepository{tokens: newCollection[models.RefreshToken]()}
+}
+
+func (rr *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID, familyID primitive.ObjectID, tokenHash, device string, expiresAt time.Time) (interface{}, error) {
+	doc := models.NewRefreshToken(userID, familyID, tokenHash, device, expiresAt)
+
+	// O hash do token é único, como garante o índice do Mongo
+	id, err := rr.tokens.insertUnless(ctx, func(token *models.RefreshToken) bool {
+		return token.TokenHash == tokenHash
+	}, func() *models.RefreshToken { return doc })
+	if err != nil {
+		return nil, err
+	}
+	if id.IsZero() {
+		return nil, fmt.Errorf("%w: token hash already in use", repository.ErrConflict)
+	}
+
+	return id, nil
+}
+
+// Só tokens ainda não revogados são afetados, como na implementação Mongo.
+func (rr *RefreshTokenRepository) RevokeRefreshToken(ctx context.Context, id primitive.ObjectID) error {
+	modified, err := rr.revokeRefreshTokens(ctx, func(token *models.RefreshToken) bool { return token.ID == id })
+	if err != nil {
+		return err
+	}
+
+	if modified != 1 {
+		return fmt.Errorf("%w: token %s is already revoked", repository.ErrNoChange, id.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RefreshTokenRepository) revokeRefreshTokens(ctx context.Context, match func(token *models.RefreshToken) bool) (int64, error) {
+	now := time.Now()
+	return rr.tokens.updateMany(ctx, func(token *models.RefreshToken) bool {
+		return token.RevokedAt == nil && match(token)
+	}, func(token *models.RefreshToken) {
+		token.RevokedAt = &now
+	})
+}
+
+func (rr *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	_, err := rr.revokeRefreshTokens(ctx, func(token *models.RefreshToken) bool { return token.FamilyID == familyID })
+	return err
+}
+
+func (rr *RefreshTokenRepository) RevokeAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := rr.revokeRefreshTokens(ctx, func(token *models.RefreshToken) bool { return token.UserID == userID })
+	return err
+}
+
+func (rr *RefreshTokenRepository) DeleteAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	deleted, err := rr.tokens.deleteMany(ctx, func(token *models.RefreshToken) bool { return token.UserID == userID })
+	return int64(len(deleted)), err
+}
+
+func (rr *RefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	tokens, err := rr.tokens.find(ctx, func(token *models.RefreshToken) bool { return token.TokenHash == tokenHash })
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: no refresh token with this hash", repository.ErrNotFound)
+	}
+
+	return tokens[0], nil
+}
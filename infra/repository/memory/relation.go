@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `RelationRepositoryInterface`, com a mesma
+// semântica da implementação Mongo com os índices criados: cada usuário
+// tem no máximo uma relação de cada tipo com outro.
+type RelationRepository struct {
+	relations *collection[models.Relation]
+}
+
+func NewRelationRepository() *RelationRepository {
+	return &RelationRepository{relations: newCollection[models.Relation]()}
+}
+
+func relationOf(userID, targetID primitive.ObjectID, kind models.RelationKind) func(relation *models.Relation) bool {
+	return func(relation *models.Relation) bool {
+		return relation.UserID == userID && relation.TargetID == targetID && relation.Kind == kind
+	}
+}
+
+func (rr *RelationRepository) AddRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	if userID == targetID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfRelation, userID.Hex())
+	}
+
+	id, err := rr.relations.insertUnless(ctx, relationOf(userID, targetID, kind), func() *models.Relation {
+		return models.NewRelation(userID, targetID, kind)
+	})
+	if err != nil {
+		return err
+	}
+
+	if id.IsZero() {
+		return fmt.Errorf("%w: %s already has a %s relation with %s", repository.ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) RemoveRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	deleted, err := rr.relations.deleteMany(ctx, relationOf(userID, targetID, kind))
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		return fmt.Errorf("%w: %s has no %s relation with %s", repository.ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) DeleteUserRelations(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	deleted, err := rr.relations.deleteMany(ctx, func(relation *models.Relation) bool {
+		return relation.UserID == userID || relation.TargetID == userID
+	})
+	return int64(len(deleted)), err
+}
+
+func (rr *RelationRepository) HasRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) (bool, error) {
+	return rr.exists(ctx, relationOf(userID, targetID, kind))
+}
+
+func (rr *RelationRepository) IsBlockedBetween(ctx context.Context, userID, otherID primitive.ObjectID) (bool, error) {
+	return rr.exists(ctx, func(relation *models.Relation) bool {
+		return relationOf(userID, otherID, models.RelationBlock)(relation) || relationOf(otherID, userID, models.RelationBlock)(relation)
+	})
+}
+
+func (rr *RelationRepository) GetRelatedIDs(ctx context.Context, userID primitive.ObjectID, kinds ...models.RelationKind) ([]primitive.ObjectID, error) {
+	relations, err := rr.relations.find(ctx, func(relation *models.Relation) bool {
+		return relation.UserID == userID && slices.Contains(kinds, relation.Kind)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Um usuário bloqueado e silenciado aparece uma única vez
+	ids := make([]primitive.ObjectID, 0, len(relations))
+	for _, relation := range relations {
+		if !slices.Contains(ids, relation.TargetID) {
+			ids = append(ids, relation.TargetID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (rr *RelationRepository) GetRelations(ctx context.Context, userID primitive.ObjectID, kind models.RelationKind, page models.PageRequest) (*models.Page[*models.Relation], error) {
+	return findPage(ctx, rr.relations, func(relation *models.Relation) bool {
+		return relation.UserID == userID && relation.Kind == kind
+	}, false, page, (*models.Relation).Position)
+}
+
+func (rr *RelationRepository) exists(ctx context.Context, match func(relation *models.Relation) bool) (bool, error) {
+	relations, err := rr.relations.find(ctx, match)
+	return len(relations) > 0, err
+}
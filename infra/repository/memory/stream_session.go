@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `StreamSessionRepositoryInterface`, com a
+// mesma semântica da implementação Mongo.
+type StreamSessionRepository struct {
+	sessions *collection[models.StreamSession]
+}
+
+func NewStreamSessionRepository() *StreamSessionRepository {
+	return &StreamSessionRepository{sessions: newCollection[models.StreamSession]()}
+}
+
+func (sr *StreamSessionRepository) CreateStreamSession(ctx context.Context, liveStreamID, publisherID primitive.ObjectID, clientIP, clientID string, encoder models.EncoderInfo) (interface{}, error) {
+	doc := models.NewStreamSession(liveStreamID, publisherID, clientIP, clientID, encoder)
+	return sr.sessions.insert(ctx, doc)
+}
+
+// Sessões ainda abertas da live `liveStreamID`.
+func openSession(liveStreamID primitive.ObjectID) func(session *models.StreamSession) bool {
+	return func(session *models.StreamSession) bool {
+		return session.LiveStreamID == liveStreamID && session.EndedAt == nil
+	}
+}
+
+func (sr *StreamSessionRepository) CloseStreamSessions(ctx context.Context, liveStreamID primitive.ObjectID) error {
+	now := time.Now()
+	_, err := sr.sessions.updateMany(ctx, openSession(liveStreamID), func(session *models.StreamSession) {
+		session.EndedAt = &now
+		session.AverageViewers = 0
+		if session.ViewerSamples > 0 {
+			session.AverageViewers = float64(session.ViewerSum) / float64(session.ViewerSamples)
+		}
+	})
+	return err
+}
+
+func (sr *StreamSessionRepository) DeleteStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	deleted, err := sr.sessions.deleteMany(ctx, func(session *models.StreamSession) bool { return session.PublisherID == publisherID })
+	return int64(len(deleted)), err
+}
+
+func (sr *StreamSessionRepository) CountStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	sessions, err := sr.sessions.find(ctx, func(session *models.StreamSession) bool { return session.PublisherID == publisherID })
+	return int64(len(sessions)), err
+}
+
+func (sr *StreamSessionRepository) RecordStreamSessionViewers(ctx context.Context, liveStreamID primitive.ObjectID, viewers int) error {
+	_, err := sr.sessions.updateMany(ctx, openSession(liveStreamID), func(session *models.StreamSession) {
+		session.PeakViewers = max(session.PeakViewers, viewers)
+		session.ViewerSum += viewers
+		session.ViewerSamples++
+	})
+	return err
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSession(ctx context.Context, liveStreamID primitive.ObjectID) (*models.StreamSession, error) {
+	sessions, err := sr.sessions.find(ctx, openSession(liveStreamID))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("%w: no open session for live stream %s", repository.ErrNotFound, liveStreamID.Hex())
+	}
+
+	// A mais recente, como na ordenação da implementação Mongo
+	return slices.MaxFunc(sessions, func(a, b *models.StreamSession) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	}), nil
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSessions(ctx context.Context, liveStreamIDs []primitive.ObjectID) ([]*models.StreamSession, error) {
+	return sr.sessions.find(ctx, func(session *models.StreamSession) bool {
+		return session.EndedAt == nil && slices.Contains(liveStreamIDs, session.LiveStreamID)
+	})
+}
+
+func (sr *StreamSessionRepository) GetStreamSessionsByLiveStream(ctx context.Context, liveStreamID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.StreamSession], error) {
+	return findPage(ctx, sr.sessions, func(session *models.StreamSession) bool {
+		return session.LiveStreamID == liveStreamID
+	}, true, page, (*models.StreamSession).Position)
+}
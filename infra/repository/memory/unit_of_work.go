@@ -0,0 +1,17 @@
+package memory
+
+import "context"
+
+// Implementação de `UnitOfWork` para os repositórios em memória. Não há
+// transação: `fn` é executada diretamente e as operações feitas antes de
+// um erro não são desfeitas. Serve apenas aos testes que não dependem do
+// rollback.
+type UnitOfWork struct{}
+
+func NewUnitOfWork() *UnitOfWork {
+	return &UnitOfWork{}
+}
+
+func (uw *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
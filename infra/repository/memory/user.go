@@ -0,0 +1,313 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `UserRepositoryInterface`, com a mesma
+// semântica da implementação Mongo com os índices criados: email e
+// username são únicos.
+type UserRepository struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]bson.Raw
+	// Ids na ordem de inserção, a mesma ordem natural do Mongo
+	order []primitive.ObjectID
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[primitive.ObjectID]bson.Raw)}
+}
+
+func (ur *UserRepository) CreateUser(ctx context.Context, username, email, password string) (interface{}, error) {
+	if err := checkContext(ctx); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if err := ur.checkUnique(primitive.NilObjectID, username, email); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	user := models.NewUser(username, email, password)
+	user.ID = primitive.NewObjectID()
+
+	doc, err := encode(user)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	ur.users[user.ID] = doc
+	ur.order = append(ur.order, user.ID)
+
+	return user.ID, nil
+}
+
+// Garante que nenhum outro usuário além de `id` usa o username ou email.
+func (ur *UserRepository) checkUnique(id primitive.ObjectID, username, email string) error {
+	for otherID, doc := range ur.users {
+		if otherID == id {
+			continue
+		}
+
+		other, err := decode[models.User](doc)
+		if err != nil {
+			return err
+		}
+
+		if other.Username == username || other.Email == email {
+			return fmt.Errorf("%w: username or email already in use", repository.ErrConflict)
+		}
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return ur.updateUser(ctx, id, modify(func(user *models.User) {
+		user.DeletedAt = &deletedAt
+	}))
+}
+
+func (ur *UserRepository) RestoreUser(ctx context.Context, id primitive.ObjectID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	user, err := ur.deletedUser(id)
+	if err != nil {
+		return err
+	}
+
+	user.DeletedAt = nil
+	user.UpdatedAt = time.Now()
+
+	doc, err := encode(user)
+	if err != nil {
+		return err
+	}
+
+	ur.users[id] = doc
+	return nil
+}
+
+func (ur *UserRepository) PurgeUser(ctx context.Context, id primitive.ObjectID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	if _, err := ur.deletedUser(id); err != nil {
+		return err
+	}
+
+	delete(ur.users, id)
+	ur.order = slices.DeleteFunc(ur.order, func(other primitive.ObjectID) bool { return other == id })
+
+	return nil
+}
+
+// Retorna o usuário `id`, desde que ele tenha sido removido.
+func (ur *UserRepository) deletedUser(id primitive.ObjectID) (*models.User, error) {
+	doc, ok := ur.users[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: no deleted user with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	user, err := decode[models.User](doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt == nil {
+		return nil, fmt.Errorf("%w: no deleted user with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	return user, nil
+}
+
+func (ur *UserRepository) updateUser(ctx context.Context, id primitive.ObjectID, u update) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	doc, ok := ur.users[id]
+	if !ok {
+		return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	current, err := decode[models.User](doc)
+	if err != nil {
+		return err
+	}
+	if current.DeletedAt != nil {
+		return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	updated, err := apply(doc, u)
+	if err != nil {
+		return fmt.Errorf("%w: _id %s", err, id.Hex())
+	}
+
+	user, err := decode[models.User](updated)
+	if err != nil {
+		return err
+	}
+
+	if err := ur.checkUnique(id, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	ur.users[id] = updated
+	return nil
+}
+
+func (ur *UserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
+	newData["updated_at"] = time.Now()
+	return ur.updateUser(ctx, id, setFields[models.User](newData))
+}
+
+func (ur *UserRepository) AddFollowCounts(ctx context.Context, id primitive.ObjectID, followers, following int) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	doc, ok := ur.users[id]
+	if !ok {
+		return nil
+	}
+
+	user, err := decode[models.User](doc)
+	if err != nil {
+		return err
+	}
+	user.FollowerCount += followers
+	user.FollowingCount += following
+
+	ur.users[id], err = encode(user)
+	return err
+}
+
+func (ur *UserRepository) SetFollowCounts(ctx context.Context, counts map[primitive.ObjectID]models.FollowCounts) (int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	// A leitura e a escrita de cada contador acontecem com a trava
+	// tomada, então nenhum follow feito nesse meio tempo é sobrescrito
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	var repaired int64
+	for _, id := range ur.order {
+		user, err := decode[models.User](ur.users[id])
+		if err != nil {
+			return repaired, err
+		}
+
+		count := counts[id]
+		if user.FollowerCount == count.Followers && user.FollowingCount == count.Following {
+			continue
+		}
+		user.FollowerCount = count.Followers
+		user.FollowingCount = count.Following
+
+		if ur.users[id], err = encode(user); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+
+	return repaired, nil
+}
+
+// Retorna, na ordem de inserção, os usuários que satisfazem `match`,
+// incluindo os removidos.
+func (ur *UserRepository) find(ctx context.Context, match func(user *models.User) bool) ([]*models.User, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	ur.mu.RLock()
+	defer ur.mu.RUnlock()
+
+	users := make([]*models.User, 0)
+	for _, id := range ur.order {
+		user, err := decode[models.User](ur.users[id])
+		if err != nil {
+			return nil, err
+		}
+
+		if match(user) {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+func (ur *UserRepository) getUserByParam(ctx context.Context, match func(user *models.User) bool) (*models.User, error) {
+	users, err := ur.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%w: no matching user", repository.ErrNotFound)
+	}
+
+	return users[0], nil
+}
+
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ur.getUserByParam(ctx, func(user *models.User) bool { return user.DeletedAt == nil && user.Username == username })
+}
+
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.getUserByParam(ctx, func(user *models.User) bool { return user.DeletedAt == nil && user.Email == email })
+}
+
+func (ur *UserRepository) GetUserById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ur.getUserByParam(ctx, func(user *models.User) bool { return user.DeletedAt == nil && user.ID == id })
+}
+
+func (ur *UserRepository) GetUsersByIds(ctx context.Context, ids []primitive.ObjectID) ([]*models.User, error) {
+	return ur.find(ctx, func(user *models.User) bool { return user.DeletedAt == nil && slices.Contains(ids, user.ID) })
+}
+
+func (ur *UserRepository) GetAllUsers(ctx context.Context, page models.PageRequest) (*models.Page[*models.User], error) {
+	users, err := ur.find(ctx, func(user *models.User) bool { return user.DeletedAt == nil })
+	if err != nil {
+		return nil, err
+	}
+	return models.Paginate(users, page, false, (*models.User).Position)
+}
+
+func (ur *UserRepository) GetDeletedUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.getUserByParam(ctx, func(user *models.User) bool { return user.DeletedAt != nil && user.Email == email })
+}
+
+func (ur *UserRepository) GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*models.User, error) {
+	return ur.find(ctx, func(user *models.User) bool { return user.DeletedAt != nil && user.DeletedAt.Before(before) })
+}
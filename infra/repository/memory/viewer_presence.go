@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação em memória de `ViewerPresenceRepositoryInterface`. Como
+// não há o monitor de TTL do Mongo, as presenças expiradas nunca são
+// removidas, apenas deixam de ser contadas.
+type ViewerPresenceRepository struct {
+	presences *collection[models.ViewerPresence]
+}
+
+func NewViewerPresenceRepository() *ViewerPresenceRepository {
+	return &ViewerPresenceRepository{presences: newCollection[models.ViewerPresence]()}
+}
+
+func viewerSession(liveStreamID primitive.ObjectID, sessionID string) func(presence *models.ViewerPresence) bool {
+	return func(presence *models.ViewerPresence) bool {
+		return presence.LiveStreamID == liveStreamID && presence.SessionID == sessionID
+	}
+}
+
+func (vr *ViewerPresenceRepository) RecordViewerHeartbeat(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string, ttl time.Duration) error {
+	now := time.Now()
+	return vr.presences.upsert(ctx, viewerSession(liveStreamID, sessionID), func(presence *models.ViewerPresence) {
+		presence.LastSeenAt = now
+		presence.ExpiresAt = now.Add(ttl)
+	}, func() *models.ViewerPresence {
+		return &models.ViewerPresence{
+			LiveStreamID: liveStreamID,
+			SessionID:    sessionID,
+			LastSeenAt:   now,
+			ExpiresAt:    now.Add(ttl),
+		}
+	})
+}
+
+func (vr *ViewerPresenceRepository) RemoveViewer(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string) error {
+	_, err := vr.presences.deleteMany(ctx, viewerSession(liveStreamID, sessionID))
+	return err
+}
+
+func (vr *ViewerPresenceRepository) CountActiveViewers(ctx context.Context, liveStreamID primitive.ObjectID) (int, error) {
+	now := time.Now()
+	active, err := vr.presences.find(ctx, func(presence *models.ViewerPresence) bool {
+		return presence.LiveStreamID == liveStreamID && presence.ExpiresAt.After(now)
+	})
+	return len(active), err
+}
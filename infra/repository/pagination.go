@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Busca uma página de `coll` entre os documentos que satisfazem `filter`.
+// A listagem é ordenada de forma decrescente por `keyField` e crescente
+// pelo _id; sem `keyField`, apenas pelo _id. `position` retorna o cursor
+// de um documento, com a chave lida de `keyField`.
+func findPage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, keyField string, req models.PageRequest, position func(item T) models.Cursor) (*models.Page[T], error) {
+	return findKeyedPage(ctx, coll, filter, keyField, numberKey, req, position)
+}
+
+// Como `findPage`, para listagens ordenadas por um campo de data. A chave
+// do cursor são os milissegundos desde a época Unix.
+func findTimePage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, timeField string, req models.PageRequest, position func(item T) models.Cursor) (*models.Page[T], error) {
+	return findKeyedPage(ctx, coll, filter, timeField, timeKey, req, position)
+}
+
+// Converte a chave de um cursor no valor comparado com `keyField`.
+type keyValue func(key float64) any
+
+func numberKey(key float64) any {
+	return key
+}
+
+func timeKey(key float64) any {
+	return time.UnixMilli(int64(key))
+}
+
+func findKeyedPage[T any](ctx context.Context, coll *mongo.Collection, filter bson.M, keyField string, value keyValue, req models.PageRequest, position func(item T) models.Cursor) (*models.Page[T], error) {
+	filter, sort, err := pageQuery(filter, keyField, value, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(sort).SetLimit(int64(req.Limit) + 1)
+
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	items := make([]T, 0)
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return models.NewPage(items, req, position), nil
+}
+
+// Restringe `filter` aos documentos depois do cursor, no sentido que ele
+// indica, e retorna a ordenação da busca nesse sentido.
+func pageQuery(filter bson.M, keyField string, value keyValue, cursor *models.Cursor) (bson.M, bson.D, error) {
+	backward := cursor != nil && cursor.Backward
+
+	keyOrder, idOrder, idAfter := -1, 1, "$gt"
+	if backward {
+		keyOrder, idOrder, idAfter = 1, -1, "$lt"
+	}
+
+	sort := bson.D{{Key: "_id", Value: idOrder}}
+	if keyField != "" {
+		sort = append(bson.D{{Key: keyField, Value: keyOrder}}, sort...)
+	}
+
+	if cursor == nil {
+		return filter, sort, nil
+	}
+
+	after := bson.M{"_id": bson.M{idAfter: cursor.ID}}
+	if keyField != "" {
+		if cursor.Key == nil {
+			return nil, nil, fmt.Errorf("%w: missing %s", ErrInvalidCursor, keyField)
+		}
+
+		// Depois na ordem decrescente da chave é uma chave menor
+		keyAfter := "$lt"
+		if backward {
+			keyAfter = "$gt"
+		}
+
+		key := value(*cursor.Key)
+		after = bson.M{"$or": bson.A{
+			bson.M{keyField: bson.M{keyAfter: key}},
+			bson.M{keyField: key, "_id": bson.M{idAfter: cursor.ID}},
+		}}
+	}
+
+	return bson.M{"$and": bson.A{filter, after}}, sort, nil
+}
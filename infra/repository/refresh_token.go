@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repositório de acesso aos dados da entidade `RefreshToken`.
+// Qualquer repositório precisa implementar a interface
+// `RefreshTokenRepositoryInterface` para ser utilizada de forma
+// válida pelo servidor HTTP.
+type RefreshTokenRepository struct {
+	refreshTokenCollectionName string
+	Db                         *db.Database
+}
+
+func NewRefreshTokenRepository(db *db.Database, refreshTokenCollectionName string) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		refreshTokenCollectionName: refreshTokenCollectionName,
+		Db:                         db,
+	}
+}
+
+// Cria o índice único do hash do token, usado em toda rotação, e os
+// índices usados para revogar as sessões de uma família ou de um usuário.
+func (rr *RefreshTokenRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "family_id", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+func (rr *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID, familyID primitive.ObjectID, tokenHash, device string, expiresAt time.Time) (interface{}, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+	doc := models.NewRefreshToken(userID, familyID, tokenHash, device, expiresAt)
+
+	res, err := coll.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return res.InsertedID, nil
+}
+
+// Revoga um único token. Só tokens ainda não revogados são afetados, de
+// forma que duas rotações concorrentes do mesmo token não podem ambas
+// ter sucesso.
+func (rr *RefreshTokenRepository) RevokeRefreshToken(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+	filter := bson.M{"_id": id, "revoked_at": nil}
+
+	res, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.ModifiedCount != 1 {
+		return fmt.Errorf("%w: token %s is already revoked", ErrNoChange, id.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RefreshTokenRepository) revokeRefreshTokens(ctx context.Context, filter primitive.M) error {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+	filter["revoked_at"] = nil
+
+	_, err := coll.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (rr *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	return rr.revokeRefreshTokens(ctx, bson.M{"family_id": familyID})
+}
+
+func (rr *RefreshTokenRepository) RevokeAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return rr.revokeRefreshTokens(ctx, bson.M{"user_id": userID})
+}
+
+// Apaga todos os tokens do usuário, revogados ou não. Usado apenas na
+// remoção do usuário, já que os tokens revogados servem de histórico.
+func (rr *RefreshTokenRepository) DeleteAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+
+	res, err := coll.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return res.DeletedCount, nil
+}
+
+func (rr *RefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	var refreshToken models.RefreshToken
+	coll := rr.Db.Collection(rr.refreshTokenCollectionName)
+
+	res := coll.FindOne(ctx, bson.M{"token_hash": tokenHash})
+	if err := res.Decode(&refreshToken); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &refreshToken, nil
+}
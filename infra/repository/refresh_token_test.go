@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateRefreshToken(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	refreshTokenRepo := NewRefreshTokenRepository(container.Database, utils.RefreshTokenCollectionTest)
+	insertedID, err := refreshTokenRepo.CreateRefreshToken(context.Background(), primitive.NewObjectID(), primitive.NewObjectID(), "hash", "device", time.Now().Add(time.Hour))
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, primitive.NilObjectID, insertedID)
+
+	token, err := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "device", token.Device)
+	assert.False(t, token.Revoked())
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	refreshTokenRepo := NewRefreshTokenRepository(container.Database, utils.RefreshTokenCollectionTest)
+	insertedID, _ := refreshTokenRepo.CreateRefreshToken(context.Background(), primitive.NewObjectID(), primitive.NewObjectID(), "hash", "device", time.Now().Add(time.Hour))
+
+	err := refreshTokenRepo.RevokeRefreshToken(context.Background(), insertedID.(primitive.ObjectID))
+	assert.NoError(t, err)
+
+	// Um token só pode ser revogado (rotacionado) uma vez
+	err = refreshTokenRepo.RevokeRefreshToken(context.Background(), insertedID.(primitive.ObjectID))
+	assert.ErrorIs(t, err, ErrNoChange)
+
+	token, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash")
+	assert.True(t, token.Revoked())
+}
+
+func TestRevokeRefreshTokenFamily(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	refreshTokenRepo := NewRefreshTokenRepository(container.Database, utils.RefreshTokenCollectionTest)
+	userID := primitive.NewObjectID()
+	familyID := primitive.NewObjectID()
+
+	refreshTokenRepo.CreateRefreshToken(context.Background(), userID, familyID, "hash1", "device", time.Now().Add(time.Hour))
+	refreshTokenRepo.CreateRefreshToken(context.Background(), userID, familyID, "hash2", "device", time.Now().Add(time.Hour))
+	refreshTokenRepo.CreateRefreshToken(context.Background(), userID, primitive.NewObjectID(), "hash3", "other device", time.Now().Add(time.Hour))
+
+	err := refreshTokenRepo.RevokeRefreshTokenFamily(context.Background(), familyID)
+	assert.NoError(t, err)
+
+	token1, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash1")
+	token2, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash2")
+	token3, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash3")
+	assert.True(t, token1.Revoked())
+	assert.True(t, token2.Revoked())
+	assert.False(t, token3.Revoked())
+}
+
+func TestRevokeAllUserRefreshTokens(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	refreshTokenRepo := NewRefreshTokenRepository(container.Database, utils.RefreshTokenCollectionTest)
+	userID := primitive.NewObjectID()
+
+	refreshTokenRepo.CreateRefreshToken(context.Background(), userID, primitive.NewObjectID(), "hash1", "device", time.Now().Add(time.Hour))
+	refreshTokenRepo.CreateRefreshToken(context.Background(), userID, primitive.NewObjectID(), "hash2", "other device", time.Now().Add(time.Hour))
+
+	err := refreshTokenRepo.RevokeAllUserRefreshTokens(context.Background(), userID)
+	assert.NoError(t, err)
+
+	token1, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash1")
+	token2, _ := refreshTokenRepo.GetRefreshTokenByHash(context.Background(), "hash2")
+	assert.True(t, token1.Revoked())
+	assert.True(t, token2.Revoked())
+}
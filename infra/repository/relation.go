@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repositório de acesso aos dados da entidade `Relation`.
+// Qualquer repositório precisa implementar a interface
+// `RelationRepositoryInterface` para ser utilizada de forma
+// válida pelo servidor HTTP.
+type RelationRepository struct {
+	relationCollectionName string
+	Db                     *db.Database
+}
+
+func NewRelationRepository(db *db.Database, relationCollectionName string) *RelationRepository {
+	return &RelationRepository{
+		relationCollectionName: relationCollectionName,
+		Db:                     db,
+	}
+}
+
+// Cria o índice único da relação, que também atende à consulta de uma
+// relação específica, o índice da listagem por tipo e o índice do lado
+// de quem recebeu a relação.
+func (rr *RelationRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "target_id", Value: 1}, {Key: "kind", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+// Assim como o follow, a relação é criada por upsert para que repeti-la
+// não aborte a transação em andamento.
+func (rr *RelationRepository) AddRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	if userID == targetID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfRelation, userID.Hex())
+	}
+
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+	relation := models.NewRelation(userID, targetID, kind)
+
+	filter := bson.M{"user_id": userID, "target_id": targetID, "kind": kind}
+	update := bson.M{"$setOnInsert": bson.M{"created_at": relation.CreatedAt}}
+
+	res, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.UpsertedCount == 0 {
+		return fmt.Errorf("%w: %s already has a %s relation with %s", ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) RemoveRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+
+	res, err := coll.DeleteOne(ctx, bson.M{"user_id": userID, "target_id": targetID, "kind": kind})
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%w: %s has no %s relation with %s", ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) DeleteUserRelations(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+
+	res, err := coll.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"user_id": userID}, bson.M{"target_id": userID}}})
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return res.DeletedCount, nil
+}
+
+func (rr *RelationRepository) HasRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) (bool, error) {
+	return rr.exists(ctx, bson.M{"user_id": userID, "target_id": targetID, "kind": kind})
+}
+
+func (rr *RelationRepository) IsBlockedBetween(ctx context.Context, userID, otherID primitive.ObjectID) (bool, error) {
+	return rr.exists(ctx, bson.M{
+		"kind": models.RelationBlock,
+		"$or": bson.A{
+			bson.M{"user_id": userID, "target_id": otherID},
+			bson.M{"user_id": otherID, "target_id": userID},
+		},
+	})
+}
+
+func (rr *RelationRepository) GetRelatedIDs(ctx context.Context, userID primitive.ObjectID, kinds ...models.RelationKind) ([]primitive.ObjectID, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+
+	filter := bson.M{"user_id": userID, "kind": bson.M{"$in": kinds}}
+	opts := options.Find().SetProjection(bson.M{"target_id": 1})
+
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	relations := make([]*models.Relation, 0)
+	if err = cursor.All(ctx, &relations); err != nil {
+		return nil, wrapError(err)
+	}
+
+	// Um usuário bloqueado e silenciado aparece uma única vez
+	seen := make(map[primitive.ObjectID]bool, len(relations))
+	ids := make([]primitive.ObjectID, 0, len(relations))
+	for _, relation := range relations {
+		if !seen[relation.TargetID] {
+			seen[relation.TargetID] = true
+			ids = append(ids, relation.TargetID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (rr *RelationRepository) GetRelations(ctx context.Context, userID primitive.ObjectID, kind models.RelationKind, page models.PageRequest) (*models.Page[*models.Relation], error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+	return findPage(ctx, coll, bson.M{"user_id": userID, "kind": kind}, "", page, (*models.Relation).Position)
+}
+
+func (rr *RelationRepository) exists(ctx context.Context, filter bson.M) (bool, error) {
+	ctx, cancel := rr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := rr.Db.Collection(rr.relationCollectionName)
+
+	count, err := coll.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, wrapError(err)
+	}
+
+	return count > 0, nil
+}
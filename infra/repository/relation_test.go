@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRelation(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	relationRepo := NewRelationRepository(container.Database, utils.RelationCollectionTest)
+	assert.NoError(t, relationRepo.EnsureIndexes(context.Background()))
+
+	user, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+	assert.NoError(t, relationRepo.AddRelation(context.Background(), user, other, models.RelationBlock))
+	assert.ErrorIs(t, relationRepo.AddRelation(context.Background(), user, other, models.RelationBlock), ErrNoChange)
+	assert.ErrorIs(t, relationRepo.AddRelation(context.Background(), user, user, models.RelationMute), models.ErrSelfRelation)
+	// Bloquear e silenciar são relações independentes
+	assert.NoError(t, relationRepo.AddRelation(context.Background(), user, other, models.RelationMute))
+
+	blocked, err := relationRepo.HasRelation(context.Background(), user, other, models.RelationBlock)
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+
+	blocked, err = relationRepo.HasRelation(context.Background(), other, user, models.RelationBlock)
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+
+	// O bloqueio vale nos dois sentidos
+	blocked, err = relationRepo.IsBlockedBetween(context.Background(), other, user)
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+
+	ids, err := relationRepo.GetRelatedIDs(context.Background(), user, models.RelationBlock, models.RelationMute)
+	assert.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{other}, ids)
+
+	assert.NoError(t, relationRepo.RemoveRelation(context.Background(), user, other, models.RelationBlock))
+	assert.ErrorIs(t, relationRepo.RemoveRelation(context.Background(), user, other, models.RelationBlock), ErrNoChange)
+
+	blocked, err = relationRepo.IsBlockedBetween(context.Background(), user, other)
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+}
+
+func TestGetRelations(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	relationRepo := NewRelationRepository(container.Database, utils.RelationCollectionTest)
+	user := primitive.NewObjectID()
+
+	var targets []primitive.ObjectID
+	for range 3 {
+		target := primitive.NewObjectID()
+		assert.NoError(t, relationRepo.AddRelation(context.Background(), user, target, models.RelationMute))
+		targets = append(targets, target)
+	}
+	assert.NoError(t, relationRepo.AddRelation(context.Background(), user, targets[0], models.RelationBlock))
+
+	page, err := relationRepo.GetRelations(context.Background(), user, models.RelationMute, models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, targets[0], page.Items[0].TargetID)
+		assert.Equal(t, targets[1], page.Items[1].TargetID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page, err = relationRepo.GetRelations(context.Background(), user, models.RelationBlock, models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	deleted, err := relationRepo.DeleteUserRelations(context.Background(), targets[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
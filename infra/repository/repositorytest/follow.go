@@ -0,0 +1,97 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type FollowRepositoryFactory func(t *testing.T) models.FollowRepositoryInterface
+
+// Executa os testes de conformidade de `FollowRepositoryInterface`.
+func RunFollowRepositoryTests(t *testing.T, newRepository FollowRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Follow and unfollow", func(t *testing.T) {
+		repo := newRepository(t)
+		follower, followee := primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.Follow(ctx, follower, followee))
+		assert.ErrorIs(t, repo.Follow(ctx, follower, followee), repository.ErrNoChange)
+		assert.ErrorIs(t, repo.Follow(ctx, follower, follower), models.ErrSelfFollow)
+
+		ids, err := repo.GetFollowingIDs(ctx, follower)
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{followee}, ids)
+
+		require.NoError(t, repo.Unfollow(ctx, follower, followee))
+		assert.ErrorIs(t, repo.Unfollow(ctx, follower, followee), repository.ErrNoChange)
+
+		ids, err = repo.GetFollowingIDs(ctx, follower)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("Delete user follows", func(t *testing.T) {
+		repo := newRepository(t)
+		user, follower, followee, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.Follow(ctx, follower, user))
+		require.NoError(t, repo.Follow(ctx, user, followee))
+		require.NoError(t, repo.Follow(ctx, follower, other))
+
+		deleted, err := repo.DeleteUserFollows(ctx, user)
+		require.NoError(t, err)
+		assert.Len(t, deleted, 2)
+
+		ids, err := repo.GetFollowingIDs(ctx, follower)
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{other}, ids)
+	})
+
+	t.Run("Count follows", func(t *testing.T) {
+		repo := newRepository(t)
+		first, second, third := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.Follow(ctx, first, second))
+		require.NoError(t, repo.Follow(ctx, first, third))
+		require.NoError(t, repo.Follow(ctx, second, third))
+
+		counts, err := repo.CountFollows(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[primitive.ObjectID]models.FollowCounts{
+			first:  {Followers: 0, Following: 2},
+			second: {Followers: 1, Following: 1},
+			third:  {Followers: 2, Following: 0},
+		}, counts)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepository(t)
+		followee := primitive.NewObjectID()
+
+		var followers []primitive.ObjectID
+		for range 7 {
+			follower := primitive.NewObjectID()
+			require.NoError(t, repo.Follow(ctx, follower, followee))
+			followers = append(followers, follower)
+		}
+		require.NoError(t, repo.Follow(ctx, followee, followers[0]))
+
+		walkPages(t, 3, func(req models.PageRequest) (*models.Page[*models.Follow], error) {
+			return repo.GetFollowers(ctx, followee, req)
+		}, func(follow *models.Follow) primitive.ObjectID { return follow.FollowerID }, followers)
+
+		following, err := pageItems(repo.GetFollowing(ctx, followee, everything))
+		require.NoError(t, err)
+		if assert.Len(t, following, 1) {
+			assert.Equal(t, followers[0], following[0].FolloweeID)
+		}
+	})
+}
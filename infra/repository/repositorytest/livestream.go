@@ -0,0 +1,325 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type LiveStreamRepositoryFactory func(t *testing.T) models.LiveStreamRepositoryInterface
+
+func createLiveStream(t *testing.T, repo models.LiveStreamRepositoryInterface, name, streamKey string, publisherID primitive.ObjectID) primitive.ObjectID {
+	id, err := repo.CreateLiveStream(context.Background(), name, "fake-thumbnail", streamKey, publisherID)
+	require.NoError(t, err)
+
+	objectID, ok := id.(primitive.ObjectID)
+	require.True(t, ok, "CreateLiveStream must return a primitive.ObjectID")
+
+	return objectID
+}
+
+func liveStreamIDs(liveStreams []*models.LiveStream) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(liveStreams))
+	for _, ls := range liveStreams {
+		ids = append(ids, ls.ID)
+	}
+	return ids
+}
+
+// Executa os testes de conformidade de `LiveStreamRepositoryInterface`.
+func RunLiveStreamRepositoryTests(t *testing.T, newRepository LiveStreamRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Create and get", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+		id := createLiveStream(t, repo, "Test Stream", "streamkey-test", publisherID)
+
+		byID, err := repo.GetLiveStreamById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, byID.ID)
+		assert.Equal(t, "Test Stream", byID.Name)
+		assert.Equal(t, "fake-thumbnail", byID.Thumbnail)
+		assert.Equal(t, publisherID, byID.PublisherId)
+		assert.Equal(t, models.HashStreamKey("streamkey-test"), byID.StreamKeyHash)
+		assert.False(t, byID.LiveStatus)
+		assert.Zero(t, byID.ViewerCount)
+
+		byName, err := repo.GetLiveStreamByName(ctx, "Test Stream")
+		require.NoError(t, err)
+		assert.Equal(t, id, byName.ID)
+
+		byKey, err := repo.GetLiveStreamByStreamKey(ctx, "streamkey-test")
+		require.NoError(t, err)
+		assert.Equal(t, id, byKey.ID)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := newRepository(t)
+		missing := primitive.NewObjectID()
+
+		_, err := repo.GetLiveStreamById(ctx, missing)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repo.GetLiveStreamByName(ctx, "missing")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repo.GetLiveStreamByStreamKey(ctx, "missing")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		assert.ErrorIs(t, repo.UpdateLiveStream(ctx, missing, bson.M{"name": "missing"}), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.IncrementLiveStreamUserCount(ctx, missing), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.DecrementLiveStreamUserCount(ctx, missing), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.SetLiveStreamViewerCount(ctx, missing, 1), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteLiveStream(ctx, missing, time.Now()), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.PurgeLiveStream(ctx, missing), repository.ErrNotFound)
+
+		rotation := models.NewStreamKeyRotation(primitive.NewObjectID(), "", "")
+		assert.ErrorIs(t, repo.RotateLiveStreamKey(ctx, missing, "new-key", rotation), repository.ErrNotFound)
+	})
+
+	t.Run("Unique stream key", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+		createLiveStream(t, repo, "Test Stream", "streamkey-test", publisherID)
+		otherID := createLiveStream(t, repo, "Other Stream", "streamkey-other", publisherID)
+
+		_, err := repo.CreateLiveStream(ctx, "Duplicate", "fake-thumbnail", "streamkey-test", publisherID)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		rotation := models.NewStreamKeyRotation(publisherID, models.HashStreamKey("streamkey-other"), "")
+		err = repo.RotateLiveStreamKey(ctx, otherID, "streamkey-test", rotation)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createLiveStream(t, repo, "Test Stream", "streamkey-test", primitive.NewObjectID())
+
+		require.NoError(t, repo.UpdateLiveStream(ctx, id, bson.M{"name": "Updated", "live_stream_status": true}))
+
+		ls, err := repo.GetLiveStreamById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Updated", ls.Name)
+		assert.True(t, ls.LiveStatus)
+		assert.False(t, ls.UpdatedAt.IsZero())
+	})
+
+	t.Run("Rotate stream key", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+		id := createLiveStream(t, repo, "Test Stream", "streamkey-test", publisherID)
+
+		rotation := models.NewStreamKeyRotation(publisherID, models.HashStreamKey("streamkey-test"), "leaked")
+		require.NoError(t, repo.RotateLiveStreamKey(ctx, id, "streamkey-new", rotation))
+
+		_, err := repo.GetLiveStreamByStreamKey(ctx, "streamkey-test")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		ls, err := repo.GetLiveStreamByStreamKey(ctx, "streamkey-new")
+		require.NoError(t, err)
+		assert.Equal(t, id, ls.ID)
+		require.Len(t, ls.StreamKeyRotations, 1)
+		assert.Equal(t, "leaked", ls.StreamKeyRotations[0].Reason)
+	})
+
+	t.Run("Viewer count", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createLiveStream(t, repo, "Test Stream", "streamkey-test", primitive.NewObjectID())
+
+		viewerCount := func() int {
+			ls, err := repo.GetLiveStreamById(ctx, id)
+			require.NoError(t, err)
+			return ls.ViewerCount
+		}
+
+		require.NoError(t, repo.IncrementLiveStreamUserCount(ctx, id))
+		assert.Equal(t, 1, viewerCount())
+
+		require.NoError(t, repo.DecrementLiveStreamUserCount(ctx, id))
+		assert.Equal(t, 0, viewerCount())
+
+		// O contador não fica negativo. Só `updated_at` muda, e na mesma
+		// precisão de milissegundos a atualização pode não alterar nada
+		err := repo.DecrementLiveStreamUserCount(ctx, id)
+		if err != nil {
+			assert.ErrorIs(t, err, repository.ErrNoChange)
+		}
+		assert.Equal(t, 0, viewerCount())
+
+		require.NoError(t, repo.SetLiveStreamViewerCount(ctx, id, 5))
+		assert.Equal(t, 5, viewerCount())
+
+		require.NoError(t, repo.SetLiveStreamViewerCount(ctx, id, -3))
+		assert.Equal(t, 0, viewerCount())
+	})
+
+	t.Run("Listings", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+
+		offline := createLiveStream(t, repo, "Offline", "streamkey-offline", publisherID)
+		first := createLiveStream(t, repo, "First", "streamkey-first", publisherID)
+		second := createLiveStream(t, repo, "Second", "streamkey-second", primitive.NewObjectID())
+		require.NoError(t, repo.UpdateLiveStream(ctx, first, bson.M{"live_stream_status": true}))
+		require.NoError(t, repo.UpdateLiveStream(ctx, second, bson.M{"live_stream_status": true}))
+
+		all, err := pageItems(repo.GetAllLiveStreams(ctx, everything))
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{offline, first, second}, liveStreamIDs(all))
+
+		byUser, err := pageItems(repo.GetAllLiveStreamsByUserId(ctx, publisherID, everything))
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{offline, first}, liveStreamIDs(byUser))
+
+		active, err := repo.GetActiveLiveStreams(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{first, second}, liveStreamIDs(active))
+
+		third := createLiveStream(t, repo, "Third", "streamkey-third", primitive.NewObjectID())
+		require.NoError(t, repo.UpdateLiveStream(ctx, third, bson.M{"live_stream_status": true, "viewer_count": 10}))
+
+		mostWatched, err := repo.GetMostWatchedLiveStreams(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{third, first}, liveStreamIDs(mostWatched))
+
+		require.NoError(t, repo.UpdateLiveStream(ctx, third, bson.M{"live_stream_status": false}))
+
+		byPublishers, err := repo.GetActiveLiveStreamsByPublishers(ctx, []primitive.ObjectID{publisherID, primitive.NewObjectID()})
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{first}, liveStreamIDs(byPublishers))
+
+		byPublishers, err = repo.GetActiveLiveStreamsByPublishers(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, byPublishers)
+
+		none, err := pageItems(repo.GetAllLiveStreamsByUserId(ctx, primitive.NewObjectID(), everything))
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+
+		var all, byUser []primitive.ObjectID
+		for i, key := range []string{"a", "b", "c", "d", "e"} {
+			owner := publisherID
+			if i == 2 {
+				owner = primitive.NewObjectID()
+			}
+
+			id := createLiveStream(t, repo, "Stream "+key, "streamkey-"+key, owner)
+			all = append(all, id)
+			if owner == publisherID {
+				byUser = append(byUser, id)
+			}
+		}
+
+		position := func(ls *models.LiveStream) primitive.ObjectID { return ls.ID }
+		walkPages(t, 2, func(req models.PageRequest) (*models.Page[*models.LiveStream], error) {
+			return repo.GetAllLiveStreams(ctx, req)
+		}, position, all)
+		walkPages(t, 3, func(req models.PageRequest) (*models.Page[*models.LiveStream], error) {
+			return repo.GetAllLiveStreamsByUserId(ctx, publisherID, req)
+		}, position, byUser)
+
+		// Depois do último item não há itens, mas a página anterior existe
+		page, err := repo.GetAllLiveStreams(ctx, pageAt(t, 1, models.Cursor{ID: all[4]}.Encode()))
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+		assert.Empty(t, page.NextCursor)
+		assert.NotEmpty(t, page.PrevCursor)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+		id := createLiveStream(t, repo, "Test Stream", "streamkey-test", publisherID)
+		createLiveStream(t, repo, "Other Stream", "streamkey-other", publisherID)
+		kept := createLiveStream(t, repo, "Kept Stream", "streamkey-kept", primitive.NewObjectID())
+
+		require.NoError(t, repo.DeleteLiveStream(ctx, id, time.Now()))
+		assert.ErrorIs(t, repo.DeleteLiveStream(ctx, id, time.Now()), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateLiveStream(ctx, id, bson.M{"name": "Deleted"}), repository.ErrNotFound)
+
+		_, err := repo.GetLiveStreamById(ctx, id)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = repo.GetLiveStreamByStreamKey(ctx, "streamkey-test")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		deleted, err := repo.DeleteLiveStreamsByPublisher(ctx, publisherID, time.Now())
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, deleted)
+
+		deleted, err = repo.DeleteLiveStreamsByPublisher(ctx, publisherID, time.Now())
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+
+		all, err := pageItems(repo.GetAllLiveStreams(ctx, everything))
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{kept}, liveStreamIDs(all))
+
+		byUser, err := pageItems(repo.GetAllLiveStreamsByUserId(ctx, publisherID, everything))
+		require.NoError(t, err)
+		assert.Empty(t, byUser)
+	})
+
+	t.Run("Restore and purge", func(t *testing.T) {
+		repo := newRepository(t)
+		publisherID := primitive.NewObjectID()
+		alone := createLiveStream(t, repo, "Alone Stream", "streamkey-alone", publisherID)
+		first := createLiveStream(t, repo, "Test Stream", "streamkey-test", publisherID)
+		second := createLiveStream(t, repo, "Other Stream", "streamkey-other", publisherID)
+
+		// Lives removidas sozinhas antes não voltam junto com as do usuário
+		aloneDeletedAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+		require.NoError(t, repo.DeleteLiveStream(ctx, alone, aloneDeletedAt))
+
+		deletedAt := time.Now().Truncate(time.Millisecond)
+		deleted, err := repo.DeleteLiveStreamsByPublisher(ctx, publisherID, deletedAt)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, deleted)
+
+		old, err := repo.GetLiveStreamsDeletedBefore(ctx, deletedAt)
+		require.NoError(t, err)
+		require.Len(t, old, 1)
+		assert.Equal(t, alone, old[0].ID)
+		require.NotNil(t, old[0].DeletedAt)
+		assert.True(t, aloneDeletedAt.Equal(*old[0].DeletedAt))
+
+		restored, err := repo.RestoreLiveStreamsByPublisher(ctx, publisherID, deletedAt)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, restored)
+
+		byUser, err := pageItems(repo.GetAllLiveStreamsByUserId(ctx, publisherID, everything))
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{first, second}, liveStreamIDs(byUser))
+		assert.Nil(t, byUser[0].DeletedAt)
+
+		assert.ErrorIs(t, repo.PurgeLiveStream(ctx, first), repository.ErrNotFound)
+		require.NoError(t, repo.PurgeLiveStream(ctx, alone))
+		assert.ErrorIs(t, repo.PurgeLiveStream(ctx, alone), repository.ErrNotFound)
+
+		require.NoError(t, repo.DeleteLiveStream(ctx, first, deletedAt))
+		purged, err := repo.PurgeLiveStreamsByPublisher(ctx, publisherID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, purged)
+
+		old, err = repo.GetLiveStreamsDeletedBefore(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, old)
+
+		all, err := pageItems(repo.GetAllLiveStreams(ctx, everything))
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+}
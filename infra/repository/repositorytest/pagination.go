@@ -0,0 +1,67 @@
+package repositorytest
+
+import (
+	"testing"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Página grande o suficiente para conter todos os itens de um teste.
+var everything = models.PageRequest{Limit: 100}
+
+func pageItems[T any](page *models.Page[T], err error) ([]T, error) {
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// Requisição da página indicada pelo token `cursor`.
+func pageAt(t *testing.T, limit int, cursor string) models.PageRequest {
+	require.NotEmpty(t, cursor, "expected a cursor to the page")
+
+	decoded, err := models.DecodeCursor(cursor)
+	require.NoError(t, err)
+
+	return models.PageRequest{Limit: limit, Cursor: decoded}
+}
+
+// Percorre a listagem em páginas de `limit` itens até a última e depois
+// volta até a primeira, verificando os itens e os cursores de cada página.
+func walkPages[T any](t *testing.T, limit int, list func(req models.PageRequest) (*models.Page[T], error), id func(item T) primitive.ObjectID, want []primitive.ObjectID) {
+	ids := func(page *models.Page[T]) []primitive.ObjectID {
+		result := make([]primitive.ObjectID, 0, len(page.Items))
+		for _, item := range page.Items {
+			result = append(result, id(item))
+		}
+		return result
+	}
+
+	var pages []*models.Page[T]
+	req := models.PageRequest{Limit: limit}
+	for start := 0; ; start += limit {
+		page, err := list(req)
+		require.NoError(t, err)
+
+		end := min(start+limit, len(want))
+		require.Equal(t, want[start:end], ids(page), "page starting at %d", start)
+		require.Equal(t, start > 0, page.PrevCursor != "", "prev cursor of page starting at %d", start)
+		pages = append(pages, page)
+
+		if end == len(want) {
+			require.Empty(t, page.NextCursor, "next cursor of the last page")
+			break
+		}
+		req = pageAt(t, limit, page.NextCursor)
+	}
+
+	for i := len(pages) - 1; i > 0; i-- {
+		page, err := list(pageAt(t, limit, pages[i].PrevCursor))
+		require.NoError(t, err)
+		require.Equal(t, ids(pages[i-1]), ids(page), "page %d walking back", i-1)
+		require.Equal(t, i-1 > 0, page.PrevCursor != "", "prev cursor of page %d walking back", i-1)
+		require.NotEmpty(t, page.NextCursor, "next cursor of page %d walking back", i-1)
+	}
+}
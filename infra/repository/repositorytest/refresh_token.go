@@ -0,0 +1,103 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type RefreshTokenRepositoryFactory func(t *testing.T) models.RefreshTokenRepositoryInterface
+
+// Executa os testes de conformidade de `RefreshTokenRepositoryInterface`.
+func RunRefreshTokenRepositoryTests(t *testing.T, newRepository RefreshTokenRepositoryFactory) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("Create and get", func(t *testing.T) {
+		repo := newRepository(t)
+		userID, familyID := primitive.NewObjectID(), primitive.NewObjectID()
+
+		id, err := repo.CreateRefreshToken(ctx, userID, familyID, "hash", "phone", expiresAt)
+		require.NoError(t, err)
+
+		token, err := repo.GetRefreshTokenByHash(ctx, "hash")
+		require.NoError(t, err)
+		assert.Equal(t, id, token.ID)
+		assert.Equal(t, userID, token.UserID)
+		assert.Equal(t, familyID, token.FamilyID)
+		assert.Equal(t, "phone", token.Device)
+		assert.Nil(t, token.RevokedAt)
+
+		_, err = repo.GetRefreshTokenByHash(ctx, "other")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		repo := newRepository(t)
+
+		id, err := repo.CreateRefreshToken(ctx, primitive.NewObjectID(), primitive.NewObjectID(), "hash", "phone", expiresAt)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.RevokeRefreshToken(ctx, id.(primitive.ObjectID)))
+		// Apenas uma de duas rotações do mesmo token tem sucesso
+		assert.ErrorIs(t, repo.RevokeRefreshToken(ctx, id.(primitive.ObjectID)), repository.ErrNoChange)
+
+		token, err := repo.GetRefreshTokenByHash(ctx, "hash")
+		require.NoError(t, err)
+		assert.NotNil(t, token.RevokedAt)
+	})
+
+	t.Run("Revoke family and user", func(t *testing.T) {
+		repo := newRepository(t)
+		userID, familyID := primitive.NewObjectID(), primitive.NewObjectID()
+
+		_, err := repo.CreateRefreshToken(ctx, userID, familyID, "first", "phone", expiresAt)
+		require.NoError(t, err)
+		_, err = repo.CreateRefreshToken(ctx, userID, primitive.NewObjectID(), "second", "laptop", expiresAt)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.RevokeRefreshTokenFamily(ctx, familyID))
+
+		first, err := repo.GetRefreshTokenByHash(ctx, "first")
+		require.NoError(t, err)
+		assert.NotNil(t, first.RevokedAt)
+
+		second, err := repo.GetRefreshTokenByHash(ctx, "second")
+		require.NoError(t, err)
+		assert.Nil(t, second.RevokedAt)
+
+		require.NoError(t, repo.RevokeAllUserRefreshTokens(ctx, userID))
+
+		second, err = repo.GetRefreshTokenByHash(ctx, "second")
+		require.NoError(t, err)
+		assert.NotNil(t, second.RevokedAt)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepository(t)
+		userID := primitive.NewObjectID()
+
+		_, err := repo.CreateRefreshToken(ctx, userID, primitive.NewObjectID(), "first", "phone", expiresAt)
+		require.NoError(t, err)
+		_, err = repo.CreateRefreshToken(ctx, userID, primitive.NewObjectID(), "second", "phone", expiresAt)
+		require.NoError(t, err)
+		_, err = repo.CreateRefreshToken(ctx, primitive.NewObjectID(), primitive.NewObjectID(), "other", "phone", expiresAt)
+		require.NoError(t, err)
+
+		deleted, err := repo.DeleteAllUserRefreshTokens(ctx, userID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, deleted)
+
+		_, err = repo.GetRefreshTokenByHash(ctx, "first")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = repo.GetRefreshTokenByHash(ctx, "other")
+		assert.NoError(t, err)
+	})
+}
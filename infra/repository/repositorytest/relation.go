@@ -0,0 +1,110 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type RelationRepositoryFactory func(t *testing.T) models.RelationRepositoryInterface
+
+// Executa os testes de conformidade de `RelationRepositoryInterface`.
+func RunRelationRepositoryTests(t *testing.T, newRepository RelationRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Add and remove", func(t *testing.T) {
+		repo := newRepository(t)
+		user, target := primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.AddRelation(ctx, user, target, models.RelationBlock))
+		assert.ErrorIs(t, repo.AddRelation(ctx, user, target, models.RelationBlock), repository.ErrNoChange)
+		assert.ErrorIs(t, repo.AddRelation(ctx, user, user, models.RelationMute), models.ErrSelfRelation)
+
+		blocked, err := repo.HasRelation(ctx, user, target, models.RelationBlock)
+		require.NoError(t, err)
+		assert.True(t, blocked)
+
+		muted, err := repo.HasRelation(ctx, user, target, models.RelationMute)
+		require.NoError(t, err)
+		assert.False(t, muted)
+
+		require.NoError(t, repo.RemoveRelation(ctx, user, target, models.RelationBlock))
+		assert.ErrorIs(t, repo.RemoveRelation(ctx, user, target, models.RelationBlock), repository.ErrNoChange)
+	})
+
+	t.Run("Blocked between", func(t *testing.T) {
+		repo := newRepository(t)
+		user, target, other := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.AddRelation(ctx, user, target, models.RelationBlock))
+		require.NoError(t, repo.AddRelation(ctx, user, other, models.RelationMute))
+
+		for _, pair := range [][2]primitive.ObjectID{{user, target}, {target, user}} {
+			blocked, err := repo.IsBlockedBetween(ctx, pair[0], pair[1])
+			require.NoError(t, err)
+			assert.True(t, blocked)
+		}
+
+		// Silenciar não é bloquear
+		blocked, err := repo.IsBlockedBetween(ctx, other, user)
+		require.NoError(t, err)
+		assert.False(t, blocked)
+	})
+
+	t.Run("Related ids", func(t *testing.T) {
+		repo := newRepository(t)
+		user, blocked, muted := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.AddRelation(ctx, user, blocked, models.RelationBlock))
+		require.NoError(t, repo.AddRelation(ctx, user, blocked, models.RelationMute))
+		require.NoError(t, repo.AddRelation(ctx, user, muted, models.RelationMute))
+
+		ids, err := repo.GetRelatedIDs(ctx, user, models.RelationBlock, models.RelationMute)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []primitive.ObjectID{blocked, muted}, ids)
+
+		ids, err = repo.GetRelatedIDs(ctx, user, models.RelationBlock)
+		require.NoError(t, err)
+		assert.Equal(t, []primitive.ObjectID{blocked}, ids)
+	})
+
+	t.Run("Delete user relations", func(t *testing.T) {
+		repo := newRepository(t)
+		user, other := primitive.NewObjectID(), primitive.NewObjectID()
+
+		require.NoError(t, repo.AddRelation(ctx, user, other, models.RelationBlock))
+		require.NoError(t, repo.AddRelation(ctx, other, user, models.RelationMute))
+		require.NoError(t, repo.AddRelation(ctx, other, primitive.NewObjectID(), models.RelationMute))
+
+		deleted, err := repo.DeleteUserRelations(ctx, user)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, deleted)
+
+		ids, err := repo.GetRelatedIDs(ctx, other, models.RelationMute)
+		require.NoError(t, err)
+		assert.Len(t, ids, 1)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepository(t)
+		user := primitive.NewObjectID()
+
+		var targets []primitive.ObjectID
+		for range 7 {
+			target := primitive.NewObjectID()
+			require.NoError(t, repo.AddRelation(ctx, user, target, models.RelationMute))
+			targets = append(targets, target)
+		}
+		require.NoError(t, repo.AddRelation(ctx, user, targets[0], models.RelationBlock))
+
+		walkPages(t, 3, func(req models.PageRequest) (*models.Page[*models.Relation], error) {
+			return repo.GetRelations(ctx, user, models.RelationMute, req)
+		}, func(relation *models.Relation) primitive.ObjectID { return relation.TargetID }, targets)
+	})
+}
@@ -0,0 +1,141 @@
+package repositorytest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type StreamSessionRepositoryFactory func(t *testing.T) models.StreamSessionRepositoryInterface
+
+func createStreamSession(t *testing.T, repo models.StreamSessionRepositoryInterface, liveStreamID, publisherID primitive.ObjectID) primitive.ObjectID {
+	id, err := repo.CreateStreamSession(context.Background(), liveStreamID, publisherID, "127.0.0.1", "1", models.EncoderInfo{FlashVersion: "FMLE/3.0"})
+	require.NoError(t, err)
+
+	objectID, ok := id.(primitive.ObjectID)
+	require.True(t, ok, "CreateStreamSession must return a primitive.ObjectID")
+
+	return objectID
+}
+
+// Executa os testes de conformidade de `StreamSessionRepositoryInterface`.
+func RunStreamSessionRepositoryTests(t *testing.T, newRepository StreamSessionRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Open sessions", func(t *testing.T) {
+		repo := newRepository(t)
+		open, closed := primitive.NewObjectID(), primitive.NewObjectID()
+
+		id := createStreamSession(t, repo, open, primitive.NewObjectID())
+		createStreamSession(t, repo, closed, primitive.NewObjectID())
+		require.NoError(t, repo.CloseStreamSessions(ctx, closed))
+
+		session, err := repo.GetOpenStreamSession(ctx, open)
+		require.NoError(t, err)
+		assert.Equal(t, id, session.ID)
+		assert.Equal(t, "FMLE/3.0", session.Encoder.FlashVersion)
+		assert.Nil(t, session.EndedAt)
+
+		_, err = repo.GetOpenStreamSession(ctx, closed)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		sessions, err := repo.GetOpenStreamSessions(ctx, []primitive.ObjectID{open, closed, primitive.NewObjectID()})
+		require.NoError(t, err)
+		if assert.Len(t, sessions, 1) {
+			assert.Equal(t, open, sessions[0].LiveStreamID)
+		}
+
+		sessions, err = repo.GetOpenStreamSessions(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+
+	t.Run("Viewer samples", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID := primitive.NewObjectID()
+		createStreamSession(t, repo, liveStreamID, primitive.NewObjectID())
+
+		require.NoError(t, repo.RecordStreamSessionViewers(ctx, liveStreamID, 2))
+		require.NoError(t, repo.RecordStreamSessionViewers(ctx, liveStreamID, 6))
+		require.NoError(t, repo.CloseStreamSessions(ctx, liveStreamID))
+
+		// Sessões encerradas não recebem mais amostras
+		require.NoError(t, repo.RecordStreamSessionViewers(ctx, liveStreamID, 100))
+
+		sessions, err := pageItems(repo.GetStreamSessionsByLiveStream(ctx, liveStreamID, everything))
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.NotNil(t, sessions[0].EndedAt)
+		assert.Equal(t, 6, sessions[0].PeakViewers)
+		assert.Equal(t, 4.0, sessions[0].AverageViewers)
+	})
+
+	t.Run("Close without samples", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID := primitive.NewObjectID()
+		createStreamSession(t, repo, liveStreamID, primitive.NewObjectID())
+
+		require.NoError(t, repo.CloseStreamSessions(ctx, liveStreamID))
+
+		sessions, err := pageItems(repo.GetStreamSessionsByLiveStream(ctx, liveStreamID, everything))
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Zero(t, sessions[0].AverageViewers)
+	})
+
+	t.Run("Delete by publisher", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID, publisherID := primitive.NewObjectID(), primitive.NewObjectID()
+
+		createStreamSession(t, repo, liveStreamID, publisherID)
+		createStreamSession(t, repo, liveStreamID, publisherID)
+		other := createStreamSession(t, repo, primitive.NewObjectID(), primitive.NewObjectID())
+
+		count, err := repo.CountStreamSessionsByPublisher(ctx, publisherID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+
+		deleted, err := repo.DeleteStreamSessionsByPublisher(ctx, publisherID)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, deleted)
+
+		count, err = repo.CountStreamSessionsByPublisher(ctx, publisherID)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		sessions, err := pageItems(repo.GetStreamSessionsByLiveStream(ctx, liveStreamID, everything))
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		_, err = repo.GetOpenStreamSessions(ctx, []primitive.ObjectID{other})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID := primitive.NewObjectID()
+
+		var ids []primitive.ObjectID
+		for range 7 {
+			ids = append(ids, createStreamSession(t, repo, liveStreamID, primitive.NewObjectID()))
+			require.NoError(t, repo.CloseStreamSessions(ctx, liveStreamID))
+			// Os inícios ficam em milissegundos distintos
+			time.Sleep(2 * time.Millisecond)
+		}
+		createStreamSession(t, repo, primitive.NewObjectID(), primitive.NewObjectID())
+
+		// Da sessão mais recente para a mais antiga
+		slices.Reverse(ids)
+		walkPages(t, 3, func(req models.PageRequest) (*models.Page[*models.StreamSession], error) {
+			return repo.GetStreamSessionsByLiveStream(ctx, liveStreamID, req)
+		}, func(session *models.StreamSession) primitive.ObjectID { return session.ID }, ids)
+	})
+}
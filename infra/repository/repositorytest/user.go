@@ -0,0 +1,315 @@
+// Pacote com os testes de conformidade dos repositórios. Toda
+// implementação das interfaces de `models` deve passar por eles, para
+// que os handlers possam usar qualquer uma delas sem diferença.
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type UserRepositoryFactory func(t *testing.T) models.UserRepositoryInterface
+
+func createUser(t *testing.T, repo models.UserRepositoryInterface, username, email string) primitive.ObjectID {
+	id, err := repo.CreateUser(context.Background(), username, email, "password123")
+	require.NoError(t, err)
+
+	objectID, ok := id.(primitive.ObjectID)
+	require.True(t, ok, "CreateUser must return a primitive.ObjectID")
+
+	return objectID
+}
+
+// Executa os testes de conformidade de `UserRepositoryInterface`.
+func RunUserRepositoryTests(t *testing.T, newRepository UserRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Create and get", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createUser(t, repo, "johndoe", "johndoe@example.com")
+
+		byID, err := repo.GetUserById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, byID.ID)
+		assert.Equal(t, "johndoe", byID.Username)
+		assert.Equal(t, "johndoe@example.com", byID.Email)
+		assert.Equal(t, "password123", byID.Password)
+		assert.Zero(t, byID.FollowerCount)
+		assert.Zero(t, byID.FollowingCount)
+
+		byEmail, err := repo.GetUserByEmail(ctx, "johndoe@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, id, byEmail.ID)
+
+		byUsername, err := repo.GetUserByUsername(ctx, "johndoe")
+		require.NoError(t, err)
+		assert.Equal(t, id, byUsername.ID)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		repo := newRepository(t)
+		missing := primitive.NewObjectID()
+
+		_, err := repo.GetUserById(ctx, missing)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repo.GetUserByEmail(ctx, "nobody@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repo.GetUserByUsername(ctx, "nobody")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		assert.ErrorIs(t, repo.UpdateUser(ctx, missing, bson.M{"username": "nobody"}), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteUser(ctx, missing, time.Now()), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.RestoreUser(ctx, missing), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.PurgeUser(ctx, missing), repository.ErrNotFound)
+	})
+
+	t.Run("Unique email and username", func(t *testing.T) {
+		repo := newRepository(t)
+		createUser(t, repo, "johndoe", "johndoe@example.com")
+		otherID := createUser(t, repo, "janedoe", "janedoe@example.com")
+
+		_, err := repo.CreateUser(ctx, "other", "johndoe@example.com", "password123")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repo.CreateUser(ctx, "johndoe", "other@example.com", "password123")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		err = repo.UpdateUser(ctx, otherID, bson.M{"email": "johndoe@example.com"})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createUser(t, repo, "johndoe", "johndoe@example.com")
+
+		before, err := repo.GetUserById(ctx, id)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.UpdateUser(ctx, id, bson.M{"username": "johnny"}))
+
+		after, err := repo.GetUserById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "johnny", after.Username)
+		assert.Equal(t, before.Email, after.Email)
+		assert.False(t, after.UpdatedAt.Before(before.UpdatedAt))
+	})
+
+	t.Run("Follow counts", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createUser(t, repo, "johndoe", "johndoe@example.com")
+		removedID := createUser(t, repo, "janedoe", "janedoe@example.com")
+
+		require.NoError(t, repo.AddFollowCounts(ctx, id, 2, 1))
+		require.NoError(t, repo.AddFollowCounts(ctx, id, -1, 0))
+		// Usuários inexistentes são ignorados
+		require.NoError(t, repo.AddFollowCounts(ctx, primitive.NewObjectID(), 1, 1))
+
+		user, err := repo.GetUserById(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, user.FollowerCount)
+		assert.Equal(t, 1, user.FollowingCount)
+
+		// Removidos continuam contando até serem apagados
+		require.NoError(t, repo.DeleteUser(ctx, removedID, time.Now()))
+		require.NoError(t, repo.AddFollowCounts(ctx, removedID, 0, 1))
+		require.NoError(t, repo.RestoreUser(ctx, removedID))
+
+		removed, err := repo.GetUserById(ctx, removedID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, removed.FollowingCount)
+	})
+
+	t.Run("Set follow counts", func(t *testing.T) {
+		repo := newRepository(t)
+		followerID := createUser(t, repo, "johndoe", "johndoe@example.com")
+		followeeID := createUser(t, repo, "janedoe", "janedoe@example.com")
+		otherID := createUser(t, repo, "other", "other@example.com")
+		require.NoError(t, repo.AddFollowCounts(ctx, otherID, 3, 3))
+
+		counts := map[primitive.ObjectID]models.FollowCounts{
+			followerID:              {Following: 1},
+			followeeID:              {Followers: 1},
+			primitive.NewObjectID(): {Followers: 1},
+		}
+
+		// O usuário ausente das contagens é zerado
+		repaired, err := repo.SetFollowCounts(ctx, counts)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, repaired)
+
+		repaired, err = repo.SetFollowCounts(ctx, counts)
+		require.NoError(t, err)
+		assert.Zero(t, repaired)
+
+		follower, err := repo.GetUserById(ctx, followerID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, follower.FollowingCount)
+		assert.Zero(t, follower.FollowerCount)
+
+		followee, err := repo.GetUserById(ctx, followeeID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, followee.FollowerCount)
+
+		other, err := repo.GetUserById(ctx, otherID)
+		require.NoError(t, err)
+		assert.Zero(t, other.FollowerCount)
+		assert.Zero(t, other.FollowingCount)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createUser(t, repo, "johndoe", "johndoe@example.com")
+
+		deletedAt := time.Now().Truncate(time.Millisecond)
+		require.NoError(t, repo.DeleteUser(ctx, id, deletedAt))
+
+		_, err := repo.GetUserById(ctx, id)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = repo.GetUserByEmail(ctx, "johndoe@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+		_, err = repo.GetUserByUsername(ctx, "johndoe")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		users, err := pageItems(repo.GetAllUsers(ctx, everything))
+		require.NoError(t, err)
+		assert.Empty(t, users)
+
+		assert.ErrorIs(t, repo.DeleteUser(ctx, id, time.Now()), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.UpdateUser(ctx, id, bson.M{"username": "johnny"}), repository.ErrNotFound)
+
+		// O email e o username continuam reservados até a remoção definitiva
+		_, err = repo.CreateUser(ctx, "johndoe", "other@example.com", "password123")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		deletedUser, err := repo.GetDeletedUserByEmail(ctx, "johndoe@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, id, deletedUser.ID)
+		require.NotNil(t, deletedUser.DeletedAt)
+		assert.True(t, deletedAt.Equal(*deletedUser.DeletedAt))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		repo := newRepository(t)
+		id := createUser(t, repo, "johndoe", "johndoe@example.com")
+
+		assert.ErrorIs(t, repo.RestoreUser(ctx, id), repository.ErrNotFound)
+
+		require.NoError(t, repo.DeleteUser(ctx, id, time.Now()))
+		require.NoError(t, repo.RestoreUser(ctx, id))
+		assert.ErrorIs(t, repo.RestoreUser(ctx, id), repository.ErrNotFound)
+
+		user, err := repo.GetUserById(ctx, id)
+		require.NoError(t, err)
+		assert.Nil(t, user.DeletedAt)
+
+		_, err = repo.GetDeletedUserByEmail(ctx, "johndoe@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		repo := newRepository(t)
+		oldID := createUser(t, repo, "johndoe", "johndoe@example.com")
+		recentID := createUser(t, repo, "janedoe", "janedoe@example.com")
+		activeID := createUser(t, repo, "active", "active@example.com")
+
+		cutoff := time.Now().Add(-time.Hour)
+		require.NoError(t, repo.DeleteUser(ctx, oldID, cutoff.Add(-time.Minute)))
+		require.NoError(t, repo.DeleteUser(ctx, recentID, time.Now()))
+
+		expired, err := repo.GetUsersDeletedBefore(ctx, cutoff)
+		require.NoError(t, err)
+		require.Len(t, expired, 1)
+		assert.Equal(t, oldID, expired[0].ID)
+
+		assert.ErrorIs(t, repo.PurgeUser(ctx, activeID), repository.ErrNotFound)
+		require.NoError(t, repo.PurgeUser(ctx, oldID))
+		assert.ErrorIs(t, repo.PurgeUser(ctx, oldID), repository.ErrNotFound)
+
+		_, err = repo.GetDeletedUserByEmail(ctx, "johndoe@example.com")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// Depois da remoção definitiva o email pode ser usado de novo
+		createUser(t, repo, "johndoe", "johndoe@example.com")
+	})
+
+	t.Run("Get all", func(t *testing.T) {
+		repo := newRepository(t)
+
+		users, err := pageItems(repo.GetAllUsers(ctx, everything))
+		require.NoError(t, err)
+		assert.Empty(t, users)
+
+		first := createUser(t, repo, "johndoe", "johndoe@example.com")
+		second := createUser(t, repo, "janedoe", "janedoe@example.com")
+
+		users, err = pageItems(repo.GetAllUsers(ctx, everything))
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		assert.Equal(t, first, users[0].ID)
+		assert.Equal(t, second, users[1].ID)
+	})
+
+	t.Run("Get by ids", func(t *testing.T) {
+		repo := newRepository(t)
+
+		users, err := repo.GetUsersByIds(ctx, nil)
+		require.NoError(t, err)
+		assert.Empty(t, users)
+
+		first := createUser(t, repo, "johndoe", "johndoe@example.com")
+		second := createUser(t, repo, "janedoe", "janedoe@example.com")
+		removed := createUser(t, repo, "jimdoe", "jimdoe@example.com")
+		createUser(t, repo, "joedoe", "joedoe@example.com")
+		require.NoError(t, repo.DeleteUser(ctx, removed, time.Now()))
+
+		// Removidos e inexistentes são ignorados
+		users, err = repo.GetUsersByIds(ctx, []primitive.ObjectID{second, removed, primitive.NewObjectID(), first})
+		require.NoError(t, err)
+
+		ids := make([]primitive.ObjectID, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		assert.ElementsMatch(t, []primitive.ObjectID{first, second}, ids)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		repo := newRepository(t)
+
+		var ids []primitive.ObjectID
+		for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			id := createUser(t, repo, name, name+"@example.com")
+			if name == "d" {
+				require.NoError(t, repo.DeleteUser(ctx, id, time.Now()))
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		walkPages(t, 3, func(req models.PageRequest) (*models.Page[*models.User], error) {
+			return repo.GetAllUsers(ctx, req)
+		}, func(user *models.User) primitive.ObjectID { return user.ID }, ids)
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		repo := newRepository(t)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.GetAllUsers(canceled, everything)
+		assert.ErrorIs(t, err, repository.ErrUnavailable)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,62 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cria um repositório vazio, isolado dos criados em outros testes.
+type ViewerPresenceRepositoryFactory func(t *testing.T) models.ViewerPresenceRepositoryInterface
+
+// Executa os testes de conformidade de `ViewerPresenceRepositoryInterface`.
+func RunViewerPresenceRepositoryTests(t *testing.T, newRepository ViewerPresenceRepositoryFactory) {
+	ctx := context.Background()
+
+	t.Run("Heartbeats", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID := primitive.NewObjectID()
+
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "first", time.Minute))
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "second", time.Minute))
+		// Um novo heartbeat da mesma sessão não conta outro espectador
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "first", time.Minute))
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, primitive.NewObjectID(), "first", time.Minute))
+
+		count, err := repo.CountActiveViewers(ctx, liveStreamID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		require.NoError(t, repo.RemoveViewer(ctx, liveStreamID, "first"))
+		// Remover quem já saiu não é um erro
+		require.NoError(t, repo.RemoveViewer(ctx, liveStreamID, "first"))
+
+		count, err = repo.CountActiveViewers(ctx, liveStreamID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		repo := newRepository(t)
+		liveStreamID := primitive.NewObjectID()
+
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "first", -time.Second))
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "second", time.Minute))
+
+		count, err := repo.CountActiveViewers(ctx, liveStreamID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		// O heartbeat renova a presença expirada
+		require.NoError(t, repo.RecordViewerHeartbeat(ctx, liveStreamID, "first", time.Minute))
+
+		count, err = repo.CountActiveViewers(ctx, liveStreamID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
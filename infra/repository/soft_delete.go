@@ -0,0 +1,19 @@
+package repository
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Documentos removidos têm `deleted_at` preenchido até serem apagados
+// definitivamente. Documentos anteriores à remoção lógica não têm o
+// campo, e o filtro por `nil` também os seleciona.
+
+// Restringe `filter` aos documentos não removidos.
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// Restringe `filter` aos documentos removidos.
+func deleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$ne": nil}
+	return filter
+}
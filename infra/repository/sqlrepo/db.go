@@ -0,0 +1,260 @@
+// Implementação SQL (PostgreSQL ou SQLite) de todos os repositórios, com
+// a mesma semântica da implementação Mongo.
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Backends SQL suportados.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+//go:embed schema/*.sql
+var schemas embed.FS
+
+// Conexão com o banco SQL, junto das particularidades do backend.
+type DB struct {
+	*sql.DB
+	backend string
+
+	// Prazo máximo de cada operação feita pelos repositórios.
+	// Um valor zero desativa o prazo, deixando apenas o do contexto
+	OperationTimeout time.Duration
+}
+
+// Abre uma conexão com o backend informado (`postgres` ou `sqlite`).
+// Para o PostgreSQL, `dsn` é a URL de conexão; para o SQLite, o caminho
+// do arquivo do banco.
+func Open(backend, dsn string, operationTimeout time.Duration) (*DB, error) {
+	var driverName string
+	switch backend {
+	case Postgres:
+		driverName = "pgx"
+	case SQLite:
+		driverName = "sqlite"
+		// Sem o busy_timeout, escritas concorrentes falham em vez de esperar
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn = dsn + separator + "_pragma=busy_timeout(5000)"
+	default:
+		return nil, fmt.Errorf("unknown sql backend %q", backend)
+	}
+
+	conn, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// O SQLite aceita um único escritor por vez. Com uma única conexão,
+	// bancos em memória (`:memory:`) também não se perdem entre consultas
+	if backend == SQLite {
+		conn.SetMaxOpenConns(1)
+	}
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{DB: conn, backend: backend, OperationTimeout: operationTimeout}, nil
+}
+
+// Cria as tabelas e índices do backend, caso ainda não existam.
+func (db *DB) ApplySchema(ctx context.Context) error {
+	schema, err := schemas.ReadFile("schema/" + db.backend + ".sql")
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range strings.Split(string(schema), ";") {
+		if strings.TrimSpace(stripComments(statement)) == "" {
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, statement); err != nil && !isDuplicateColumn(err) {
+			return wrapError(err)
+		}
+	}
+
+	return nil
+}
+
+// Indica se `err` é o erro do SQLite ao adicionar uma coluna que já
+// existe, o que acontece em toda inicialização depois da primeira.
+func isDuplicateColumn(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && strings.Contains(sqliteErr.Error(), "duplicate column name")
+}
+
+// Executa `query` e retorna o número de linhas afetadas.
+func (db *DB) execAffected(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := db.conn(ctx).ExecContext(ctx, db.rebind(query), args...)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return affected, nil
+}
+
+// Executa `query` e lê cada linha retornada com `scan`.
+func queryRows[T any](ctx context.Context, db *DB, query string, scan func(row scanner) (T, error), args ...any) ([]T, error) {
+	rows, err := db.conn(ctx).QueryContext(ctx, db.rebind(query), args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	items := make([]T, 0)
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return items, nil
+}
+
+func stripComments(statement string) string {
+	lines := strings.Split(statement, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Deriva de `ctx` um contexto com o prazo de operação configurado.
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, db.OperationTimeout)
+}
+
+// As consultas são escritas com `?`, o formato do SQLite. No PostgreSQL
+// os parâmetros são numerados ($1, $2, ...).
+func (db *DB) rebind(query string) string {
+	if db.backend != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Sufixo que trava as linhas lidas dentro de uma transação. O SQLite
+// não precisa dele, já que só há um escritor por vez.
+func (db *DB) forUpdate() string {
+	if db.backend == Postgres {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// Operações comuns a `*sql.DB` e `*sql.Tx`.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// Retorna a transação da unidade de trabalho guardada em `ctx`, ou a
+// própria conexão quando não há uma.
+func (db *DB) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// Executa `fn` em uma transação, desfeita caso `fn` retorne um erro.
+// Dentro de uma unidade de trabalho, a transação dela é reaproveitada.
+func (db *DB) inTx(ctx context.Context, fn func(tx querier) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return wrapError(tx.Commit())
+}
+
+// Classifica um erro do driver em um dos erros do pacote `repository`.
+// Erros não reconhecidos são retornados sem alteração.
+func wrapError(err error) error {
+	var pgErr *pgconn.PgError
+	var sqliteErr *sqlite.Error
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", repository.ErrNotFound, err)
+	case errors.As(err, &pgErr) && pgErr.Code == "23505",
+		errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY):
+		return fmt.Errorf("%w: %w", repository.ErrConflict, err)
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, driver.ErrBadConn),
+		errors.As(err, &netErr),
+		errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_BUSY:
+		return fmt.Errorf("%w: %w", repository.ErrUnavailable, err)
+	}
+
+	return err
+}
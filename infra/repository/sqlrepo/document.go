@@ -0,0 +1,157 @@
+package sqlrepo
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// As atualizações são feitas sobre a entidade lida da tabela, e não
+// com SQL gerado a partir de `bson.M`. Assim os nomes dos campos aceitos
+// em `UpdateUser` e `UpdateLiveStream` são os mesmos do Mongo, e a
+// entidade passa por uma ida e volta em BSON para ter a mesma precisão
+// de horários (milissegundos, em UTC) das outras implementações.
+
+// Linha de uma consulta, seja de `*sql.Row` ou de `*sql.Rows`.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Codifica e decodifica `v` em BSON, truncando os horários.
+func normalize[T any](v *T) (*T, error) {
+	doc, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized T
+	if err := bson.Unmarshal(doc, &normalized); err != nil {
+		return nil, err
+	}
+	return &normalized, nil
+}
+
+// Equivalente a um `$set`: sobrescreve os campos de `v` presentes em
+// `fields`, que usam os nomes dos campos no BSON.
+func setFields[T any](fields bson.M) func(v *T) error {
+	return func(v *T) error {
+		doc, err := bson.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		var m bson.M
+		if err := bson.Unmarshal(doc, &m); err != nil {
+			return err
+		}
+
+		for key, value := range fields {
+			m[key] = value
+		}
+
+		withFields, err := bson.Marshal(m)
+		if err != nil {
+			return err
+		}
+
+		var updated T
+		if err := bson.Unmarshal(withFields, &updated); err != nil {
+			return err
+		}
+
+		*v = updated
+		return nil
+	}
+}
+
+// Aplica `change` sobre `v` e retorna a entidade alterada, ou
+// `ErrNoChange` caso nada tenha mudado.
+func apply[T any](v *T, change func(v *T) error) (*T, error) {
+	before, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := change(v); err != nil {
+		return nil, err
+	}
+
+	updated, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := bson.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(before, after) {
+		return nil, repository.ErrNoChange
+	}
+
+	return updated, nil
+}
+
+// Listas são guardadas como JSON. Uma lista nula é guardada vazia,
+// como nos documentos criados pelos construtores de `models`.
+func marshalList[T any](list []T) (string, error) {
+	if list == nil {
+		list = make([]T, 0)
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalList[T any](data string) ([]T, error) {
+	list := make([]T, 0)
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("invalid list column: %w", err)
+	}
+	return list, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+// Horários opcionais são guardados como NULL quando ausentes.
+func toNullMillis(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: toMillis(*t), Valid: true}
+}
+
+func fromNullMillis(ms sql.NullInt64) *time.Time {
+	if !ms.Valid {
+		return nil
+	}
+	t := fromMillis(ms.Int64)
+	return &t
+}
+
+// Monta a lista de parâmetros de um `IN (...)` com os ids, guardados
+// como texto. A lista não pode ser vazia.
+func idList(ids []primitive.ObjectID) (string, []any) {
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id.Hex())
+	}
+	return "(" + strings.Repeat("?, ", len(ids)-1) + "?)", args
+}
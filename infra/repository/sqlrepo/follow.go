@@ -0,0 +1,225 @@
+package sqlrepo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const followColumns = "id, follower_id, followee_id, created_at"
+
+// Implementação SQL de `FollowRepositoryInterface`. Cada par
+// seguidor/seguido é único, como garantido pelos índices no Mongo.
+type FollowRepository struct {
+	Db *DB
+}
+
+func NewFollowRepository(db *DB) *FollowRepository {
+	return &FollowRepository{Db: db}
+}
+
+func scanFollow(row scanner) (*models.Follow, error) {
+	var follow models.Follow
+	var id, followerID, followeeID string
+	var createdAt int64
+
+	err := row.Scan(&id, &followerID, &followeeID, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if follow.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if follow.FollowerID, err = primitive.ObjectIDFromHex(followerID); err != nil {
+		return nil, err
+	}
+	if follow.FolloweeID, err = primitive.ObjectIDFromHex(followeeID); err != nil {
+		return nil, err
+	}
+	follow.CreatedAt = fromMillis(createdAt)
+
+	return &follow, nil
+}
+
+// Um follow repetido é ignorado pelo `ON CONFLICT`, e não tratado como
+// erro de chave duplicada, o que abortaria a transação em andamento no
+// PostgreSQL.
+func (fr *FollowRepository) Follow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	if followerID == followeeID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfFollow, followerID.Hex())
+	}
+
+	ctx, cancel := fr.Db.withTimeout(ctx)
+	defer cancel()
+
+	follow := models.NewFollow(followerID, followeeID)
+	query := "INSERT INTO follows (" + followColumns + ") VALUES (?, ?, ?, ?) ON CONFLICT (follower_id, followee_id) DO NOTHING"
+	inserted, err := fr.Db.execAffected(ctx, query, primitive.NewObjectID().Hex(), followerID.Hex(), followeeID.Hex(), toMillis(follow.CreatedAt))
+	if err != nil {
+		return err
+	}
+
+	if inserted == 0 {
+		return fmt.Errorf("%w: %s already follows %s", repository.ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error {
+	ctx, cancel := fr.Db.withTimeout(ctx)
+	defer cancel()
+
+	deleted, err := fr.Db.execAffected(ctx, "DELETE FROM follows WHERE follower_id = ? AND followee_id = ?", followerID.Hex(), followeeID.Hex())
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s does not follow %s", repository.ErrNoChange, followerID.Hex(), followeeID.Hex())
+	}
+
+	return nil
+}
+
+func (fr *FollowRepository) DeleteUserFollows(ctx context.Context, userID primitive.ObjectID) ([]*models.Follow, error) {
+	var follows []*models.Follow
+
+	err := NewUnitOfWork(fr.Db).Do(ctx, func(ctx context.Context) error {
+		var err error
+		follows, err = fr.find(ctx, "follower_id = ? OR followee_id = ?", "id", 0, userID.Hex(), userID.Hex())
+		if err != nil || len(follows) == 0 {
+			return err
+		}
+
+		// Apenas os follows lidos são apagados, para que o retorno
+		// corresponda exatamente ao que foi removido
+		ids := make([]primitive.ObjectID, 0, len(follows))
+		for _, follow := range follows {
+			ids = append(ids, follow.ID)
+		}
+
+		ctx, cancel := fr.Db.withTimeout(ctx)
+		defer cancel()
+
+		list, args := idList(ids)
+		_, err = fr.Db.execAffected(ctx, "DELETE FROM follows WHERE id IN "+list, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return follows, nil
+}
+
+func (fr *FollowRepository) GetFollowingIDs(ctx context.Context, followerID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	follows, err := fr.find(ctx, "follower_id = ?", "id", 0, followerID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.FolloweeID)
+	}
+
+	return ids, nil
+}
+
+func (fr *FollowRepository) GetFollowers(ctx context.Context, followeeID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	return fr.findPage(ctx, "followee_id = ?", page, followeeID.Hex())
+}
+
+func (fr *FollowRepository) GetFollowing(ctx context.Context, followerID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.Follow], error) {
+	return fr.findPage(ctx, "follower_id = ?", page, followerID.Hex())
+}
+
+func (fr *FollowRepository) CountFollows(ctx context.Context) (map[primitive.ObjectID]models.FollowCounts, error) {
+	counts := make(map[primitive.ObjectID]models.FollowCounts)
+
+	err := fr.countBy(ctx, "followee_id", func(id primitive.ObjectID, count int) {
+		user := counts[id]
+		user.Followers = count
+		counts[id] = user
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = fr.countBy(ctx, "follower_id", func(id primitive.ObjectID, count int) {
+		user := counts[id]
+		user.Following = count
+		counts[id] = user
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Conta os follows agrupados por `column`, entregando cada contagem a `add`.
+func (fr *FollowRepository) countBy(ctx context.Context, column string, add func(id primitive.ObjectID, count int)) error {
+	ctx, cancel := fr.Db.withTimeout(ctx)
+	defer cancel()
+
+	type userCount struct {
+		id    primitive.ObjectID
+		count int
+	}
+
+	query := "SELECT " + column + ", COUNT(*) FROM follows GROUP BY " + column
+	counts, err := queryRows(ctx, fr.Db, query, func(row scanner) (userCount, error) {
+		var c userCount
+		var id string
+		if err := row.Scan(&id, &c.count); err != nil {
+			return c, err
+		}
+
+		var err error
+		c.id, err = primitive.ObjectIDFromHex(id)
+		return c, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range counts {
+		add(c.id, c.count)
+	}
+
+	return nil
+}
+
+// Retorna a página dos follows que satisfazem `where`, em ordem de id.
+func (fr *FollowRepository) findPage(ctx context.Context, where string, page models.PageRequest, args ...any) (*models.Page[*models.Follow], error) {
+	where, orderBy, args, err := pageQuery(where, "", page.Cursor, args)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := fr.find(ctx, where, orderBy, page.Limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(follows, page, (*models.Follow).Position), nil
+}
+
+func (fr *FollowRepository) find(ctx context.Context, where, orderBy string, limit int, args ...any) ([]*models.Follow, error) {
+	ctx, cancel := fr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + followColumns + " FROM follows WHERE " + where + " ORDER BY " + orderBy
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	return queryRows(ctx, fr.Db, query, scanFollow, args...)
+}
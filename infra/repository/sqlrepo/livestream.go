@@ -0,0 +1,331 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const liveStreamColumns = "id, name, thumbnail, stream_key_hash, viewer_count, publisher_id, allowed_ips, " +
+	"stream_key_rotations, publisher_client_id, live_stream_status, last_session_ended_at, created_at, updated_at, deleted_at"
+
+// Implementação SQL de `LiveStreamRepositoryInterface`. O hash da chave
+// de stream é único, como garantido pelos índices no Mongo.
+type LiveStreamRepository struct {
+	Db *DB
+}
+
+func NewLiveStreamRepository(db *DB) *LiveStreamRepository {
+	return &LiveStreamRepository{Db: db}
+}
+
+func scanLiveStream(row scanner) (*models.LiveStream, error) {
+	var ls models.LiveStream
+	var id, publisherID, allowedIPs, rotations string
+	var lastSessionEndedAt, deletedAt sql.NullInt64
+	var createdAt, updatedAt int64
+
+	err := row.Scan(&id, &ls.Name, &ls.Thumbnail, &ls.StreamKeyHash, &ls.ViewerCount, &publisherID, &allowedIPs,
+		&rotations, &ls.PublisherClientID, &ls.LiveStatus, &lastSessionEndedAt, &createdAt, &updatedAt, &deletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if ls.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if ls.PublisherId, err = primitive.ObjectIDFromHex(publisherID); err != nil {
+		return nil, err
+	}
+	if ls.AllowedIPs, err = unmarshalList[string](allowedIPs); err != nil {
+		return nil, err
+	}
+	if ls.StreamKeyRotations, err = unmarshalList[*models.StreamKeyRotation](rotations); err != nil {
+		return nil, err
+	}
+	ls.LastSessionEndedAt = fromNullMillis(lastSessionEndedAt)
+	ls.CreatedAt = fromMillis(createdAt)
+	ls.UpdatedAt = fromMillis(updatedAt)
+	ls.DeletedAt = fromNullMillis(deletedAt)
+
+	return &ls, nil
+}
+
+// Valores das colunas de `liveStreamColumns`, na mesma ordem.
+func liveStreamArgs(ls *models.LiveStream) ([]any, error) {
+	allowedIPs, err := marshalList(ls.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	rotations, err := marshalList(ls.StreamKeyRotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		ls.ID.Hex(), ls.Name, ls.Thumbnail, ls.StreamKeyHash, ls.ViewerCount, ls.PublisherId.Hex(), allowedIPs,
+		rotations, ls.PublisherClientID, ls.LiveStatus, toNullMillis(ls.LastSessionEndedAt), toMillis(ls.CreatedAt),
+		toMillis(ls.UpdatedAt), toNullMillis(ls.DeletedAt),
+	}, nil
+}
+
+func (lr *LiveStreamRepository) CreateLiveStream(ctx context.Context, name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error) {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	ls, err := normalize(models.NewLiveStream(name, thumbnail, publisherId, streamKey))
+	if err != nil {
+		return nil, err
+	}
+	ls.ID = primitive.NewObjectID()
+
+	args, err := liveStreamArgs(ls)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO livestreams (" + liveStreamColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err := lr.Db.conn(ctx).ExecContext(ctx, lr.Db.rebind(query), args...); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return ls.ID, nil
+}
+
+func (lr *LiveStreamRepository) DeleteLiveStream(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return lr.updateLiveStream(ctx, id, func(ls *models.LiveStream) error {
+		ls.DeletedAt = &deletedAt
+		return nil
+	})
+}
+
+func (lr *LiveStreamRepository) DeleteLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE livestreams SET deleted_at = ? WHERE " + notDeleted("publisher_id = ?")
+	return lr.Db.execAffected(ctx, query, toMillis(deletedAt), id.Hex())
+}
+
+func (lr *LiveStreamRepository) RestoreLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error) {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE livestreams SET deleted_at = NULL, updated_at = ? WHERE publisher_id = ? AND deleted_at = ?"
+	return lr.Db.execAffected(ctx, query, toMillis(time.Now()), id.Hex(), toMillis(deletedAt))
+}
+
+func (lr *LiveStreamRepository) PurgeLiveStream(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	purged, err := lr.Db.execAffected(ctx, "DELETE FROM livestreams WHERE "+deleted("id = ?"), id.Hex())
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return fmt.Errorf("%w: no deleted live stream with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	return nil
+}
+
+func (lr *LiveStreamRepository) PurgeLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID) (int64, error) {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	return lr.Db.execAffected(ctx, "DELETE FROM livestreams WHERE publisher_id = ?", id.Hex())
+}
+
+// Lê a live não removida, aplica `change` e grava o resultado na mesma transação.
+func (lr *LiveStreamRepository) updateLiveStream(ctx context.Context, id primitive.ObjectID, change func(ls *models.LiveStream) error) error {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	return lr.Db.inTx(ctx, func(tx querier) error {
+		query := "SELECT " + liveStreamColumns + " FROM livestreams WHERE " + notDeleted("id = ?") + lr.Db.forUpdate()
+		ls, err := scanLiveStream(tx.QueryRowContext(ctx, lr.Db.rebind(query), id.Hex()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+		}
+		if err != nil {
+			return wrapError(err)
+		}
+
+		updated, err := apply(ls, change)
+		if err != nil {
+			return fmt.Errorf("%w: _id %s", err, id.Hex())
+		}
+		updated.ID = id
+
+		args, err := liveStreamArgs(updated)
+		if err != nil {
+			return err
+		}
+
+		query = "UPDATE livestreams SET name = ?, thumbnail = ?, stream_key_hash = ?, viewer_count = ?, publisher_id = ?, " +
+			"allowed_ips = ?, stream_key_rotations = ?, publisher_client_id = ?, live_stream_status = ?, " +
+			"last_session_ended_at = ?, created_at = ?, updated_at = ?, deleted_at = ? WHERE id = ?"
+		_, err = tx.ExecContext(ctx, lr.Db.rebind(query), append(args[1:], args[0])...)
+		return wrapError(err)
+	})
+}
+
+func (lr *LiveStreamRepository) UpdateLiveStream(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
+	newData["updated_at"] = time.Now()
+	return lr.updateLiveStream(ctx, id, setFields[models.LiveStream](newData))
+}
+
+func (lr *LiveStreamRepository) RotateLiveStreamKey(ctx context.Context, id primitive.ObjectID, newStreamKey string, rotation *models.StreamKeyRotation) error {
+	return lr.updateLiveStream(ctx, id, func(ls *models.LiveStream) error {
+		ls.StreamKeyHash = models.HashStreamKey(newStreamKey)
+		ls.StreamKeyRotations = append(ls.StreamKeyRotations, rotation)
+		ls.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (lr *LiveStreamRepository) IncrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
+	return lr.updateLiveStream(ctx, id, func(ls *models.LiveStream) error {
+		ls.ViewerCount++
+		ls.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (lr *LiveStreamRepository) DecrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error {
+	return lr.updateLiveStream(ctx, id, func(ls *models.LiveStream) error {
+		ls.ViewerCount = max(0, ls.ViewerCount-1)
+		ls.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (lr *LiveStreamRepository) SetLiveStreamViewerCount(ctx context.Context, id primitive.ObjectID, viewers int) error {
+	return lr.updateLiveStream(ctx, id, func(ls *models.LiveStream) error {
+		ls.ViewerCount = max(viewers, 0)
+		ls.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// Retorna, em ordem de id (a ordem de criação), até `limit` lives que
+// satisfazem `where`. Um limite zero retorna todas. Lives removidas só
+// são excluídas se `where` filtrar por elas.
+func (lr *LiveStreamRepository) find(ctx context.Context, where string, limit int, args ...any) ([]*models.LiveStream, error) {
+	return lr.findOrdered(ctx, where, "id", limit, args...)
+}
+
+// Retorna uma página das lives que satisfazem `where`, ordenadas como
+// descrito em `pageQuery`.
+func (lr *LiveStreamRepository) findPage(ctx context.Context, where, keyColumn string, req models.PageRequest, position func(ls *models.LiveStream) models.Cursor, args ...any) (*models.Page[*models.LiveStream], error) {
+	where, orderBy, args, err := pageQuery(where, keyColumn, req.Cursor, args)
+	if err != nil {
+		return nil, err
+	}
+
+	liveStreams, err := lr.findOrdered(ctx, where, orderBy, req.Limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(liveStreams, req, position), nil
+}
+
+func (lr *LiveStreamRepository) findOrdered(ctx context.Context, where, orderBy string, limit int, args ...any) ([]*models.LiveStream, error) {
+	ctx, cancel := lr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + liveStreamColumns + " FROM livestreams"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY " + orderBy
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := lr.Db.conn(ctx).QueryContext(ctx, lr.Db.rebind(query), args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	liveStreams := make([]*models.LiveStream, 0)
+	for rows.Next() {
+		ls, err := scanLiveStream(rows)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		liveStreams = append(liveStreams, ls)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return liveStreams, nil
+}
+
+func (lr *LiveStreamRepository) getLiveStreamByParam(ctx context.Context, where string, arg any) (*models.LiveStream, error) {
+	liveStreams, err := lr.find(ctx, where, 1, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(liveStreams) == 0 {
+		return nil, fmt.Errorf("%w: no matching live stream", repository.ErrNotFound)
+	}
+
+	return liveStreams[0], nil
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamById(ctx context.Context, id primitive.ObjectID) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, notDeleted("id = ?"), id.Hex())
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamByName(ctx context.Context, name string) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, notDeleted("name = ?"), name)
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamByStreamKey(ctx context.Context, key string) (*models.LiveStream, error) {
+	return lr.getLiveStreamByParam(ctx, notDeleted("stream_key_hash = ?"), models.HashStreamKey(key))
+}
+
+func (lr *LiveStreamRepository) GetAllLiveStreamsByUserId(ctx context.Context, id primitive.ObjectID, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	return lr.findPage(ctx, notDeleted("publisher_id = ?"), "", page, (*models.LiveStream).Position, id.Hex())
+}
+
+func (lr *LiveStreamRepository) GetActiveLiveStreams(ctx context.Context) ([]*models.LiveStream, error) {
+	return lr.find(ctx, notDeleted("live_stream_status = ?"), 0, true)
+}
+
+func (lr *LiveStreamRepository) GetMostWatchedLiveStreams(ctx context.Context, limit int) ([]*models.LiveStream, error) {
+	return lr.findOrdered(ctx, notDeleted("live_stream_status = ?"), "viewer_count DESC, id", limit, true)
+}
+
+func (lr *LiveStreamRepository) GetActiveLiveStreamsByPublishers(ctx context.Context, publisherIDs []primitive.ObjectID) ([]*models.LiveStream, error) {
+	if len(publisherIDs) == 0 {
+		return []*models.LiveStream{}, nil
+	}
+
+	list, args := idList(publisherIDs)
+	return lr.find(ctx, notDeleted("live_stream_status = ? AND publisher_id IN "+list), 0, append([]any{true}, args...)...)
+}
+
+func (lr *LiveStreamRepository) GetAllLiveStreams(ctx context.Context, page models.PageRequest) (*models.Page[*models.LiveStream], error) {
+	return lr.findPage(ctx, notDeleted(""), "", page, (*models.LiveStream).Position)
+}
+
+func (lr *LiveStreamRepository) GetLiveStreamsDeletedBefore(ctx context.Context, before time.Time) ([]*models.LiveStream, error) {
+	return lr.find(ctx, "deleted_at < ?", 0, toMillis(before))
+}
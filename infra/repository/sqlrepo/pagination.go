@@ -0,0 +1,48 @@
+package sqlrepo
+
+import (
+	"fmt"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+)
+
+// Restringe a condição `where` às linhas depois do cursor, no sentido que
+// ele indica, e retorna a ordenação da busca nesse sentido. A listagem é
+// ordenada de forma decrescente por `keyColumn` e crescente pelo id; sem
+// `keyColumn`, apenas pelo id. Ids são ObjectIDs em hexadecimal, que têm
+// a mesma ordem dos ObjectIDs no Mongo.
+func pageQuery(where, keyColumn string, cursor *models.Cursor, args []any) (string, string, []any, error) {
+	backward := cursor != nil && cursor.Backward
+
+	keyOrder, idOrder, idAfter, keyAfter := "DESC", "ASC", ">", "<"
+	if backward {
+		keyOrder, idOrder, idAfter, keyAfter = "ASC", "DESC", "<", ">"
+	}
+
+	orderBy := "id " + idOrder
+	if keyColumn != "" {
+		orderBy = keyColumn + " " + keyOrder + ", " + orderBy
+	}
+
+	if cursor == nil {
+		return where, orderBy, args, nil
+	}
+
+	after := "id " + idAfter + " ?"
+	afterArgs := []any{cursor.ID.Hex()}
+	if keyColumn != "" {
+		if cursor.Key == nil {
+			return "", "", nil, fmt.Errorf("%w: missing %s", repository.ErrInvalidCursor, keyColumn)
+		}
+
+		after = fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[3]s ?))", keyColumn, keyAfter, idAfter)
+		afterArgs = []any{*cursor.Key, *cursor.Key, cursor.ID.Hex()}
+	}
+
+	if where != "" {
+		where += " AND "
+	}
+
+	return where + after, orderBy, append(args, afterArgs...), nil
+}
@@ -0,0 +1,127 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, device, expires_at, revoked_at, created_at"
+
+// Implementação SQL de `RefreshTokenRepositoryInterface`. O hash do token
+// é único, como garantido pelos índices no Mongo.
+type RefreshTokenRepository struct {
+	Db *DB
+}
+
+func NewRefreshTokenRepository(db *DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{Db: db}
+}
+
+func scanRefreshToken(row scanner) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var id, userID, familyID string
+	var expiresAt, createdAt int64
+	var revokedAt sql.NullInt64
+
+	err := row.Scan(&id, &userID, &familyID, &token.TokenHash, &token.Device, &expiresAt, &revokedAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if token.UserID, err = primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, err
+	}
+	if token.FamilyID, err = primitive.ObjectIDFromHex(familyID); err != nil {
+		return nil, err
+	}
+	token.ExpiresAt = fromMillis(expiresAt)
+	token.RevokedAt = fromNullMillis(revokedAt)
+	token.CreatedAt = fromMillis(createdAt)
+
+	return &token, nil
+}
+
+func (rr *RefreshTokenRepository) CreateRefreshToken(ctx context.Context, userID, familyID primitive.ObjectID, tokenHash, device string, expiresAt time.Time) (interface{}, error) {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	token := models.NewRefreshToken(userID, familyID, tokenHash, device, expiresAt)
+	token.ID = primitive.NewObjectID()
+
+	query := "INSERT INTO refresh_tokens (" + refreshTokenColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := rr.Db.conn(ctx).ExecContext(ctx, rr.Db.rebind(query), token.ID.Hex(), userID.Hex(), familyID.Hex(),
+		tokenHash, device, toMillis(expiresAt), toNullMillis(token.RevokedAt), toMillis(token.CreatedAt))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return token.ID, nil
+}
+
+// Revoga um único token. Só tokens ainda não revogados são afetados, de
+// forma que duas rotações concorrentes do mesmo token não podem ambas
+// ter sucesso.
+func (rr *RefreshTokenRepository) RevokeRefreshToken(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	revoked, err := rr.Db.execAffected(ctx, query, toMillis(time.Now()), id.Hex())
+	if err != nil {
+		return err
+	}
+
+	if revoked != 1 {
+		return fmt.Errorf("%w: token %s is already revoked", repository.ErrNoChange, id.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RefreshTokenRepository) revokeRefreshTokens(ctx context.Context, column string, id primitive.ObjectID) error {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE refresh_tokens SET revoked_at = ? WHERE " + column + " = ? AND revoked_at IS NULL"
+	_, err := rr.Db.execAffected(ctx, query, toMillis(time.Now()), id.Hex())
+	return err
+}
+
+func (rr *RefreshTokenRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error {
+	return rr.revokeRefreshTokens(ctx, "family_id", familyID)
+}
+
+func (rr *RefreshTokenRepository) RevokeAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error {
+	return rr.revokeRefreshTokens(ctx, "user_id", userID)
+}
+
+// Apaga todos os tokens do usuário, revogados ou não. Usado apenas na
+// remoção do usuário, já que os tokens revogados servem de histórico.
+func (rr *RefreshTokenRepository) DeleteAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	return rr.Db.execAffected(ctx, "DELETE FROM refresh_tokens WHERE user_id = ?", userID.Hex())
+}
+
+func (rr *RefreshTokenRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + refreshTokenColumns + " FROM refresh_tokens WHERE token_hash = ?"
+	token, err := scanRefreshToken(rr.Db.conn(ctx).QueryRowContext(ctx, rr.Db.rebind(query), tokenHash))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,161 @@
+package sqlrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const relationColumns = "id, user_id, target_id, kind, created_at"
+
+// Implementação SQL de `RelationRepositoryInterface`. Cada usuário tem no
+// máximo uma relação de cada tipo com outro, como garantido pelos índices
+// no Mongo.
+type RelationRepository struct {
+	Db *DB
+}
+
+func NewRelationRepository(db *DB) *RelationRepository {
+	return &RelationRepository{Db: db}
+}
+
+func scanRelation(row scanner) (*models.Relation, error) {
+	var relation models.Relation
+	var id, userID, targetID string
+	var createdAt int64
+
+	err := row.Scan(&id, &userID, &targetID, &relation.Kind, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if relation.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if relation.UserID, err = primitive.ObjectIDFromHex(userID); err != nil {
+		return nil, err
+	}
+	if relation.TargetID, err = primitive.ObjectIDFromHex(targetID); err != nil {
+		return nil, err
+	}
+	relation.CreatedAt = fromMillis(createdAt)
+
+	return &relation, nil
+}
+
+// Assim como o follow, a relação repetida é ignorada pelo `ON CONFLICT`
+// para não abortar a transação em andamento.
+func (rr *RelationRepository) AddRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	if userID == targetID {
+		return fmt.Errorf("%w: _id %s", models.ErrSelfRelation, userID.Hex())
+	}
+
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	relation := models.NewRelation(userID, targetID, kind)
+	query := "INSERT INTO relations (" + relationColumns + ") VALUES (?, ?, ?, ?, ?) ON CONFLICT (user_id, target_id, kind) DO NOTHING"
+	inserted, err := rr.Db.execAffected(ctx, query, primitive.NewObjectID().Hex(), userID.Hex(), targetID.Hex(), string(kind), toMillis(relation.CreatedAt))
+	if err != nil {
+		return err
+	}
+
+	if inserted == 0 {
+		return fmt.Errorf("%w: %s already has a %s relation with %s", repository.ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) RemoveRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) error {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "DELETE FROM relations WHERE user_id = ? AND target_id = ? AND kind = ?"
+	deleted, err := rr.Db.execAffected(ctx, query, userID.Hex(), targetID.Hex(), string(kind))
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("%w: %s has no %s relation with %s", repository.ErrNoChange, userID.Hex(), kind, targetID.Hex())
+	}
+
+	return nil
+}
+
+func (rr *RelationRepository) DeleteUserRelations(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	return rr.Db.execAffected(ctx, "DELETE FROM relations WHERE user_id = ? OR target_id = ?", userID.Hex(), userID.Hex())
+}
+
+func (rr *RelationRepository) HasRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind models.RelationKind) (bool, error) {
+	return rr.exists(ctx, "user_id = ? AND target_id = ? AND kind = ?", userID.Hex(), targetID.Hex(), string(kind))
+}
+
+func (rr *RelationRepository) IsBlockedBetween(ctx context.Context, userID, otherID primitive.ObjectID) (bool, error) {
+	return rr.exists(ctx, "kind = ? AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))",
+		string(models.RelationBlock), userID.Hex(), otherID.Hex(), otherID.Hex(), userID.Hex())
+}
+
+func (rr *RelationRepository) GetRelatedIDs(ctx context.Context, userID primitive.ObjectID, kinds ...models.RelationKind) ([]primitive.ObjectID, error) {
+	if len(kinds) == 0 {
+		return []primitive.ObjectID{}, nil
+	}
+
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	args := []any{userID.Hex()}
+	for _, kind := range kinds {
+		args = append(args, string(kind))
+	}
+
+	// Um usuário bloqueado e silenciado aparece uma única vez
+	query := "SELECT DISTINCT target_id FROM relations WHERE user_id = ? AND kind IN (" +
+		strings.Repeat("?, ", len(kinds)-1) + "?) ORDER BY target_id"
+	return queryRows(ctx, rr.Db, query, func(row scanner) (primitive.ObjectID, error) {
+		var id string
+		if err := row.Scan(&id); err != nil {
+			return primitive.NilObjectID, err
+		}
+		return primitive.ObjectIDFromHex(id)
+	}, args...)
+}
+
+func (rr *RelationRepository) GetRelations(ctx context.Context, userID primitive.ObjectID, kind models.RelationKind, page models.PageRequest) (*models.Page[*models.Relation], error) {
+	where, orderBy, args, err := pageQuery("user_id = ? AND kind = ?", "", page.Cursor, []any{userID.Hex(), string(kind)})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + relationColumns + " FROM relations WHERE " + where + " ORDER BY " + orderBy + " LIMIT ?"
+	relations, err := queryRows(ctx, rr.Db, query, scanRelation, append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(relations, page, (*models.Relation).Position), nil
+}
+
+func (rr *RelationRepository) exists(ctx context.Context, where string, args ...any) (bool, error) {
+	ctx, cancel := rr.Db.withTimeout(ctx)
+	defer cancel()
+
+	var found int
+	query := "SELECT COUNT(*) FROM (SELECT 1 FROM relations WHERE " + where + " LIMIT 1) AS found"
+	if err := rr.Db.conn(ctx).QueryRowContext(ctx, rr.Db.rebind(query), args...).Scan(&found); err != nil {
+		return false, wrapError(err)
+	}
+
+	return found > 0, nil
+}
@@ -0,0 +1,123 @@
+-- Esquema do backend PostgreSQL. Todos os comandos são idempotentes e
+-- executados na inicialização do servidor.
+--
+-- Ids são ObjectIDs em hexadecimal, como no Mongo, e horários são
+-- guardados em milissegundos desde a época Unix, a mesma precisão do
+-- BSON. Listas (allowed_ips, stream_key_rotations) e o encoder das
+-- sessões são guardados como JSON, espelhando os documentos.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         CHAR(24) PRIMARY KEY,
+    username   TEXT     NOT NULL UNIQUE,
+    email      TEXT     NOT NULL UNIQUE,
+    password   TEXT     NOT NULL,
+    created_at BIGINT   NOT NULL,
+    updated_at BIGINT   NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS livestreams (
+    id                    CHAR(24) PRIMARY KEY,
+    name                  TEXT     NOT NULL,
+    thumbnail             TEXT     NOT NULL,
+    stream_key_hash       TEXT     NOT NULL UNIQUE,
+    viewer_count          INTEGER  NOT NULL DEFAULT 0,
+    publisher_id          CHAR(24) NOT NULL,
+    allowed_ips           TEXT     NOT NULL DEFAULT '[]',
+    stream_key_rotations  TEXT     NOT NULL DEFAULT '[]',
+    publisher_client_id   TEXT     NOT NULL DEFAULT '',
+    live_stream_status    BOOLEAN  NOT NULL DEFAULT FALSE,
+    last_session_ended_at BIGINT,
+    created_at            BIGINT   NOT NULL,
+    updated_at            BIGINT   NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS livestreams_publisher_id_idx ON livestreams (publisher_id);
+CREATE INDEX IF NOT EXISTS livestreams_live_stream_status_idx ON livestreams (live_stream_status);
+
+-- Colunas adicionadas depois da criação das tabelas
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at BIGINT;
+ALTER TABLE livestreams ADD COLUMN IF NOT EXISTS deleted_at BIGINT;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at);
+CREATE INDEX IF NOT EXISTS livestreams_deleted_at_idx ON livestreams (deleted_at);
+
+-- Lives ativas de quem o usuário segue
+CREATE INDEX IF NOT EXISTS livestreams_publisher_status_idx ON livestreams (publisher_id, live_stream_status);
+
+-- Lives ativas mais assistidas, candidatas do feed
+CREATE INDEX IF NOT EXISTS livestreams_status_viewers_idx ON livestreams (live_stream_status, viewer_count DESC, id);
+
+-- Contadores de seguidores e seguidos, mantidos junto dos follows
+ALTER TABLE users ADD COLUMN IF NOT EXISTS follower_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS following_count INTEGER NOT NULL DEFAULT 0;
+
+-- Dados que também ficavam no Mongo, para que um backend SQL seja
+-- autossuficiente. Cada tabela tem os mesmos índices da coleção
+-- correspondente.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         CHAR(24) PRIMARY KEY,
+    user_id    CHAR(24) NOT NULL,
+    family_id  CHAR(24) NOT NULL,
+    token_hash TEXT     NOT NULL UNIQUE,
+    device     TEXT     NOT NULL,
+    expires_at BIGINT   NOT NULL,
+    revoked_at BIGINT,
+    created_at BIGINT   NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS stream_sessions (
+    id              CHAR(24)         PRIMARY KEY,
+    live_stream_id  CHAR(24)         NOT NULL,
+    publisher_id    CHAR(24)         NOT NULL,
+    client_ip       TEXT             NOT NULL,
+    client_id       TEXT             NOT NULL,
+    encoder         TEXT             NOT NULL DEFAULT '{}',
+    peak_viewers    INTEGER          NOT NULL DEFAULT 0,
+    average_viewers DOUBLE PRECISION NOT NULL DEFAULT 0,
+    viewer_sum      INTEGER          NOT NULL DEFAULT 0,
+    viewer_samples  INTEGER          NOT NULL DEFAULT 0,
+    started_at      BIGINT           NOT NULL,
+    ended_at        BIGINT
+);
+
+-- Sessão aberta da live e histórico de sessões, da mais recente para a mais antiga
+CREATE INDEX IF NOT EXISTS stream_sessions_open_idx ON stream_sessions (live_stream_id, ended_at);
+CREATE INDEX IF NOT EXISTS stream_sessions_started_at_idx ON stream_sessions (live_stream_id, started_at DESC, id);
+CREATE INDEX IF NOT EXISTS stream_sessions_publisher_id_idx ON stream_sessions (publisher_id);
+
+CREATE TABLE IF NOT EXISTS viewer_presence (
+    id             CHAR(24) PRIMARY KEY,
+    live_stream_id CHAR(24) NOT NULL,
+    session_id     TEXT     NOT NULL,
+    last_seen_at   BIGINT   NOT NULL,
+    expires_at     BIGINT   NOT NULL,
+    UNIQUE (live_stream_id, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS viewer_presence_expires_at_idx ON viewer_presence (expires_at);
+
+CREATE TABLE IF NOT EXISTS follows (
+    id          CHAR(24) PRIMARY KEY,
+    follower_id CHAR(24) NOT NULL,
+    followee_id CHAR(24) NOT NULL,
+    created_at  BIGINT   NOT NULL,
+    UNIQUE (follower_id, followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows (followee_id, id);
+
+CREATE TABLE IF NOT EXISTS relations (
+    id         CHAR(24) PRIMARY KEY,
+    user_id    CHAR(24) NOT NULL,
+    target_id  CHAR(24) NOT NULL,
+    kind       TEXT     NOT NULL,
+    created_at BIGINT   NOT NULL,
+    UNIQUE (user_id, target_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS relations_user_kind_idx ON relations (user_id, kind, id);
+CREATE INDEX IF NOT EXISTS relations_target_id_idx ON relations (target_id);
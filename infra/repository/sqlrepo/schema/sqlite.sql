@@ -0,0 +1,122 @@
+-- Esquema do backend SQLite, usado em desenvolvimento. Segue o mesmo
+-- formato do esquema PostgreSQL, com os tipos nativos do SQLite:
+-- booleanos são inteiros 0/1 e horários são milissegundos desde a
+-- época Unix.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         TEXT    PRIMARY KEY,
+    username   TEXT    NOT NULL UNIQUE,
+    email      TEXT    NOT NULL UNIQUE,
+    password   TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS livestreams (
+    id                    TEXT    PRIMARY KEY,
+    name                  TEXT    NOT NULL,
+    thumbnail             TEXT    NOT NULL,
+    stream_key_hash       TEXT    NOT NULL UNIQUE,
+    viewer_count          INTEGER NOT NULL DEFAULT 0,
+    publisher_id          TEXT    NOT NULL,
+    allowed_ips           TEXT    NOT NULL DEFAULT '[]',
+    stream_key_rotations  TEXT    NOT NULL DEFAULT '[]',
+    publisher_client_id   TEXT    NOT NULL DEFAULT '',
+    live_stream_status    INTEGER NOT NULL DEFAULT 0,
+    last_session_ended_at INTEGER,
+    created_at            INTEGER NOT NULL,
+    updated_at            INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS livestreams_publisher_id_idx ON livestreams (publisher_id);
+CREATE INDEX IF NOT EXISTS livestreams_live_stream_status_idx ON livestreams (live_stream_status);
+
+-- Colunas adicionadas depois da criação das tabelas. O SQLite não aceita
+-- `IF NOT EXISTS` aqui, então a coluna duplicada é ignorada em
+-- `ApplySchema`
+ALTER TABLE users ADD COLUMN deleted_at INTEGER;
+ALTER TABLE livestreams ADD COLUMN deleted_at INTEGER;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at);
+CREATE INDEX IF NOT EXISTS livestreams_deleted_at_idx ON livestreams (deleted_at);
+
+-- Lives ativas de quem o usuário segue
+CREATE INDEX IF NOT EXISTS livestreams_publisher_status_idx ON livestreams (publisher_id, live_stream_status);
+
+-- Lives ativas mais assistidas, candidatas do feed
+CREATE INDEX IF NOT EXISTS livestreams_status_viewers_idx ON livestreams (live_stream_status, viewer_count DESC, id);
+
+-- Contadores de seguidores e seguidos, mantidos junto dos follows
+ALTER TABLE users ADD COLUMN follower_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN following_count INTEGER NOT NULL DEFAULT 0;
+
+-- Dados que também ficavam no Mongo, para que um backend SQL seja
+-- autossuficiente. Cada tabela tem os mesmos índices da coleção
+-- correspondente.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         TEXT    PRIMARY KEY,
+    user_id    TEXT    NOT NULL,
+    family_id  TEXT    NOT NULL,
+    token_hash TEXT    NOT NULL UNIQUE,
+    device     TEXT    NOT NULL,
+    expires_at INTEGER NOT NULL,
+    revoked_at INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS stream_sessions (
+    id              TEXT    PRIMARY KEY,
+    live_stream_id  TEXT    NOT NULL,
+    publisher_id    TEXT    NOT NULL,
+    client_ip       TEXT    NOT NULL,
+    client_id       TEXT    NOT NULL,
+    encoder         TEXT    NOT NULL DEFAULT '{}',
+    peak_viewers    INTEGER NOT NULL DEFAULT 0,
+    average_viewers REAL    NOT NULL DEFAULT 0,
+    viewer_sum      INTEGER NOT NULL DEFAULT 0,
+    viewer_samples  INTEGER NOT NULL DEFAULT 0,
+    started_at      INTEGER NOT NULL,
+    ended_at        INTEGER
+);
+
+-- Sessão aberta da live e histórico de sessões, da mais recente para a mais antiga
+CREATE INDEX IF NOT EXISTS stream_sessions_open_idx ON stream_sessions (live_stream_id, ended_at);
+CREATE INDEX IF NOT EXISTS stream_sessions_started_at_idx ON stream_sessions (live_stream_id, started_at DESC, id);
+CREATE INDEX IF NOT EXISTS stream_sessions_publisher_id_idx ON stream_sessions (publisher_id);
+
+CREATE TABLE IF NOT EXISTS viewer_presence (
+    id             TEXT    PRIMARY KEY,
+    live_stream_id TEXT    NOT NULL,
+    session_id     TEXT    NOT NULL,
+    last_seen_at   INTEGER NOT NULL,
+    expires_at     INTEGER NOT NULL,
+    UNIQUE (live_stream_id, session_id)
+);
+
+CREATE INDEX IF NOT EXISTS viewer_presence_expires_at_idx ON viewer_presence (expires_at);
+
+CREATE TABLE IF NOT EXISTS follows (
+    id          TEXT    PRIMARY KEY,
+    follower_id TEXT    NOT NULL,
+    followee_id TEXT    NOT NULL,
+    created_at  INTEGER NOT NULL,
+    UNIQUE (follower_id, followee_id)
+);
+
+CREATE INDEX IF NOT EXISTS follows_followee_id_idx ON follows (followee_id, id);
+
+CREATE TABLE IF NOT EXISTS relations (
+    id         TEXT    PRIMARY KEY,
+    user_id    TEXT    NOT NULL,
+    target_id  TEXT    NOT NULL,
+    kind       TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    UNIQUE (user_id, target_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS relations_user_kind_idx ON relations (user_id, kind, id);
+CREATE INDEX IF NOT EXISTS relations_target_id_idx ON relations (target_id);
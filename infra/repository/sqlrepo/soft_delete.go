@@ -0,0 +1,20 @@
+package sqlrepo
+
+// Linhas removidas têm `deleted_at` preenchido até serem apagadas
+// definitivamente.
+
+// Restringe a condição `where` às linhas não removidas.
+func notDeleted(where string) string {
+	if where == "" {
+		return "deleted_at IS NULL"
+	}
+	return "deleted_at IS NULL AND " + where
+}
+
+// Restringe a condição `where` às linhas removidas.
+func deleted(where string) string {
+	if where == "" {
+		return "deleted_at IS NOT NULL"
+	}
+	return "deleted_at IS NOT NULL AND " + where
+}
@@ -0,0 +1,185 @@
+package sqlrepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository/repositorytest"
+	"github.com/gtvb/livestream/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Abre um banco vazio para cada subteste. O SQLite roda em memória; o
+// PostgreSQL só é testado quando `POSTGRES_TEST_DSN` está definida, e
+// tem as tabelas apagadas antes de cada subteste.
+func openTestDB(t *testing.T, backend string) *DB {
+	dsn := ":memory:"
+	if backend == Postgres {
+		dsn = os.Getenv("POSTGRES_TEST_DSN")
+		if dsn == "" {
+			t.Skip("POSTGRES_TEST_DSN not set")
+		}
+	}
+
+	db, err := Open(backend, dsn, 5*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	if backend == Postgres {
+		_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS users, livestreams, refresh_tokens, stream_sessions, viewer_presence, follows, relations")
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.ApplySchema(ctx))
+
+	return db
+}
+
+// O esquema é aplicado em toda inicialização, inclusive sobre bancos
+// criados antes das colunas adicionadas depois.
+func TestApplySchemaTwice(t *testing.T) {
+	db := openTestDB(t, SQLite)
+	require.NoError(t, db.ApplySchema(context.Background()))
+}
+
+// O busy_timeout é acrescentado mesmo a caminhos que já têm parâmetros.
+func TestOpenBusyTimeout(t *testing.T) {
+	for _, dsn := range []string{
+		filepath.Join(t.TempDir(), "plain.db"),
+		"file:" + filepath.Join(t.TempDir(), "params.db") + "?_pragma=foreign_keys(1)",
+	} {
+		db, err := Open(SQLite, dsn, 5*time.Second)
+		require.NoError(t, err, dsn)
+
+		var timeout int
+		require.NoError(t, db.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&timeout))
+		assert.Equal(t, 5000, timeout, dsn)
+		db.Close()
+	}
+}
+
+func TestUserRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunUserRepositoryTests(t, func(t *testing.T) models.UserRepositoryInterface {
+				return NewUserRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestLiveStreamRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunLiveStreamRepositoryTests(t, func(t *testing.T) models.LiveStreamRepositoryInterface {
+				return NewLiveStreamRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestRefreshTokenRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunRefreshTokenRepositoryTests(t, func(t *testing.T) models.RefreshTokenRepositoryInterface {
+				return NewRefreshTokenRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestStreamSessionRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunStreamSessionRepositoryTests(t, func(t *testing.T) models.StreamSessionRepositoryInterface {
+				return NewStreamSessionRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestViewerPresenceRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunViewerPresenceRepositoryTests(t, func(t *testing.T) models.ViewerPresenceRepositoryInterface {
+				return NewViewerPresenceRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestFollowRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunFollowRepositoryTests(t, func(t *testing.T) models.FollowRepositoryInterface {
+				return NewFollowRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestRelationRepositoryConformance(t *testing.T) {
+	for _, backend := range []string{SQLite, Postgres} {
+		t.Run(backend, func(t *testing.T) {
+			repositorytest.RunRelationRepositoryTests(t, func(t *testing.T) models.RelationRepositoryInterface {
+				return NewRelationRepository(openTestDB(t, backend))
+			})
+		})
+	}
+}
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, SQLite)
+	users := NewUserRepository(db)
+	liveStreams := NewLiveStreamRepository(db)
+	unitOfWork := NewUnitOfWork(db)
+
+	id, err := users.CreateUser(ctx, "johndoe", "johndoe@example.com", "password123")
+	require.NoError(t, err)
+	userID := id.(primitive.ObjectID)
+
+	t.Run("Rollback", func(t *testing.T) {
+		failure := errors.New("failure")
+
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			_, err := liveStreams.CreateLiveStream(ctx, "Test Stream", "fake-thumbnail", "streamkey-test", userID)
+			require.NoError(t, err)
+			require.NoError(t, users.DeleteUser(ctx, userID, time.Now()))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		_, err = users.GetUserById(ctx, userID)
+		assert.NoError(t, err)
+
+		all, err := liveStreams.GetAllLiveStreams(ctx, models.PageRequest{Limit: 10})
+		require.NoError(t, err)
+		assert.Empty(t, all.Items)
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if _, err := liveStreams.CreateLiveStream(ctx, "Test Stream", "fake-thumbnail", "streamkey-test", userID); err != nil {
+				return err
+			}
+			return users.UpdateUser(ctx, userID, bson.M{"username": "johnny"})
+		})
+		require.NoError(t, err)
+
+		user, err := users.GetUserById(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, "johnny", user.Username)
+
+		byUser, err := liveStreams.GetAllLiveStreamsByUserId(ctx, userID, models.PageRequest{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, byUser.Items, 1)
+	})
+}
@@ -0,0 +1,177 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const streamSessionColumns = "id, live_stream_id, publisher_id, client_ip, client_id, encoder, peak_viewers, " +
+	"average_viewers, viewer_sum, viewer_samples, started_at, ended_at"
+
+// Implementação SQL de `StreamSessionRepositoryInterface`. As informações
+// do encoder são guardadas como JSON, espelhando o subdocumento no Mongo.
+type StreamSessionRepository struct {
+	Db *DB
+}
+
+func NewStreamSessionRepository(db *DB) *StreamSessionRepository {
+	return &StreamSessionRepository{Db: db}
+}
+
+func scanStreamSession(row scanner) (*models.StreamSession, error) {
+	var session models.StreamSession
+	var id, liveStreamID, publisherID, encoder string
+	var startedAt int64
+	var endedAt sql.NullInt64
+
+	err := row.Scan(&id, &liveStreamID, &publisherID, &session.ClientIP, &session.ClientID, &encoder, &session.PeakViewers,
+		&session.AverageViewers, &session.ViewerSum, &session.ViewerSamples, &startedAt, &endedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if session.LiveStreamID, err = primitive.ObjectIDFromHex(liveStreamID); err != nil {
+		return nil, err
+	}
+	if session.PublisherID, err = primitive.ObjectIDFromHex(publisherID); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(encoder), &session.Encoder); err != nil {
+		return nil, fmt.Errorf("invalid encoder column: %w", err)
+	}
+	session.StartedAt = fromMillis(startedAt)
+	session.EndedAt = fromNullMillis(endedAt)
+
+	return &session, nil
+}
+
+func (sr *StreamSessionRepository) CreateStreamSession(ctx context.Context, liveStreamID, publisherID primitive.ObjectID, clientIP, clientID string, encoder models.EncoderInfo) (interface{}, error) {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	session := models.NewStreamSession(liveStreamID, publisherID, clientIP, clientID, encoder)
+	session.ID = primitive.NewObjectID()
+
+	encoded, err := json.Marshal(session.Encoder)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO stream_sessions (" + streamSessionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = sr.Db.conn(ctx).ExecContext(ctx, sr.Db.rebind(query), session.ID.Hex(), liveStreamID.Hex(), publisherID.Hex(),
+		clientIP, clientID, string(encoded), session.PeakViewers, session.AverageViewers, session.ViewerSum,
+		session.ViewerSamples, toMillis(session.StartedAt), toNullMillis(session.EndedAt))
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return session.ID, nil
+}
+
+// Encerra todas as sessões abertas da live, calculando a média
+// de espectadores a partir das amostras registradas.
+func (sr *StreamSessionRepository) CloseStreamSessions(ctx context.Context, liveStreamID primitive.ObjectID) error {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE stream_sessions SET ended_at = ?, " +
+		"average_viewers = CASE WHEN viewer_samples > 0 THEN viewer_sum * 1.0 / viewer_samples ELSE 0 END " +
+		"WHERE live_stream_id = ? AND ended_at IS NULL"
+	_, err := sr.Db.execAffected(ctx, query, toMillis(time.Now()), liveStreamID.Hex())
+	return err
+}
+
+func (sr *StreamSessionRepository) DeleteStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	return sr.Db.execAffected(ctx, "DELETE FROM stream_sessions WHERE publisher_id = ?", publisherID.Hex())
+}
+
+func (sr *StreamSessionRepository) CountStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	var count int64
+	query := "SELECT COUNT(*) FROM stream_sessions WHERE publisher_id = ?"
+	if err := sr.Db.conn(ctx).QueryRowContext(ctx, sr.Db.rebind(query), publisherID.Hex()).Scan(&count); err != nil {
+		return 0, wrapError(err)
+	}
+
+	return count, nil
+}
+
+// Registra uma amostra da quantidade de espectadores na sessão aberta da live.
+func (sr *StreamSessionRepository) RecordStreamSessionViewers(ctx context.Context, liveStreamID primitive.ObjectID, viewers int) error {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE stream_sessions SET peak_viewers = CASE WHEN peak_viewers < ? THEN ? ELSE peak_viewers END, " +
+		"viewer_sum = viewer_sum + ?, viewer_samples = viewer_samples + 1 WHERE live_stream_id = ? AND ended_at IS NULL"
+	_, err := sr.Db.execAffected(ctx, query, viewers, viewers, viewers, liveStreamID.Hex())
+	return err
+}
+
+func (sr *StreamSessionRepository) find(ctx context.Context, where, orderBy string, limit int, args ...any) ([]*models.StreamSession, error) {
+	ctx, cancel := sr.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + streamSessionColumns + " FROM stream_sessions WHERE " + where
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	return queryRows(ctx, sr.Db, query, scanStreamSession, args...)
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSession(ctx context.Context, liveStreamID primitive.ObjectID) (*models.StreamSession, error) {
+	sessions, err := sr.find(ctx, "live_stream_id = ? AND ended_at IS NULL", "started_at DESC, id DESC", 1, liveStreamID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("%w: no open session for live stream %s", repository.ErrNotFound, liveStreamID.Hex())
+	}
+
+	return sessions[0], nil
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSessions(ctx context.Context, liveStreamIDs []primitive.ObjectID) ([]*models.StreamSession, error) {
+	if len(liveStreamIDs) == 0 {
+		return []*models.StreamSession{}, nil
+	}
+
+	list, args := idList(liveStreamIDs)
+	return sr.find(ctx, "ended_at IS NULL AND live_stream_id IN "+list, "", 0, args...)
+}
+
+// Retorna uma página das sessões da live, da mais recente para a mais
+// antiga.
+func (sr *StreamSessionRepository) GetStreamSessionsByLiveStream(ctx context.Context, liveStreamID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.StreamSession], error) {
+	where, orderBy, args, err := pageQuery("live_stream_id = ?", "started_at", page.Cursor, []any{liveStreamID.Hex()})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := sr.find(ctx, where, orderBy, page.Limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(sessions, page, (*models.StreamSession).Position), nil
+}
@@ -0,0 +1,26 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Implementação de `UnitOfWork` com as transações do banco SQL. Os
+// repositórios encontram a transação no contexto recebido por `fn`.
+type UnitOfWork struct {
+	Db *DB
+}
+
+func NewUnitOfWork(db *DB) *UnitOfWork {
+	return &UnitOfWork{Db: db}
+}
+
+func (uw *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return uw.Db.inTx(ctx, func(tx querier) error {
+		// Uma unidade de trabalho aninhada já recebe a transação externa
+		if _, nested := ctx.Value(txKey{}).(*sql.Tx); nested {
+			return fn(ctx)
+		}
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
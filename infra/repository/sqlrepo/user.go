@@ -0,0 +1,304 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gtvb/livestream/infra/repository"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const userColumns = "id, username, email, password, follower_count, following_count, created_at, updated_at, deleted_at"
+
+// Implementação SQL de `UserRepositoryInterface`. Email e username são
+// únicos, como garantido pelos índices no Mongo.
+type UserRepository struct {
+	Db *DB
+}
+
+func NewUserRepository(db *DB) *UserRepository {
+	return &UserRepository{Db: db}
+}
+
+func scanUser(row scanner) (*models.User, error) {
+	var user models.User
+	var id string
+	var createdAt, updatedAt int64
+	var deletedAt sql.NullInt64
+
+	if err := row.Scan(&id, &user.Username, &user.Email, &user.Password, &user.FollowerCount, &user.FollowingCount, &createdAt, &updatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if user.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	user.CreatedAt = fromMillis(createdAt)
+	user.UpdatedAt = fromMillis(updatedAt)
+	user.DeletedAt = fromNullMillis(deletedAt)
+
+	return &user, nil
+}
+
+// Valores das colunas de `userColumns`, na mesma ordem.
+func userArgs(user *models.User) []any {
+	return []any{
+		user.ID.Hex(), user.Username, user.Email, user.Password, user.FollowerCount, user.FollowingCount,
+		toMillis(user.CreatedAt), toMillis(user.UpdatedAt), toNullMillis(user.DeletedAt),
+	}
+}
+
+func (ur *UserRepository) CreateUser(ctx context.Context, username, email, password string) (interface{}, error) {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	user, err := normalize(models.NewUser(username, email, password))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	user.ID = primitive.NewObjectID()
+
+	query := "INSERT INTO users (" + userColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err := ur.Db.conn(ctx).ExecContext(ctx, ur.Db.rebind(query), userArgs(user)...); err != nil {
+		return primitive.NilObjectID, wrapError(err)
+	}
+
+	return user.ID, nil
+}
+
+func (ur *UserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return ur.updateUser(ctx, id, func(user *models.User) error {
+		user.DeletedAt = &deletedAt
+		return nil
+	})
+}
+
+func (ur *UserRepository) RestoreUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET deleted_at = NULL, updated_at = ? WHERE " + deleted("id = ?")
+	restored, err := ur.Db.execAffected(ctx, query, toMillis(time.Now()), id.Hex())
+	if err != nil {
+		return err
+	}
+	if restored == 0 {
+		return fmt.Errorf("%w: no deleted user with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) PurgeUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	purged, err := ur.Db.execAffected(ctx, "DELETE FROM users WHERE "+deleted("id = ?"), id.Hex())
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return fmt.Errorf("%w: no deleted user with _id %s", repository.ErrNotFound, id.Hex())
+	}
+
+	return nil
+}
+
+// Lê o usuário não removido, aplica `change` e grava o resultado na
+// mesma transação.
+func (ur *UserRepository) updateUser(ctx context.Context, id primitive.ObjectID, change func(user *models.User) error) error {
+	return ur.updateUserWhere(ctx, notDeleted("id = ?"), id, change)
+}
+
+// Como `updateUser`, mas seleciona o usuário pela condição `where`, que
+// recebe o id como único argumento.
+func (ur *UserRepository) updateUserWhere(ctx context.Context, where string, id primitive.ObjectID, change func(user *models.User) error) error {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	return ur.Db.inTx(ctx, func(tx querier) error {
+		query := "SELECT " + userColumns + " FROM users WHERE " + where + ur.Db.forUpdate()
+		user, err := scanUser(tx.QueryRowContext(ctx, ur.Db.rebind(query), id.Hex()))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: no match for _id %s", repository.ErrNotFound, id.Hex())
+		}
+		if err != nil {
+			return wrapError(err)
+		}
+
+		updated, err := apply(user, change)
+		if err != nil {
+			return fmt.Errorf("%w: _id %s", err, id.Hex())
+		}
+		updated.ID = id
+
+		args := userArgs(updated)
+		query = "UPDATE users SET username = ?, email = ?, password = ?, follower_count = ?, following_count = ?, created_at = ?, updated_at = ?, deleted_at = ? WHERE id = ?"
+		_, err = tx.ExecContext(ctx, ur.Db.rebind(query), append(args[1:], args[0])...)
+		return wrapError(err)
+	})
+}
+
+func (ur *UserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
+	newData["updated_at"] = time.Now()
+	return ur.updateUser(ctx, id, setFields[models.User](newData))
+}
+
+func (ur *UserRepository) AddFollowCounts(ctx context.Context, id primitive.ObjectID, followers, following int) error {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE users SET follower_count = follower_count + ?, following_count = following_count + ? WHERE id = ?"
+	_, err := ur.Db.execAffected(ctx, query, followers, following, id.Hex())
+	return err
+}
+
+func (ur *UserRepository) SetFollowCounts(ctx context.Context, counts map[primitive.ObjectID]models.FollowCounts) (int64, error) {
+	var repaired int64
+
+	err := NewUnitOfWork(ur.Db).Do(ctx, func(ctx context.Context) error {
+		repaired = 0
+
+		users, err := ur.find(ctx, "")
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := ur.Db.withTimeout(ctx)
+		defer cancel()
+
+		// Só os usuários com contadores divergentes são gravados, e apenas
+		// se os contadores não mudaram desde a leitura: um follow feito
+		// nesse meio tempo não é sobrescrito, e o usuário é corrigido na
+		// próxima vez
+		for _, user := range users {
+			count := counts[user.ID]
+			if user.FollowerCount == count.Followers && user.FollowingCount == count.Following {
+				continue
+			}
+
+			query := "UPDATE users SET follower_count = ?, following_count = ? WHERE id = ? AND follower_count = ? AND following_count = ?"
+			affected, err := ur.Db.execAffected(ctx, query, count.Followers, count.Following, user.ID.Hex(), user.FollowerCount, user.FollowingCount)
+			if err != nil {
+				return err
+			}
+			repaired += affected
+		}
+
+		return nil
+	})
+
+	return repaired, err
+}
+
+// Retorna os usuários que satisfazem `where`, em ordem de id. Ids são
+// ObjectIDs, então essa é a ordem de criação, como a ordem natural do Mongo.
+// Usuários removidos só são excluídos se `where` filtrar por eles.
+func (ur *UserRepository) find(ctx context.Context, where string, args ...any) ([]*models.User, error) {
+	return ur.findOrdered(ctx, where, "id", 0, args...)
+}
+
+// Retorna até `limit` usuários que satisfazem `where`, na ordem `orderBy`.
+// Um limite zero retorna todos.
+func (ur *UserRepository) findOrdered(ctx context.Context, where, orderBy string, limit int, args ...any) ([]*models.User, error) {
+	ctx, cancel := ur.Db.withTimeout(ctx)
+	defer cancel()
+
+	query := "SELECT " + userColumns + " FROM users"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " ORDER BY " + orderBy
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := ur.Db.conn(ctx).QueryContext(ctx, ur.Db.rebind(query), args...)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, wrapError(err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return users, nil
+}
+
+func (ur *UserRepository) getUserByParam(ctx context.Context, where string, arg any) (*models.User, error) {
+	users, err := ur.find(ctx, where, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%w: no matching user", repository.ErrNotFound)
+	}
+
+	return users[0], nil
+}
+
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ur.getUserByParam(ctx, notDeleted("username = ?"), username)
+}
+
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.getUserByParam(ctx, notDeleted("email = ?"), email)
+}
+
+func (ur *UserRepository) GetUserById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ur.getUserByParam(ctx, notDeleted("id = ?"), id.Hex())
+}
+
+func (ur *UserRepository) GetUsersByIds(ctx context.Context, ids []primitive.ObjectID) ([]*models.User, error) {
+	if len(ids) == 0 {
+		return []*models.User{}, nil
+	}
+
+	list, args := idList(ids)
+	return ur.find(ctx, notDeleted("id IN "+list), args...)
+}
+
+func (ur *UserRepository) GetAllUsers(ctx context.Context, page models.PageRequest) (*models.Page[*models.User], error) {
+	return ur.findPage(ctx, notDeleted(""), page)
+}
+
+// Retorna a página de usuários que satisfazem `where`, em ordem de id.
+func (ur *UserRepository) findPage(ctx context.Context, where string, page models.PageRequest, args ...any) (*models.Page[*models.User], error) {
+	where, orderBy, args, err := pageQuery(where, "", page.Cursor, args)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := ur.findOrdered(ctx, where, orderBy, page.Limit+1, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewPage(users, page, (*models.User).Position), nil
+}
+
+func (ur *UserRepository) GetDeletedUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.getUserByParam(ctx, deleted("email = ?"), email)
+}
+
+func (ur *UserRepository) GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*models.User, error) {
+	return ur.find(ctx, "deleted_at < ?", toMillis(before))
+}
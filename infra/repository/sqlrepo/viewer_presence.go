@@ -0,0 +1,63 @@
+package sqlrepo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Implementação SQL de `ViewerPresenceRepositoryInterface`. Cada sessão
+// de espectador tem uma única presença por live.
+type ViewerPresenceRepository struct {
+	Db *DB
+}
+
+func NewViewerPresenceRepository(db *DB) *ViewerPresenceRepository {
+	return &ViewerPresenceRepository{Db: db}
+}
+
+// Sem o índice TTL do Mongo, as presenças expiradas de todas as lives são
+// apagadas a cada heartbeat, na mesma transação.
+func (vr *ViewerPresenceRepository) RecordViewerHeartbeat(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string, ttl time.Duration) error {
+	ctx, cancel := vr.Db.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	return vr.Db.inTx(ctx, func(tx querier) error {
+		if _, err := tx.ExecContext(ctx, vr.Db.rebind("DELETE FROM viewer_presence WHERE expires_at <= ?"), toMillis(now)); err != nil {
+			return wrapError(err)
+		}
+
+		query := "INSERT INTO viewer_presence (id, live_stream_id, session_id, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?) " +
+			"ON CONFLICT (live_stream_id, session_id) DO UPDATE SET last_seen_at = excluded.last_seen_at, expires_at = excluded.expires_at"
+		_, err := tx.ExecContext(ctx, vr.Db.rebind(query), primitive.NewObjectID().Hex(), liveStreamID.Hex(), sessionID,
+			toMillis(now), toMillis(now.Add(ttl)))
+		return wrapError(err)
+	})
+}
+
+func (vr *ViewerPresenceRepository) RemoveViewer(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string) error {
+	ctx, cancel := vr.Db.withTimeout(ctx)
+	defer cancel()
+
+	_, err := vr.Db.execAffected(ctx, "DELETE FROM viewer_presence WHERE live_stream_id = ? AND session_id = ?", liveStreamID.Hex(), sessionID)
+	return err
+}
+
+// Conta os espectadores com heartbeat ainda válido. As presenças
+// expiradas só são apagadas no próximo heartbeat, então a expiração
+// também é filtrada aqui.
+func (vr *ViewerPresenceRepository) CountActiveViewers(ctx context.Context, liveStreamID primitive.ObjectID) (int, error) {
+	ctx, cancel := vr.Db.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	query := "SELECT COUNT(*) FROM viewer_presence WHERE live_stream_id = ? AND expires_at > ?"
+	err := vr.Db.conn(ctx).QueryRowContext(ctx, vr.Db.rebind(query), liveStreamID.Hex(), toMillis(time.Now())).Scan(&count)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return count, nil
+}
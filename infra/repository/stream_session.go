@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repositório de acesso aos dados da entidade `StreamSession`.
+// Qualquer repositório precisa implementar a interface
+// `StreamSessionRepositoryInterface` para ser utilizada de forma
+// válida pelo servidor HTTP.
+type StreamSessionRepository struct {
+	streamSessionCollectionName string
+	Db                          *db.Database
+}
+
+func NewStreamSessionRepository(db *db.Database, streamSessionCollectionName string) *StreamSessionRepository {
+	return &StreamSessionRepository{
+		streamSessionCollectionName: streamSessionCollectionName,
+		Db:                          db,
+	}
+}
+
+// Cria os índices usados pelas buscas de sessões: a sessão aberta de uma
+// live, também atualizada a cada amostra de espectadores, a listagem
+// paginada das sessões da live e a remoção das sessões de um publisher.
+func (sr *StreamSessionRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "live_stream_id", Value: 1}, {Key: "ended_at", Value: 1}}},
+		{Keys: bson.D{{Key: "live_stream_id", Value: 1}, {Key: "started_at", Value: -1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "publisher_id", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+func (sr *StreamSessionRepository) CreateStreamSession(ctx context.Context, liveStreamID, publisherID primitive.ObjectID, clientIP, clientID string, encoder models.EncoderInfo) (interface{}, error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+	doc := models.NewStreamSession(liveStreamID, publisherID, clientIP, clientID, encoder)
+
+	res, err := coll.InsertOne(ctx, doc)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return res.InsertedID, nil
+}
+
+// Encerra todas as sessões abertas da live, calculando a média
+// de espectadores a partir das amostras registradas.
+func (sr *StreamSessionRepository) CloseStreamSessions(ctx context.Context, liveStreamID primitive.ObjectID) error {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+	filter := bson.M{"live_stream_id": liveStreamID, "ended_at": nil}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"ended_at": time.Now(),
+			"average_viewers": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$viewer_samples", 0}},
+				bson.M{"$divide": bson.A{"$viewer_sum", "$viewer_samples"}},
+				0,
+			}},
+		}}},
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (sr *StreamSessionRepository) DeleteStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+
+	res, err := coll.DeleteMany(ctx, bson.M{"publisher_id": publisherID})
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return res.DeletedCount, nil
+}
+
+func (sr *StreamSessionRepository) CountStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+
+	count, err := coll.CountDocuments(ctx, bson.M{"publisher_id": publisherID})
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return count, nil
+}
+
+// Registra uma amostra da quantidade de espectadores na sessão aberta da live.
+func (sr *StreamSessionRepository) RecordStreamSessionViewers(ctx context.Context, liveStreamID primitive.ObjectID, viewers int) error {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+	filter := bson.M{"live_stream_id": liveStreamID, "ended_at": nil}
+
+	update := bson.M{
+		"$max": bson.M{"peak_viewers": viewers},
+		"$inc": bson.M{"viewer_sum": viewers, "viewer_samples": 1},
+	}
+
+	_, err := coll.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSession(ctx context.Context, liveStreamID primitive.ObjectID) (*models.StreamSession, error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	var session models.StreamSession
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+
+	filter := bson.M{"live_stream_id": liveStreamID, "ended_at": nil}
+	opts := options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}})
+
+	res := coll.FindOne(ctx, filter, opts)
+	if err := res.Decode(&session); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &session, nil
+}
+
+func (sr *StreamSessionRepository) GetOpenStreamSessions(ctx context.Context, liveStreamIDs []primitive.ObjectID) ([]*models.StreamSession, error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	sessions := make([]*models.StreamSession, 0)
+	if len(liveStreamIDs) == 0 {
+		return sessions, nil
+	}
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+	filter := bson.M{"live_stream_id": bson.M{"$in": liveStreamIDs}, "ended_at": nil}
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, wrapError(err)
+	}
+
+	return sessions, nil
+}
+
+// Retorna uma página das sessões da live, da mais recente para a mais
+// antiga.
+func (sr *StreamSessionRepository) GetStreamSessionsByLiveStream(ctx context.Context, liveStreamID primitive.ObjectID, page models.PageRequest) (*models.Page[*models.StreamSession], error) {
+	ctx, cancel := sr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := sr.Db.Collection(sr.streamSessionCollectionName)
+	return findTimePage(ctx, coll, bson.M{"live_stream_id": liveStreamID}, "started_at", page, (*models.StreamSession).Position)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateStreamSession(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	sessionRepo := NewStreamSessionRepository(container.Database, utils.StreamSessionCollectionTest)
+	liveStreamID := primitive.NewObjectID()
+
+	insertedID, err := sessionRepo.CreateStreamSession(context.Background(), liveStreamID, primitive.NewObjectID(), "127.0.0.1", "1", models.EncoderInfo{FlashVersion: "FMLE/3.0"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, primitive.NilObjectID, insertedID)
+
+	session, err := sessionRepo.GetOpenStreamSession(context.Background(), liveStreamID)
+	assert.NoError(t, err)
+	assert.Equal(t, "FMLE/3.0", session.Encoder.FlashVersion)
+	assert.Nil(t, session.EndedAt)
+}
+
+func TestGetOpenStreamSessions(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	sessionRepo := NewStreamSessionRepository(container.Database, utils.StreamSessionCollectionTest)
+	open, closed := primitive.NewObjectID(), primitive.NewObjectID()
+
+	sessionRepo.CreateStreamSession(context.Background(), open, primitive.NewObjectID(), "127.0.0.1", "1", models.EncoderInfo{})
+	sessionRepo.CreateStreamSession(context.Background(), closed, primitive.NewObjectID(), "127.0.0.1", "2", models.EncoderInfo{})
+	assert.NoError(t, sessionRepo.CloseStreamSessions(context.Background(), closed))
+
+	sessions, err := sessionRepo.GetOpenStreamSessions(context.Background(), []primitive.ObjectID{open, closed, primitive.NewObjectID()})
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, open, sessions[0].LiveStreamID)
+	}
+}
+
+func TestCloseStreamSessions(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	sessionRepo := NewStreamSessionRepository(container.Database, utils.StreamSessionCollectionTest)
+	liveStreamID := primitive.NewObjectID()
+
+	sessionRepo.CreateStreamSession(context.Background(), liveStreamID, primitive.NewObjectID(), "127.0.0.1", "1", models.EncoderInfo{})
+
+	assert.NoError(t, sessionRepo.RecordStreamSessionViewers(context.Background(), liveStreamID, 2))
+	assert.NoError(t, sessionRepo.RecordStreamSessionViewers(context.Background(), liveStreamID, 6))
+	assert.NoError(t, sessionRepo.CloseStreamSessions(context.Background(), liveStreamID))
+
+	_, err := sessionRepo.GetOpenStreamSession(context.Background(), liveStreamID)
+	assert.Error(t, err)
+
+	page, err := sessionRepo.GetStreamSessionsByLiveStream(context.Background(), liveStreamID, models.PageRequest{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Items, 1) {
+		assert.NotNil(t, page.Items[0].EndedAt)
+		assert.Equal(t, 6, page.Items[0].PeakViewers)
+		assert.Equal(t, 4.0, page.Items[0].AverageViewers)
+	}
+}
+
+func TestGetStreamSessionsByLiveStream(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	sessionRepo := NewStreamSessionRepository(container.Database, utils.StreamSessionCollectionTest)
+	liveStreamID := primitive.NewObjectID()
+
+	for i := 0; i < 3; i++ {
+		sessionRepo.CreateStreamSession(context.Background(), liveStreamID, primitive.NewObjectID(), "127.0.0.1", "1", models.EncoderInfo{})
+		sessionRepo.CloseStreamSessions(context.Background(), liveStreamID)
+	}
+
+	first, err := sessionRepo.GetStreamSessionsByLiveStream(context.Background(), liveStreamID, models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, first.Items, 2)
+	assert.Empty(t, first.PrevCursor)
+
+	cursor, err := models.DecodeCursor(first.NextCursor)
+	assert.NoError(t, err)
+
+	second, err := sessionRepo.GetStreamSessionsByLiveStream(context.Background(), liveStreamID, models.PageRequest{Limit: 2, Cursor: cursor})
+	assert.NoError(t, err)
+	if assert.Len(t, second.Items, 1) {
+		// Da mais recente para a mais antiga
+		assert.False(t, second.Items[0].StartedAt.After(first.Items[1].StartedAt))
+	}
+	assert.Empty(t, second.NextCursor)
+	assert.NotEmpty(t, second.PrevCursor)
+}
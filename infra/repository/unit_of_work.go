@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gtvb/livestream/infra/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Implementação de `UnitOfWork` com as transações do Mongo. As operações
+// dos repositórios participam da transação através da sessão guardada
+// no contexto recebido por `fn`.
+//
+// Transações só existem em replica sets (ou atrás de um mongos).
+type UnitOfWork struct {
+	Db *db.Database
+}
+
+// Cria a unidade de trabalho, verificando se o servidor suporta transações.
+// Um servidor isolado é recusado: as operações que dependem da unidade de
+// trabalho deixariam dados pela metade ao falhar no meio do caminho.
+func NewUnitOfWork(ctx context.Context, db *db.Database) (*UnitOfWork, error) {
+	ctx, cancel := db.WithTimeout(ctx)
+	defer cancel()
+
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+
+	err := db.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return nil, errors.New("mongo server is not a replica set, transactions are not supported")
+	}
+
+	return &UnitOfWork{Db: db}, nil
+}
+
+func (uw *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := uw.Db.Client().StartSession()
+	if err != nil {
+		return wrapError(err)
+	}
+	defer session.EndSession(ctx)
+
+	// Erros de `fn` já vêm classificados pelos repositórios, apenas os
+	// erros da própria transação precisam ser traduzidos
+	var fnErr error
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		fnErr = fn(sc)
+		return nil, fnErr
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return wrapError(err)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/models"
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	ctx := context.Background()
+	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
+	sessionRepo := NewStreamSessionRepository(container.Database, utils.StreamSessionCollectionTest)
+	tokenRepo := NewRefreshTokenRepository(container.Database, utils.RefreshTokenCollectionTest)
+
+	unitOfWork, err := NewUnitOfWork(ctx, container.Database)
+	require.NoError(t, err)
+
+	id, err := userRepo.CreateUser(ctx, "johndoe", "johndoe@example.com", "password123")
+	require.NoError(t, err)
+	userID := id.(primitive.ObjectID)
+
+	liveStreamID := primitive.NewObjectID()
+	_, err = sessionRepo.CreateStreamSession(ctx, liveStreamID, userID, "127.0.0.1", "1", models.EncoderInfo{})
+	require.NoError(t, err)
+	_, err = tokenRepo.CreateRefreshToken(ctx, userID, primitive.NewObjectID(), "token_hash", "test", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	t.Run("Rollback", func(t *testing.T) {
+		failure := errors.New("failure")
+
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			deleted, err := sessionRepo.DeleteStreamSessionsByPublisher(ctx, userID)
+			require.NoError(t, err)
+			assert.EqualValues(t, 1, deleted)
+
+			require.NoError(t, userRepo.DeleteUser(ctx, userID, time.Now()))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		_, err = userRepo.GetUserById(ctx, userID)
+		assert.NoError(t, err)
+
+		page, err := sessionRepo.GetStreamSessionsByLiveStream(ctx, liveStreamID, models.PageRequest{Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, page.Items, 1)
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			deleted, err := tokenRepo.DeleteAllUserRefreshTokens(ctx, userID)
+			if err != nil {
+				return err
+			}
+			assert.EqualValues(t, 1, deleted)
+
+			return userRepo.DeleteUser(ctx, userID, time.Now())
+		})
+		require.NoError(t, err)
+
+		_, err = userRepo.GetUserById(ctx, userID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = tokenRepo.GetRefreshTokenByHash(ctx, "token_hash")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Repository errors", func(t *testing.T) {
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return userRepo.DeleteUser(ctx, userID, time.Now())
+		})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/gtvb/livestream/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Repositório de acesso aos dados da entidade `User`.
@@ -27,108 +29,270 @@ func NewUserRepository(db *db.Database, userCollectionName string) *UserReposito
 	}
 }
 
-func (ur *UserRepository) CreateUser(username, email, password string) (interface{}, error) {
+// Cria os índices únicos de email e username. Eles garantem que dois
+// cadastros concorrentes não resultem em usuários duplicados. O índice
+// de `deleted_at` é usado na busca por contas a serem apagadas.
+func (ur *UserRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := ur.Db.Collection(ur.userCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+	})
+
+	return wrapError(err)
+}
+
+func (ur *UserRepository) CreateUser(ctx context.Context, username, email, password string) (interface{}, error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := ur.Db.Collection(ur.userCollectionName)
 	doc := models.NewUser(username, email, password)
 
 	// TODO: verify email against a valid pattern and return error if it exists, implement it
 
-	id, err := coll.InsertOne(context.TODO(), doc)
+	id, err := coll.InsertOne(ctx, doc)
 	if err != nil {
-		return primitive.NilObjectID, err
+		return primitive.NilObjectID, wrapError(err)
 	}
 
 	return id.InsertedID, nil
 }
 
-func (ur *UserRepository) DeleteUser(id primitive.ObjectID) error {
+func (ur *UserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error {
+	return ur.updateUser(ctx, id, bson.M{"$set": bson.M{"deleted_at": deletedAt}})
+}
+
+func (ur *UserRepository) RestoreUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := ur.Db.Collection(ur.userCollectionName)
-	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"deleted_at": nil, "updated_at": time.Now()}}
 
-	_, err := coll.DeleteOne(context.TODO(), filter)
+	res, err := coll.UpdateOne(ctx, deleted(bson.M{"_id": id}), update)
 	if err != nil {
-		return err
+		return wrapError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: no deleted user with _id %s", ErrNotFound, id.Hex())
 	}
 
 	return nil
 }
 
-func (ur *UserRepository) updateUser(id primitive.ObjectID, updateQuery primitive.M) error {
+func (ur *UserRepository) PurgeUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := ur.Db.Collection(ur.userCollectionName)
 
-	res, err := coll.UpdateByID(context.TODO(), id, updateQuery)
+	res, err := coll.DeleteOne(ctx, deleted(bson.M{"_id": id}))
 	if err != nil {
-		return err
+		return wrapError(err)
+	}
+
+	if res.DeletedCount != 1 {
+		return fmt.Errorf("%w: no deleted user with _id %s", ErrNotFound, id.Hex())
+	}
+
+	return nil
+}
+
+func (ur *UserRepository) updateUser(ctx context.Context, id primitive.ObjectID, updateQuery primitive.M) error {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := ur.Db.Collection(ur.userCollectionName)
+
+	res, err := coll.UpdateOne(ctx, notDeleted(bson.M{"_id": id}), updateQuery)
+	if err != nil {
+		return wrapError(err)
 	}
 
 	if res.MatchedCount == 0 {
-		return fmt.Errorf("no match for _id %d", id)
+		return fmt.Errorf("%w: no match for _id %s", ErrNotFound, id.Hex())
 	}
 
 	if res.ModifiedCount != 1 {
-		return fmt.Errorf("expected one document to be updated, got %d", res.ModifiedCount)
+		return fmt.Errorf("%w: _id %s", ErrNoChange, id.Hex())
 	}
 
 	return nil
 }
 
-func (ur *UserRepository) UpdateUser(id primitive.ObjectID, newData bson.M) error {
+func (ur *UserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, newData bson.M) error {
 	newData["updated_at"] = time.Now()
 
-	err := ur.updateUser(id, bson.M{"$set": newData})
+	err := ur.updateUser(ctx, id, bson.M{"$set": newData})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ur *UserRepository) UpdateUserAddToFollowList(id primitive.ObjectID, following primitive.ObjectID) error {
-	update := bson.M{"$push": bson.M{"following": following}, "$set": bson.M{"updated_at": time.Now()}}
-	return ur.updateUser(id, update)
+func (ur *UserRepository) AddFollowCounts(ctx context.Context, id primitive.ObjectID, followers, following int) error {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := ur.Db.Collection(ur.userCollectionName)
+	update := bson.M{"$inc": bson.M{"follower_count": followers, "following_count": following}}
+
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return wrapError(err)
+}
+
+func (ur *UserRepository) SetFollowCounts(ctx context.Context, counts map[primitive.ObjectID]models.FollowCounts) (int64, error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := ur.Db.Collection(ur.userCollectionName)
+
+	projection := bson.M{"follower_count": 1, "following_count": 1}
+	users, err := coll.Find(ctx, bson.M{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return 0, wrapError(err)
+	}
+	defer users.Close(ctx)
+
+	// Só os usuários com contadores divergentes são gravados, e apenas se
+	// os contadores não mudaram desde a leitura: um follow feito nesse
+	// meio tempo não é sobrescrito, e o usuário é corrigido na próxima vez
+	var updates []mongo.WriteModel
+	for users.Next(ctx) {
+		var user models.User
+		if err := users.Decode(&user); err != nil {
+			return 0, wrapError(err)
+		}
+
+		count := counts[user.ID]
+		if user.FollowerCount == count.Followers && user.FollowingCount == count.Following {
+			continue
+		}
+
+		counters := bson.M{"follower_count": count.Followers, "following_count": count.Following}
+		filter := bson.M{
+			"_id":             user.ID,
+			"follower_count":  readCounter(user.FollowerCount),
+			"following_count": readCounter(user.FollowingCount),
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": counters}))
+	}
+	if err := users.Err(); err != nil {
+		return 0, wrapError(err)
+	}
+
+	if len(updates) == 0 {
+		return 0, nil
+	}
+
+	res, err := coll.BulkWrite(ctx, updates)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return res.ModifiedCount, nil
 }
 
-func (ur *UserRepository) UpdateUserRemoveFromFollowList(id primitive.ObjectID, following primitive.ObjectID) error {
-	update := bson.M{"$pull": bson.M{"following": following}, "$set": bson.M{"updated_at": time.Now()}}
-	return ur.updateUser(id, update)
+// Filtro pelo valor lido de um contador. Usuários antigos podem não ter
+// o campo, que é lido como zero.
+func readCounter(value int) any {
+	if value == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return value
 }
 
-func (ur *UserRepository) getUserByParam(filter primitive.M) (*models.User, error) {
+func (ur *UserRepository) getUserByParam(ctx context.Context, filter primitive.M) (*models.User, error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
 	var user models.User
 	coll := ur.Db.Collection(ur.userCollectionName)
 
-	res := coll.FindOne(context.TODO(), filter)
+	res := coll.FindOne(ctx, notDeleted(filter))
 	err := res.Decode(&user)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return &user, nil
 }
 
-func (ur *UserRepository) GetUserByUsername(username string) (*models.User, error) {
-	return ur.getUserByParam(bson.M{"username": username})
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ur.getUserByParam(ctx, bson.M{"username": username})
+}
+
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.getUserByParam(ctx, bson.M{"email": email})
 }
 
-func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
-	return ur.getUserByParam(bson.M{"email": email})
+func (ur *UserRepository) GetUserById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ur.getUserByParam(ctx, bson.M{"_id": id})
 }
 
-func (ur *UserRepository) GetUserById(id primitive.ObjectID) (*models.User, error) {
-	return ur.getUserByParam(bson.M{"_id": id})
+func (ur *UserRepository) GetUsersByIds(ctx context.Context, ids []primitive.ObjectID) ([]*models.User, error) {
+	if len(ids) == 0 {
+		return []*models.User{}, nil
+	}
+	return ur.findUsers(ctx, notDeleted(bson.M{"_id": bson.M{"$in": ids}}))
 }
 
-func (ur *UserRepository) GetAllUsers() ([]*models.User, error) {
+func (ur *UserRepository) GetAllUsers(ctx context.Context, page models.PageRequest) (*models.Page[*models.User], error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := ur.Db.Collection(ur.userCollectionName)
+	return findPage(ctx, coll, notDeleted(bson.M{}), "", page, (*models.User).Position)
+}
+
+func (ur *UserRepository) GetDeletedUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
+	var user models.User
+	coll := ur.Db.Collection(ur.userCollectionName)
+
+	err := coll.FindOne(ctx, deleted(bson.M{"email": email})).Decode(&user)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+
+	return &user, nil
+}
+
+func (ur *UserRepository) GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*models.User, error) {
+	return ur.findUsers(ctx, bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": before}})
+}
+
+func (ur *UserRepository) findUsers(ctx context.Context, filter bson.M) ([]*models.User, error) {
+	ctx, cancel := ur.Db.WithTimeout(ctx)
+	defer cancel()
+
 	coll := ur.Db.Collection(ur.userCollectionName)
-	filter := bson.D{}
 
-	cursor, err := coll.Find(context.TODO(), filter)
+	cursor, err := coll.Find(ctx, filter)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	var users []*models.User
-	if err = cursor.All(context.TODO(), &users); err != nil {
-		return nil, err
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, wrapError(err)
 	}
 
 	return users, nil
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/gtvb/livestream/models"
 	"github.com/gtvb/livestream/utils"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -25,83 +27,87 @@ func TestCreateUser(t *testing.T) {
 	defer container.Terminate()
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
-	insertedID, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
+	insertedID, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 
 	assert.NoError(t, err)
 	assert.NotEqual(t, primitive.NilObjectID, insertedID)
 }
 
-func TestDeleteUser(t *testing.T) {
+func TestCreateUserDuplicate(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
+	assert.NoError(t, userRepo.EnsureIndexes(context.Background()))
 
-	insertedID, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
+	_, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 	assert.NoError(t, err)
-	assert.NotEqual(t, primitive.NilObjectID, insertedID)
 
-	err = userRepo.DeleteUser(insertedID.(primitive.ObjectID))
-	assert.NoError(t, err)
+	_, err = userRepo.CreateUser(context.Background(), "janedoe", "johndoe@example.com", "password123")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = userRepo.CreateUser(context.Background(), "johndoe", "other@example.com", "password123")
+	assert.ErrorIs(t, err, ErrConflict)
 }
 
-func TestUpdateUser(t *testing.T) {
+func TestDeleteUser(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
-	insertedID, _ := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
 
-	err := userRepo.UpdateUser(insertedID.(primitive.ObjectID), bson.M{"email": "johndoe@new.example.com"})
-	assert.Equal(t, nil, err)
+	insertedID, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
+	assert.NoError(t, err)
+	assert.NotEqual(t, primitive.NilObjectID, insertedID)
 
-	user, _ := userRepo.GetUserById(insertedID.(primitive.ObjectID))
-	assert.Equal(t, "johndoe@new.example.com", user.Email)
-	assert.Equal(t, "johndoe", user.Username)
+	err = userRepo.DeleteUser(context.Background(), insertedID.(primitive.ObjectID), time.Now())
+	assert.NoError(t, err)
 }
 
-func TestUpdateAddToFollowList(t *testing.T) {
+func TestUpdateUser(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
+	insertedID, _ := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 
-	insertedIDFollower, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
-	assert.NoError(t, err)
-
-	insertedIDFollowing, err := userRepo.CreateUser("alice", "alice@example.com", "password456")
-	assert.NoError(t, err)
-
-	err = userRepo.UpdateUserAddToFollowList(insertedIDFollower.(primitive.ObjectID), insertedIDFollowing.(primitive.ObjectID))
-	assert.NoError(t, err)
+	err := userRepo.UpdateUser(context.Background(), insertedID.(primitive.ObjectID), bson.M{"email": "johndoe@new.example.com"})
+	assert.Equal(t, nil, err)
 
-	userAfterUpdate, err := userRepo.GetUserById(insertedIDFollower.(primitive.ObjectID))
-	assert.NoError(t, err)
-	assert.Contains(t, userAfterUpdate.Following, insertedIDFollowing)
+	user, _ := userRepo.GetUserById(context.Background(), insertedID.(primitive.ObjectID))
+	assert.Equal(t, "johndoe@new.example.com", user.Email)
+	assert.Equal(t, "johndoe", user.Username)
 }
 
-func TestUpdateRemoveFromFollowList(t *testing.T) {
+func TestAddFollowCounts(t *testing.T) {
 	container := setupDatabase()
 	defer container.Terminate()
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
 
-	insertedIDFollower, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
+	insertedID, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 	assert.NoError(t, err)
+	id := insertedID.(primitive.ObjectID)
 
-	insertedIDFollowing, err := userRepo.CreateUser("alice", "alice@example.com", "password456")
-	assert.NoError(t, err)
+	assert.NoError(t, userRepo.AddFollowCounts(context.Background(), id, 2, 1))
+	assert.NoError(t, userRepo.AddFollowCounts(context.Background(), id, -1, 0))
+	// Usuários inexistentes são ignorados
+	assert.NoError(t, userRepo.AddFollowCounts(context.Background(), primitive.NewObjectID(), 1, 1))
 
-	userBeforeUpdate, err := userRepo.GetUserById(insertedIDFollower.(primitive.ObjectID))
+	user, err := userRepo.GetUserById(context.Background(), id)
 	assert.NoError(t, err)
-	assert.NotContains(t, userBeforeUpdate.Following, insertedIDFollowing)
+	assert.Equal(t, 1, user.FollowerCount)
+	assert.Equal(t, 1, user.FollowingCount)
 
-	err = userRepo.UpdateUserRemoveFromFollowList(insertedIDFollower.(primitive.ObjectID), insertedIDFollowing.(primitive.ObjectID))
+	// Os contadores são sobrescritos, com zero para quem não aparece
+	repaired, err := userRepo.SetFollowCounts(context.Background(), map[primitive.ObjectID]models.FollowCounts{})
 	assert.NoError(t, err)
+	assert.EqualValues(t, 1, repaired)
 
-	userAfterUpdate, err := userRepo.GetUserById(insertedIDFollower.(primitive.ObjectID))
+	user, err = userRepo.GetUserById(context.Background(), id)
 	assert.NoError(t, err)
-	assert.NotContains(t, userAfterUpdate.Following, insertedIDFollowing)
+	assert.Zero(t, user.FollowerCount)
+	assert.Zero(t, user.FollowingCount)
 }
 
 func TestGetUserByUsername(t *testing.T) {
@@ -110,10 +116,10 @@ func TestGetUserByUsername(t *testing.T) {
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
 
-	_, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
+	_, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 	assert.NoError(t, err)
 
-	user, err := userRepo.GetUserByUsername("johndoe")
+	user, err := userRepo.GetUserByUsername(context.Background(), "johndoe")
 
 	assert.NoError(t, err)
 	assert.Equal(t, "johndoe", user.Username)
@@ -126,14 +132,14 @@ func TestGetAllUsers(t *testing.T) {
 
 	userRepo := NewUserRepository(container.Database, utils.UserCollectionTest)
 
-	_, err := userRepo.CreateUser("johndoe", "johndoe@example.com", "password123")
+	_, err := userRepo.CreateUser(context.Background(), "johndoe", "johndoe@example.com", "password123")
 	assert.NoError(t, err)
 
-	_, err = userRepo.CreateUser("janedoe", "janedoe@example.com", "password123")
+	_, err = userRepo.CreateUser(context.Background(), "janedoe", "janedoe@example.com", "password123")
 	assert.NoError(t, err)
 
-	users, err := userRepo.GetAllUsers()
+	users, err := userRepo.GetAllUsers(context.Background(), models.PageRequest{Limit: 10})
 
 	assert.NoError(t, err)
-	assert.Len(t, users, 2)
+	assert.Len(t, users.Items, 2)
 }
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/gtvb/livestream/infra/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repositório de acesso aos dados da entidade `ViewerPresence`.
+// Qualquer repositório precisa implementar a interface
+// `ViewerPresenceRepositoryInterface` para ser utilizada de forma
+// válida pelo servidor HTTP.
+type ViewerPresenceRepository struct {
+	viewerPresenceCollectionName string
+	Db                           *db.Database
+}
+
+func NewViewerPresenceRepository(db *db.Database, viewerPresenceCollectionName string) *ViewerPresenceRepository {
+	return &ViewerPresenceRepository{
+		viewerPresenceCollectionName: viewerPresenceCollectionName,
+		Db:                           db,
+	}
+}
+
+// Cria o índice TTL que faz o Mongo remover as presenças expiradas,
+// além do índice único que garante uma presença por sessão de espectador.
+func (vr *ViewerPresenceRepository) EnsureIndexes(ctx context.Context) error {
+	ctx, cancel := vr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := vr.Db.Collection(vr.viewerPresenceCollectionName)
+
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "live_stream_id", Value: 1}, {Key: "session_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+
+	return wrapError(err)
+}
+
+func (vr *ViewerPresenceRepository) RecordViewerHeartbeat(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string, ttl time.Duration) error {
+	ctx, cancel := vr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := vr.Db.Collection(vr.viewerPresenceCollectionName)
+
+	now := time.Now()
+	filter := bson.M{"live_stream_id": liveStreamID, "session_id": sessionID}
+	update := bson.M{"$set": bson.M{"last_seen_at": now, "expires_at": now.Add(ttl)}}
+
+	_, err := coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+func (vr *ViewerPresenceRepository) RemoveViewer(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string) error {
+	ctx, cancel := vr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := vr.Db.Collection(vr.viewerPresenceCollectionName)
+	filter := bson.M{"live_stream_id": liveStreamID, "session_id": sessionID}
+
+	_, err := coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return nil
+}
+
+// Conta os espectadores com heartbeat ainda válido. O monitor de TTL do
+// Mongo só roda a cada minuto, então a expiração também é filtrada aqui.
+func (vr *ViewerPresenceRepository) CountActiveViewers(ctx context.Context, liveStreamID primitive.ObjectID) (int, error) {
+	ctx, cancel := vr.Db.WithTimeout(ctx)
+	defer cancel()
+
+	coll := vr.Db.Collection(vr.viewerPresenceCollectionName)
+	filter := bson.M{"live_stream_id": liveStreamID, "expires_at": bson.M{"$gt": time.Now()}}
+
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, wrapError(err)
+	}
+
+	return int(count), nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gtvb/livestream/utils"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRecordViewerHeartbeat(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	presenceRepo := NewViewerPresenceRepository(container.Database, utils.ViewerPresenceCollectionTest)
+	assert.NoError(t, presenceRepo.EnsureIndexes(context.Background()))
+
+	liveStreamID := primitive.NewObjectID()
+
+	// Heartbeats repetidos da mesma sessão contam um único espectador
+	assert.NoError(t, presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-1", time.Minute))
+	assert.NoError(t, presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-1", time.Minute))
+	assert.NoError(t, presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-2", time.Minute))
+
+	count, err := presenceRepo.CountActiveViewers(context.Background(), liveStreamID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestExpiredViewersAreNotCounted(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	presenceRepo := NewViewerPresenceRepository(container.Database, utils.ViewerPresenceCollectionTest)
+	liveStreamID := primitive.NewObjectID()
+
+	presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-1", -time.Second)
+	presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-2", time.Minute)
+
+	count, err := presenceRepo.CountActiveViewers(context.Background(), liveStreamID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestRemoveViewer(t *testing.T) {
+	container := setupDatabase()
+	defer container.Terminate()
+
+	presenceRepo := NewViewerPresenceRepository(container.Database, utils.ViewerPresenceCollectionTest)
+	liveStreamID := primitive.NewObjectID()
+
+	presenceRepo.RecordViewerHeartbeat(context.Background(), liveStreamID, "viewer-1", time.Minute)
+	assert.NoError(t, presenceRepo.RemoveViewer(context.Background(), liveStreamID, "viewer-1"))
+
+	count, err := presenceRepo.CountActiveViewers(context.Background(), liveStreamID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
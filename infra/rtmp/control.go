@@ -0,0 +1,58 @@
+package rtmp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Aplicação do nginx-rtmp que recebe as transmissões dos broadcasters
+// (bloco `application app` do nginx.conf).
+const publishApplication = "app"
+
+// Permite encerrar transmissões ativas no servidor de ingestão.
+type PublishController interface {
+	DropPublisher(ctx context.Context, clientID string) error
+}
+
+// Cliente do módulo de controle do nginx-rtmp (`rtmp_control`).
+type Controller struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewController(baseURL string) *Controller {
+	return &Controller{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Derruba a conexão do broadcaster identificado por `clientID` (o
+// `clientid` recebido no callback `on_publish`).
+func (c *Controller) DropPublisher(ctx context.Context, clientID string) error {
+	query := url.Values{}
+	query.Set("app", publishApplication)
+	query.Set("clientid", clientID)
+
+	endpoint := fmt.Sprintf("%s/control/drop/client?%s", c.baseURL, query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("nginx-rtmp control returned status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package rtmp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropPublisher(t *testing.T) {
+	var path, app, clientID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		app = r.URL.Query().Get("app")
+		clientID = r.URL.Query().Get("clientid")
+		w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	err := NewController(server.URL).DropPublisher(context.Background(), "42")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/control/drop/client", path)
+	assert.Equal(t, "app", app)
+	assert.Equal(t, "42", clientID)
+}
+
+func TestDropPublisherNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := NewController(server.URL).DropPublisher(context.Background(), "42")
+	assert.Error(t, err)
+}
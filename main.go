@@ -3,26 +3,46 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/gtvb/livestream/application/http"
 	"github.com/gtvb/livestream/infra/db"
-	"github.com/gtvb/livestream/infra/repository"
 	_ "github.com/joho/godotenv/autoload"
 )
 
+const (
+	usersCollection       = "users"
+	liveStreamsCollection = "livestreams"
+	followsCollection     = "follows"
+)
+
 func main() {
-	db, err := db.NewDb()
+	// As migrações só se aplicam aos dados do MongoDB
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		db, err := db.NewDb()
+		if err != nil {
+			log.Printf("Failed to start database: %s\n", err.Error())
+			os.Exit(1)
+		}
+
+		err = runMigrate(db, os.Args[2:])
+		db.Client().Disconnect(context.TODO())
+		if err != nil {
+			log.Printf("Migration failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	repos, err := newRepositories(context.Background())
 	if err != nil {
-		log.Printf("Failed to start database: %s\n", err.Error())
+		log.Printf("Failed to start repositories: %s\n", err.Error())
 		return
 	}
 	defer func() {
 		log.Printf("disconnecting from db\n")
-		db.Client().Disconnect(context.TODO())
+		repos.close()
 	}()
 
-	userRepository := repository.NewUserRepository(db, "users")
-	liveStreamsRepository := repository.NewLiveStreamRepository(db, "livestreams")
-
-	http.RunServer(liveStreamsRepository, userRepository)
+	http.RunServer(repos.liveStreams, repos.users, repos.refreshTokens, repos.streamSessions, repos.viewerPresence, repos.follows, repos.relations, repos.unitOfWork)
 }
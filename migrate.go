@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gtvb/livestream/infra/db"
+	"github.com/gtvb/livestream/infra/migrations"
+)
+
+const migrateUsage = "usage: livestream migrate up|down|status"
+
+// Executa o subcomando `migrate`: `up` aplica as migrações pendentes,
+// `down` reverte a última aplicada e `status` lista todas elas.
+func runMigrate(database *db.Database, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := newMigrator(database)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		versions, err := applyMigrations(ctx, migrator)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			log.Printf("no pending migrations\n")
+		}
+	case "down":
+		version, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("reverted migration %d\n", version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied at " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-50s  %s\n", status.Version, status.Description, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+func newMigrator(database *db.Database) (*migrations.Migrator, error) {
+	return migrations.NewMigrator(database, migrations.All(migrations.Collections{
+		Users:       usersCollection,
+		LiveStreams: liveStreamsCollection,
+		Follows:     followsCollection,
+	}))
+}
+
+// Aplica as migrações pendentes, registrando cada versão aplicada.
+func applyMigrations(ctx context.Context, migrator *migrations.Migrator) ([]int, error) {
+	versions, err := migrator.Up(ctx)
+	for _, version := range versions {
+		log.Printf("applied migration %d\n", version)
+	}
+
+	return versions, err
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Live do feed junto do perfil público de quem a transmite.
+// swagger:model
+type FeedEntry struct {
+	LiveStream *LiveStream `json:"livestream"`
+	// Nulo caso o publisher tenha sido removido
+	Publisher *PublicProfile `json:"publisher"`
+	// Indica, no feed personalizado, que o usuário segue o publisher
+	Following bool `json:"following"`
+}
+
+// Ids dos publishers das lives, sem repetições.
+func PublisherIDs(liveStreams []*LiveStream) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(liveStreams))
+	seen := make(map[primitive.ObjectID]bool)
+	for _, ls := range liveStreams {
+		if !seen[ls.PublisherId] {
+			seen[ls.PublisherId] = true
+			ids = append(ids, ls.PublisherId)
+		}
+	}
+
+	return ids
+}
+
+// Junta a cada live o perfil do seu publisher entre `publishers`, mantendo
+// a ordem das lives.
+func JoinPublishers(liveStreams []*LiveStream, publishers []*User) []*FeedEntry {
+	profiles := make(map[primitive.ObjectID]*PublicProfile, len(publishers))
+	for _, user := range publishers {
+		profiles[user.ID] = user.PublicProfile()
+	}
+
+	entries := make([]*FeedEntry, 0, len(liveStreams))
+	for _, ls := range liveStreams {
+		entries = append(entries, &FeedEntry{LiveStream: ls, Publisher: profiles[ls.PublisherId]})
+	}
+
+	return entries
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Retornado ao tentar seguir a si mesmo.
+var ErrSelfFollow = errors.New("users cannot follow themselves")
+
+type FollowRepositoryInterface interface {
+	// Registra que `followerID` segue `followeeID`. Seguir quem já é
+	// seguido retorna `ErrNoChange`, e seguir a si mesmo `ErrSelfFollow`
+	Follow(ctx context.Context, followerID, followeeID primitive.ObjectID) error
+	// Desfaz o follow. Deixar de seguir quem não é seguido retorna
+	// `ErrNoChange`
+	Unfollow(ctx context.Context, followerID, followeeID primitive.ObjectID) error
+	// Apaga todos os follows de que o usuário participa, dos dois lados,
+	// retornando os follows apagados
+	DeleteUserFollows(ctx context.Context, userID primitive.ObjectID) ([]*Follow, error)
+
+	// Ids de todos os usuários seguidos por `followerID`
+	GetFollowingIDs(ctx context.Context, followerID primitive.ObjectID) ([]primitive.ObjectID, error)
+	// Listagens paginadas dos follows recebidos e feitos pelo usuário, na
+	// ordem em que foram criados
+	GetFollowers(ctx context.Context, followeeID primitive.ObjectID, page PageRequest) (*Page[*Follow], error)
+	GetFollowing(ctx context.Context, followerID primitive.ObjectID, page PageRequest) (*Page[*Follow], error)
+
+	// Conta os seguidores e seguidos de cada usuário presente no grafo
+	CountFollows(ctx context.Context) (map[primitive.ObjectID]FollowCounts, error)
+}
+
+// Representa o fato de um usuário seguir outro. Cada par é único.
+type Follow struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FollowerID primitive.ObjectID `bson:"follower_id" json:"follower_id"`
+	FolloweeID primitive.ObjectID `bson:"followee_id" json:"followee_id"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func NewFollow(followerID, followeeID primitive.ObjectID) *Follow {
+	return &Follow{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Posição do follow na listagem por id.
+func (f *Follow) Position() Cursor {
+	return Cursor{ID: f.ID}
+}
+
+// Quantidade de seguidores e de seguidos de um usuário.
+type FollowCounts struct {
+	Followers int
+	Following int
+}
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -8,49 +12,191 @@ import (
 )
 
 type LiveStreamRepositoryInterface interface {
-	CreateLiveStream(name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error)
-	DeleteLiveStream(id primitive.ObjectID) error
-	DeleteLiveStreamsByPublisher(id primitive.ObjectID) error
+	CreateLiveStream(ctx context.Context, name string, thumbnail string, streamKey string, publisherId primitive.ObjectID) (interface{}, error)
+	// Marca a live como removida em `deletedAt`. Lives removidas não são
+	// retornadas pelas buscas e não podem ser alteradas
+	DeleteLiveStream(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error
+	// Marca como removidas, em `deletedAt`, as lives ainda não removidas do usuário
+	DeleteLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error)
+	// Restaura as lives do usuário removidas exatamente em `deletedAt`
+	RestoreLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (int64, error)
+	// Apagam definitivamente uma live já removida, ou todas as lives do usuário
+	PurgeLiveStream(ctx context.Context, id primitive.ObjectID) error
+	PurgeLiveStreamsByPublisher(ctx context.Context, id primitive.ObjectID) (int64, error)
 
-	UpdateLiveStream(id primitive.ObjectID, newData bson.M) error
+	UpdateLiveStream(ctx context.Context, id primitive.ObjectID, newData bson.M) error
+	RotateLiveStreamKey(ctx context.Context, id primitive.ObjectID, newStreamKey string, rotation *StreamKeyRotation) error
 
-	IncrementLiveStreamUserCount(id primitive.ObjectID) error
-	DecrementLiveStreamUserCount(id primitive.ObjectID) error
+	IncrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error
+	DecrementLiveStreamUserCount(ctx context.Context, id primitive.ObjectID) error
+	SetLiveStreamViewerCount(ctx context.Context, id primitive.ObjectID, viewers int) error
 
-	GetAllLiveStreamsByUserId(id primitive.ObjectID) ([]*LiveStream, error)
-	GetLiveStreamById(id primitive.ObjectID) (*LiveStream, error)
-	GetLiveStreamByName(name string) (*LiveStream, error)
-	GetLiveStreamByStreamKey(key string) (*LiveStream, error)
-	GetLiveStreamFeed(maxStreams int) ([]*LiveStream, error)
-	GetAllLiveStreams() ([]*LiveStream, error)
+	// Listagens paginadas, ordenadas pelo id (a ordem de criação)
+	GetAllLiveStreamsByUserId(ctx context.Context, id primitive.ObjectID, page PageRequest) (*Page[*LiveStream], error)
+	GetAllLiveStreams(ctx context.Context, page PageRequest) (*Page[*LiveStream], error)
+
+	GetLiveStreamById(ctx context.Context, id primitive.ObjectID) (*LiveStream, error)
+	GetLiveStreamByName(ctx context.Context, name string) (*LiveStream, error)
+	GetLiveStreamByStreamKey(ctx context.Context, key string) (*LiveStream, error)
+	GetActiveLiveStreams(ctx context.Context) ([]*LiveStream, error)
+	// Até `limit` lives ativas, das com mais espectadores para as com
+	// menos, com empates pelo id
+	GetMostWatchedLiveStreams(ctx context.Context, limit int) ([]*LiveStream, error)
+	// Lives ativas de qualquer um dos publishers dados, em qualquer ordem
+	GetActiveLiveStreamsByPublishers(ctx context.Context, publisherIDs []primitive.ObjectID) ([]*LiveStream, error)
+
+	GetLiveStreamsDeletedBefore(ctx context.Context, before time.Time) ([]*LiveStream, error)
 }
 
+const keyFingerprintLength = 12
+
 // Representa uma livestream acontecendo na plataforma
 // swagger:model
 type LiveStream struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name      string             `json:"name"`
-	Thubmnail string             `json:"thumbnail"`
+	Thumbnail string             `bson:"thumbnail" json:"thumbnail"`
+
+	// Apenas o hash da chave de stream é guardado, a chave em si
+	// é exibida uma única vez, na criação da live
+	StreamKeyHash string             `bson:"stream_key_hash" json:"-"`
+	ViewerCount   int                `bson:"viewer_count" json:"viewer_count"`
+	PublisherId   primitive.ObjectID `bson:"publisher_id" json:"publisher_id"`
+
+	// Endereços (IPs ou blocos CIDR) autorizados a transmitir com a
+	// chave desta live. Uma lista vazia autoriza qualquer endereço.
+	// Exibidos apenas ao publisher, em `Settings`
+	AllowedIPs []string `bson:"allowed_ips" json:"-"`
 
-	StreamKey   string             `bson:"stream_key" json:"stream_key"`
-	ViewerCount int                `bson:"viewer_count" json:"viewer_count"`
-	PublisherId primitive.ObjectID `bson:"publisher_id" json:"publisher_id"`
+	// Histórico das rotações da chave de stream, para auditoria. Exibido
+	// apenas ao publisher, em `Settings`
+	StreamKeyRotations []*StreamKeyRotation `bson:"stream_key_rotations" json:"-"`
+	// Id do cliente (no nginx-rtmp) que está transmitindo no momento
+	PublisherClientID string `bson:"publisher_client_id" json:"-"`
 
 	LiveStatus bool `bson:"live_stream_status" json:"live_stream_status"`
+	// Momento em que a última transmissão foi encerrada
+	LastSessionEndedAt *time.Time `bson:"last_session_ended_at" json:"last_session_ended_at"`
 
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	// Momento em que a live foi removida, até ser apagada definitivamente
+	DeletedAt *time.Time `bson:"deleted_at" json:"-"`
 }
 
 func NewLiveStream(name string, thumbnail string, publisherId primitive.ObjectID, streamKey string) *LiveStream {
 	return &LiveStream{
 		Name:        name,
-		Thubmnail:   thumbnail,
+		Thumbnail:   thumbnail,
 		PublisherId: publisherId,
 		LiveStatus:  false,
 		ViewerCount: 0,
-		StreamKey:   streamKey,
+
+		StreamKeyHash:      HashStreamKey(streamKey),
+		AllowedIPs:         make([]string, 0),
+		StreamKeyRotations: make([]*StreamKeyRotation, 0),
 
 		CreatedAt: time.Now(),
 	}
 }
+
+// Configurações de publicação de uma live, visíveis apenas ao publisher.
+// swagger:model
+type LiveStreamSettings struct {
+	ID primitive.ObjectID `json:"id"`
+	// Endereços autorizados a transmitir. Uma lista vazia autoriza qualquer endereço
+	AllowedIPs []string `json:"allowed_ips"`
+	// Histórico das rotações da chave de stream
+	StreamKeyRotations []*StreamKeyRotation `json:"stream_key_rotations"`
+}
+
+func (ls *LiveStream) Settings() *LiveStreamSettings {
+	return &LiveStreamSettings{
+		ID:                 ls.ID,
+		AllowedIPs:         ls.AllowedIPs,
+		StreamKeyRotations: ls.StreamKeyRotations,
+	}
+}
+
+// Registro de uma rotação da chave de stream de uma live.
+// swagger:model
+type StreamKeyRotation struct {
+	RotatedAt time.Time          `bson:"rotated_at" json:"rotated_at"`
+	RotatedBy primitive.ObjectID `bson:"rotated_by" json:"rotated_by"`
+	Reason    string             `bson:"reason" json:"reason"`
+
+	// Prefixo do hash da chave revogada, suficiente para identificá-la
+	PreviousKeyFingerprint string `bson:"previous_key_fingerprint" json:"previous_key_fingerprint"`
+	// Indica se foi solicitado o encerramento da transmissão ativa
+	TerminatePublish bool `bson:"terminate_publish" json:"terminate_publish"`
+}
+
+func NewStreamKeyRotation(rotatedBy primitive.ObjectID, previousKeyHash, reason string) *StreamKeyRotation {
+	fingerprint := previousKeyHash
+	if len(fingerprint) > keyFingerprintLength {
+		fingerprint = fingerprint[:keyFingerprintLength]
+	}
+
+	return &StreamKeyRotation{
+		RotatedAt:              time.Now(),
+		RotatedBy:              rotatedBy,
+		Reason:                 reason,
+		PreviousKeyFingerprint: fingerprint,
+	}
+}
+
+// Calcula o hash (SHA-256) de uma chave de stream. O hash é
+// determinístico para que a live possa ser buscada pela chave
+// recebida nos callbacks do nginx-rtmp.
+func HashStreamKey(streamKey string) string {
+	sum := sha256.Sum256([]byte(streamKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verifica se `addr` pode transmitir para esta live de acordo com
+// a lista de endereços autorizados.
+func (ls *LiveStream) AllowsAddress(addr string) bool {
+	if len(ls.AllowedIPs) == 0 {
+		return true
+	}
+
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+
+	for _, allowed := range ls.AllowedIPs {
+		prefix, err := ParseAllowedIP(allowed)
+		if err != nil {
+			continue
+		}
+
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Interpreta uma entrada da lista de endereços autorizados, que pode
+// ser tanto um IP isolado quanto um bloco CIDR.
+func ParseAllowedIP(allowed string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(allowed); err == nil {
+		return prefix.Masked(), nil
+	}
+
+	ip, err := netip.ParseAddr(allowed)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// Posição da live na listagem por id.
+func (ls *LiveStream) Position() Cursor {
+	return Cursor{ID: ls.ID}
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// O cursor de paginação não pertence à listagem consultada
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Posição de um item em uma listagem paginada. As listagens são
+// ordenadas pela seção, de forma decrescente pela chave (quando existe) e
+// crescente pelo id, que desempata itens com a mesma chave.
+type Cursor struct {
+	// Seção do item, nas listagens divididas em seções consecutivas
+	Section int `json:"s,omitempty"`
+	// Chave de ordenação do item. Nula nas listagens ordenadas apenas pelo id
+	Key *float64           `json:"k,omitempty"`
+	ID  primitive.ObjectID `json:"id"`
+	// Indica que a página desejada é a anterior ao item, e não a seguinte
+	Backward bool `json:"b,omitempty"`
+}
+
+// Codifica o cursor no token opaco entregue aos clientes.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decodifica um token gerado por `Encode`.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID.IsZero() {
+		return nil, errors.New("malformed cursor")
+	}
+
+	return &cursor, nil
+}
+
+// Parâmetros de uma página. Sem cursor, a primeira página é retornada.
+type PageRequest struct {
+	// Máximo de itens na página, que deve ser positivo
+	Limit  int
+	Cursor *Cursor
+}
+
+// Página de uma listagem, junto dos tokens das páginas vizinhas.
+type Page[T any] struct {
+	Items []T
+	// Token da página seguinte, vazio na última
+	NextCursor string
+	// Token da página anterior, vazio na primeira
+	PrevCursor string
+}
+
+// Monta uma página a partir de até `req.Limit`+1 itens buscados a partir
+// do cursor, no sentido que ele indica (na ordem inversa da listagem, se
+// `Backward`). O item excedente só indica que há mais itens nesse sentido.
+// `position` retorna o cursor (sem sentido) de um item.
+func NewPage[T any](items []T, req PageRequest, position func(item T) Cursor) *Page[T] {
+	backward := req.Cursor != nil && req.Cursor.Backward
+
+	hasMore := len(items) > req.Limit
+	if hasMore {
+		items = items[:req.Limit]
+	}
+	if backward {
+		slices.Reverse(items)
+	}
+
+	page := &Page[T]{Items: items}
+	if len(items) == 0 {
+		// Sem itens, a única página vizinha é a do lado de onde o cursor veio
+		if req.Cursor != nil {
+			reverse := *req.Cursor
+			reverse.Backward = !backward
+			if backward {
+				page.NextCursor = reverse.Encode()
+			} else {
+				page.PrevCursor = reverse.Encode()
+			}
+		}
+		return page
+	}
+
+	first, last := position(items[0]), position(items[len(items)-1])
+	first.Backward = true
+
+	// Um cursor indica que há itens do lado de onde ele veio
+	if backward {
+		page.NextCursor = last.Encode()
+		if hasMore {
+			page.PrevCursor = first.Encode()
+		}
+	} else {
+		if hasMore {
+			page.NextCursor = last.Encode()
+		}
+		if req.Cursor != nil {
+			page.PrevCursor = first.Encode()
+		}
+	}
+
+	return page
+}
+
+// Compara duas posições na ordem das listagens, retornando um valor
+// negativo se `a` vem antes de `b`, positivo se vem depois e zero se são
+// a mesma posição.
+func ComparePositions(a, b Cursor) int {
+	if a.Section != b.Section {
+		return cmp.Compare(a.Section, b.Section)
+	}
+
+	if a.Key != nil && b.Key != nil && *a.Key != *b.Key {
+		if *a.Key > *b.Key {
+			return -1
+		}
+		return 1
+	}
+
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// Monta uma página a partir de todos os itens de uma listagem já em
+// memória, com o mesmo tratamento de cursor das consultas ao banco.
+// `keyed` indica que a listagem é ordenada por uma chave, além do id.
+// A lista dada não é alterada.
+func Paginate[T any](items []T, req PageRequest, keyed bool, position func(item T) Cursor) (*Page[T], error) {
+	items = slices.Clone(items)
+	slices.SortStableFunc(items, func(a, b T) int {
+		return ComparePositions(position(a), position(b))
+	})
+
+	if cursor := req.Cursor; cursor != nil {
+		if keyed && cursor.Key == nil {
+			return nil, fmt.Errorf("%w: missing key", ErrInvalidCursor)
+		}
+
+		// A posição do cursor apenas pelo id, nas listagens que não usam chave
+		reference := *cursor
+		if !keyed {
+			reference.Key = nil
+			reference.Section = 0
+		}
+
+		items = slices.DeleteFunc(items, func(item T) bool {
+			cmp := ComparePositions(position(item), reference)
+			return (cursor.Backward && cmp >= 0) || (!cursor.Backward && cmp <= 0)
+		})
+		if cursor.Backward {
+			slices.Reverse(items)
+		}
+	}
+
+	if len(items) > req.Limit+1 {
+		items = items[:req.Limit+1]
+	}
+
+	return NewPage(items, req, position), nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RefreshTokenRepositoryInterface interface {
+	CreateRefreshToken(ctx context.Context, userID, familyID primitive.ObjectID, tokenHash, device string, expiresAt time.Time) (interface{}, error)
+
+	RevokeRefreshToken(ctx context.Context, id primitive.ObjectID) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID primitive.ObjectID) error
+	RevokeAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) error
+	DeleteAllUserRefreshTokens(ctx context.Context, userID primitive.ObjectID) (int64, error)
+
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+}
+
+// Representa um refresh token emitido para um dispositivo do usuário.
+// Apenas o hash do token é guardado. Todos os tokens gerados a partir
+// de um mesmo login compartilham a mesma família (`FamilyID`), o que
+// permite revogar a sessão inteira caso um token já usado seja reapresentado.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	FamilyID  primitive.ObjectID `bson:"family_id" json:"family_id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	Device    string             `bson:"device" json:"device"`
+
+	ExpiresAt time.Time  `bson:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `bson:"revoked_at" json:"revoked_at"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+}
+
+func NewRefreshToken(userID, familyID primitive.ObjectID, tokenHash, device string, expiresAt time.Time) *RefreshToken {
+	return &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		Device:    device,
+
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Indica se o token já foi utilizado (rotacionado) ou revogado.
+func (rt *RefreshToken) Revoked() bool {
+	return rt.RevokedAt != nil
+}
+
+// Indica se o token já passou do seu prazo de validade.
+func (rt *RefreshToken) Expired() bool {
+	return time.Now().After(rt.ExpiresAt)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Retornado ao tentar bloquear ou silenciar a si mesmo.
+var ErrSelfRelation = errors.New("users cannot block or mute themselves")
+
+// Tipo da relação de um usuário com outro.
+type RelationKind string
+
+const (
+	// O usuário bloqueado não pode seguir quem o bloqueou nem ser seguido
+	// por ele, e não pode assistir às suas lives
+	RelationBlock RelationKind = "block"
+	// As lives do usuário silenciado somem do feed de quem o silenciou
+	RelationMute RelationKind = "mute"
+)
+
+type RelationRepositoryInterface interface {
+	// Registra a relação `kind` de `userID` com `targetID`. Repetir uma
+	// relação existente retorna `ErrNoChange`, e relacionar-se consigo
+	// mesmo `ErrSelfRelation`
+	AddRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind RelationKind) error
+	// Desfaz a relação. Desfazer uma relação inexistente retorna
+	// `ErrNoChange`
+	RemoveRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind RelationKind) error
+	// Apaga todas as relações de que o usuário participa, dos dois lados,
+	// retornando quantas foram apagadas
+	DeleteUserRelations(ctx context.Context, userID primitive.ObjectID) (int64, error)
+
+	// Se `userID` tem a relação `kind` com `targetID`
+	HasRelation(ctx context.Context, userID, targetID primitive.ObjectID, kind RelationKind) (bool, error)
+	// Se algum dos dois usuários bloqueou o outro
+	IsBlockedBetween(ctx context.Context, userID, otherID primitive.ObjectID) (bool, error)
+	// Ids de todos os usuários com quem `userID` tem alguma das relações `kinds`
+	GetRelatedIDs(ctx context.Context, userID primitive.ObjectID, kinds ...RelationKind) ([]primitive.ObjectID, error)
+	// Listagem paginada das relações `kind` feitas pelo usuário, na ordem
+	// em que foram criadas
+	GetRelations(ctx context.Context, userID primitive.ObjectID, kind RelationKind, page PageRequest) (*Page[*Relation], error)
+}
+
+// Representa o bloqueio ou silenciamento de um usuário por outro. Cada
+// usuário tem no máximo uma relação de cada tipo com outro.
+type Relation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	TargetID  primitive.ObjectID `bson:"target_id" json:"target_id"`
+	Kind      RelationKind       `bson:"kind" json:"kind"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func NewRelation(userID, targetID primitive.ObjectID, kind RelationKind) *Relation {
+	return &Relation{
+		UserID:    userID,
+		TargetID:  targetID,
+		Kind:      kind,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Posição da relação na listagem por id.
+func (r *Relation) Position() Cursor {
+	return Cursor{ID: r.ID}
+}
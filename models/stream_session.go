@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type StreamSessionRepositoryInterface interface {
+	CreateStreamSession(ctx context.Context, liveStreamID, publisherID primitive.ObjectID, clientIP, clientID string, encoder EncoderInfo) (interface{}, error)
+	CloseStreamSessions(ctx context.Context, liveStreamID primitive.ObjectID) error
+	DeleteStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error)
+	CountStreamSessionsByPublisher(ctx context.Context, publisherID primitive.ObjectID) (int64, error)
+
+	RecordStreamSessionViewers(ctx context.Context, liveStreamID primitive.ObjectID, viewers int) error
+
+	GetOpenStreamSession(ctx context.Context, liveStreamID primitive.ObjectID) (*StreamSession, error)
+	// Busca de uma só vez as sessões abertas das lives dadas, em qualquer ordem
+	GetOpenStreamSessions(ctx context.Context, liveStreamIDs []primitive.ObjectID) ([]*StreamSession, error)
+	// Lista as sessões da live da mais recente para a mais antiga
+	GetStreamSessionsByLiveStream(ctx context.Context, liveStreamID primitive.ObjectID, page PageRequest) (*Page[*StreamSession], error)
+}
+
+// Informações sobre o software de transmissão, obtidas a partir dos
+// argumentos enviados pelo nginx-rtmp no callback `on_publish`.
+type EncoderInfo struct {
+	FlashVersion string `bson:"flash_version" json:"flash_version"`
+	SwfURL       string `bson:"swf_url" json:"swf_url"`
+	TcURL        string `bson:"tc_url" json:"tc_url"`
+	PageURL      string `bson:"page_url" json:"page_url"`
+}
+
+// Representa uma transmissão de uma live, do `on_publish` ao
+// `on_publish_done`.
+// swagger:model
+type StreamSession struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LiveStreamID primitive.ObjectID `bson:"live_stream_id" json:"live_stream_id"`
+	PublisherID  primitive.ObjectID `bson:"publisher_id" json:"publisher_id"`
+
+	ClientIP string      `bson:"client_ip" json:"client_ip"`
+	ClientID string      `bson:"client_id" json:"client_id"`
+	Encoder  EncoderInfo `bson:"encoder" json:"encoder"`
+
+	PeakViewers    int     `bson:"peak_viewers" json:"peak_viewers"`
+	AverageViewers float64 `bson:"average_viewers" json:"average_viewers"`
+	// Soma e quantidade das amostras de espectadores, usadas
+	// para calcular a média quando a sessão é encerrada
+	ViewerSum     int `bson:"viewer_sum" json:"-"`
+	ViewerSamples int `bson:"viewer_samples" json:"-"`
+
+	StartedAt time.Time  `bson:"started_at" json:"started_at"`
+	EndedAt   *time.Time `bson:"ended_at" json:"ended_at"`
+}
+
+func NewStreamSession(liveStreamID, publisherID primitive.ObjectID, clientIP, clientID string, encoder EncoderInfo) *StreamSession {
+	return &StreamSession{
+		LiveStreamID: liveStreamID,
+		PublisherID:  publisherID,
+
+		ClientIP: clientIP,
+		ClientID: clientID,
+		Encoder:  encoder,
+
+		StartedAt: time.Now(),
+	}
+}
+
+// Posição da sessão na listagem pelo início, da mais recente para a mais
+// antiga. A chave são os milissegundos do início, a precisão guardada
+// pelo banco.
+func (s *StreamSession) Position() Cursor {
+	started := float64(s.StartedAt.UnixMilli())
+	return Cursor{Key: &started, ID: s.ID}
+}
@@ -0,0 +1,12 @@
+package models
+
+import "context"
+
+// Executa um conjunto de operações dos repositórios de forma atômica.
+type UnitOfWork interface {
+	// Executa `fn` em uma transação. As operações feitas com o contexto
+	// recebido por `fn` fazem parte dela: se `fn` retornar um erro,
+	// nenhuma delas é aplicada. `fn` pode ser executada mais de uma vez
+	// caso a transação precise ser repetida.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
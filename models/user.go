@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -8,19 +9,37 @@ import (
 )
 
 type UserRepositoryInterface interface {
-	CreateUser(username, email, password string) (interface{}, error)
-	DeleteUser(id primitive.ObjectID) error
+	CreateUser(ctx context.Context, username, email, password string) (interface{}, error)
 
-	UpdateUser(id primitive.ObjectID, newData bson.M) error
+	// Marca o usuário como removido em `deletedAt`. Usuários removidos
+	// não são retornados pelas buscas e não podem ser alterados, mas
+	// continuam ocupando seu email e username até serem apagados
+	DeleteUser(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) error
+	RestoreUser(ctx context.Context, id primitive.ObjectID) error
+	// Apaga definitivamente um usuário já removido
+	PurgeUser(ctx context.Context, id primitive.ObjectID) error
 
-	UpdateUserAddToFollowList(id primitive.ObjectID, following primitive.ObjectID) error
-	UpdateUserRemoveFromFollowList(id primitive.ObjectID, following primitive.ObjectID) error
+	UpdateUser(ctx context.Context, id primitive.ObjectID, newData bson.M) error
 
-	GetUserById(id primitive.ObjectID) (*User, error)
-	GetUserByEmail(email string) (*User, error)
-	GetUserByUsername(username string) (*User, error)
+	// Soma `followers` e `following` aos contadores do usuário, inclusive
+	// se ele estiver removido. Usuários inexistentes são ignorados
+	AddFollowCounts(ctx context.Context, id primitive.ObjectID, followers, following int) error
+	// Grava os contadores de todos os usuários a partir de `counts`, com
+	// zero para os ausentes, retornando quantos usuários foram corrigidos
+	SetFollowCounts(ctx context.Context, counts map[primitive.ObjectID]FollowCounts) (int64, error)
 
-	GetAllUsers() ([]*User, error)
+	GetUserById(ctx context.Context, id primitive.ObjectID) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	// Busca de uma só vez os usuários (não removidos) com os ids dados, em
+	// qualquer ordem. Ids sem usuário correspondente são ignorados
+	GetUsersByIds(ctx context.Context, ids []primitive.ObjectID) ([]*User, error)
+
+	// Listagem paginada, ordenada pelo id (a ordem de criação)
+	GetAllUsers(ctx context.Context, page PageRequest) (*Page[*User], error)
+
+	GetDeletedUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUsersDeletedBefore(ctx context.Context, before time.Time) ([]*User, error)
 }
 
 // Representa um usuário cadastrado na plataforma
@@ -29,12 +48,18 @@ type User struct {
 	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Username string             `bson:"username" json:"username"`
 	Email    string             `bson:"email" json:"email"`
-	Password string             `bson:"password" json:"password"`
+	Password string             `bson:"password" json:"-"`
 
-	Following []primitive.ObjectID `bson:"following" json:"following"`
+	// Contadores mantidos junto dos follows. Usuários removidos continuam
+	// contando até serem apagados
+	FollowerCount  int `bson:"follower_count" json:"follower_count"`
+	FollowingCount int `bson:"following_count" json:"following_count"`
 
 	CreatedAt time.Time `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	// Momento em que o usuário removeu a conta. Ela pode ser restaurada
+	// até ser apagada definitivamente
+	DeletedAt *time.Time `bson:"deleted_at" json:"-"`
 }
 
 func NewUser(username, email, password string) *User {
@@ -43,8 +68,71 @@ func NewUser(username, email, password string) *User {
 		Email:    email,
 		Password: password,
 
-		Following: make([]primitive.ObjectID, 0),
-
 		CreatedAt: time.Now(),
 	}
 }
+
+// Perfil público de um usuário, seguro para ser exibido a qualquer cliente.
+// swagger:model
+type PublicProfile struct {
+	ID       primitive.ObjectID `json:"id"`
+	Username string             `json:"username"`
+
+	FollowerCount  int `json:"follower_count"`
+	FollowingCount int `json:"following_count"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Perfil visto pelo próprio usuário, contendo também seus dados de contato.
+// swagger:model
+type SelfProfile struct {
+	ID       primitive.ObjectID `json:"id"`
+	Username string             `json:"username"`
+	Email    string             `json:"email"`
+
+	FollowerCount  int `json:"follower_count"`
+	FollowingCount int `json:"following_count"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Visão administrativa de um usuário. Estende o perfil visto pelo próprio
+// usuário, ao qual só devem ser acrescentados os dados exclusivos dos
+// administradores; o hash da senha nunca é exposto.
+// swagger:model
+type AdminUserView struct {
+	SelfProfile
+}
+
+func (u *User) PublicProfile() *PublicProfile {
+	return &PublicProfile{
+		ID:             u.ID,
+		Username:       u.Username,
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+		CreatedAt:      u.CreatedAt,
+	}
+}
+
+func (u *User) SelfProfile() *SelfProfile {
+	return &SelfProfile{
+		ID:             u.ID,
+		Username:       u.Username,
+		Email:          u.Email,
+		FollowerCount:  u.FollowerCount,
+		FollowingCount: u.FollowingCount,
+		CreatedAt:      u.CreatedAt,
+		UpdatedAt:      u.UpdatedAt,
+	}
+}
+
+func (u *User) AdminView() *AdminUserView {
+	return &AdminUserView{SelfProfile: *u.SelfProfile()}
+}
+
+// Posição do usuário na listagem por id.
+func (u *User) Position() Cursor {
+	return Cursor{ID: u.ID}
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ViewerPresenceRepositoryInterface interface {
+	RecordViewerHeartbeat(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string, ttl time.Duration) error
+	RemoveViewer(ctx context.Context, liveStreamID primitive.ObjectID, sessionID string) error
+
+	CountActiveViewers(ctx context.Context, liveStreamID primitive.ObjectID) (int, error)
+}
+
+// Representa a presença de um espectador assistindo uma live. Cada
+// heartbeat do player renova `ExpiresAt`; espectadores que param de
+// enviar heartbeats deixam de ser contados quando ele passa.
+type ViewerPresence struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	LiveStreamID primitive.ObjectID `bson:"live_stream_id" json:"live_stream_id"`
+	SessionID    string             `bson:"session_id" json:"session_id"`
+
+	LastSeenAt time.Time `bson:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time `bson:"expires_at" json:"expires_at"`
+}
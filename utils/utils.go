@@ -10,8 +10,13 @@ import (
 )
 
 const (
-	UserCollectionTest       = "users_test"
-	LiveStreamCollectionTest = "livestreams_test"
+	UserCollectionTest           = "users_test"
+	LiveStreamCollectionTest     = "livestreams_test"
+	RefreshTokenCollectionTest   = "refresh_tokens_test"
+	StreamSessionCollectionTest  = "stream_sessions_test"
+	ViewerPresenceCollectionTest = "viewer_presence_test"
+	FollowCollectionTest         = "follows_test"
+	RelationCollectionTest       = "relations_test"
 )
 
 type TestContainer struct {
@@ -26,7 +31,8 @@ type TestContainer struct {
 func NewTestContainer(databaseName string) (*TestContainer, error) {
 	ctx := context.Background()
 
-	mongoContainer, err := mongodb.Run(ctx, "mongo")
+	// Transações só funcionam em um replica set
+	mongoContainer, err := mongodb.Run(ctx, "mongo", mongodb.WithReplicaSet("rs"))
 	if err != nil {
 		return nil, err
 	}
@@ -43,12 +49,14 @@ func (tc *TestContainer) SetupDatabaseWrapper() error {
 		return err
 	}
 
-	mongoClient, err := mongo.Connect(tc.ctx, options.Client().ApplyURI(endpoint))
+	// O replica set anuncia o endereço interno do container, então a
+	// conexão é feita diretamente com o nó pelo endereço exposto
+	mongoClient, err := mongo.Connect(tc.ctx, options.Client().ApplyURI(endpoint).SetDirect(true))
 	if err != nil {
 		return err
 	}
 
-	db := &db.Database{mongoClient.Database(tc.databaseName)}
+	db := &db.Database{Database: mongoClient.Database(tc.databaseName)}
 	tc.Database = db
 
 	return nil